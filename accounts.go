@@ -16,8 +16,9 @@
 package keyhub
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"iter"
 	"strconv"
 
 	"github.com/dghubble/sling"
@@ -35,39 +36,31 @@ func newAccountService(sling *sling.Sling) *AccountService {
 	}
 }
 
-func (s *AccountService) List() (accounts []model.Account, err error) {
-
-	searchRange := model.NewRange()
-
-	var response *http.Response
-
-	for ok := true; ok; ok = searchRange.NextPage() {
-
-		errorReport := new(model.ErrorReport)
-		results := new(model.AccountList)
-		response, err = s.sling.New().Get("").Add(searchRange.GetRequestRangeHeader()).Add(searchRange.GetRequestModeHeader()).Receive(results, errorReport)
-		searchRange.ParseResponse(response)
+func (s *AccountService) List(opts ...ListOption) (accounts []model.Account, err error) {
+	return s.ListContext(context.Background(), opts...)
+}
 
-		if errorReport.Code > 0 {
-			err = errorReport.Wrap("Could not fetch accounts,")
-		}
-		if err == nil {
-			if len(results.Items) > 0 {
-				accounts = append(accounts, results.Items...)
-			}
-		}
+// ListContext Retrieve all accounts, no further pages are fetched once ctx is done
+func (s *AccountService) ListContext(ctx context.Context, opts ...ListOption) (accounts []model.Account, err error) {
+	return collect(s.All(ctx, nil, opts...))
+}
 
-	}
-	return
+// All Iterate all accounts matching query, pages are fetched while ranging over the accounts
+func (s *AccountService) All(ctx context.Context, query *model.AccountQueryParams, opts ...ListOption) iter.Seq2[model.Account, error] {
+	return paginate[model.Account](ctx, s.sling.New().Get("").QueryStruct(query), opts, "Could not fetch accounts,")
 }
 
 func (s *AccountService) GetByUUID(uuid uuid.UUID) (result *model.Account, err error) {
+	return s.GetByUUIDContext(context.Background(), uuid)
+}
+
+func (s *AccountService) GetByUUIDContext(ctx context.Context, uuid uuid.UUID) (result *model.Account, err error) {
 	al := new(model.AccountList)
 	errorReport := new(model.ErrorReport)
 
 	params := &model.AccountQueryParams{UUID: uuid.String()}
 
-	_, err = s.sling.New().Get("").QueryStruct(params).Receive(al, errorReport)
+	_, err = receive(ctx, s.sling.New().Get("").QueryStruct(params), al, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get Account %q.", uuid)
 	}
@@ -75,7 +68,7 @@ func (s *AccountService) GetByUUID(uuid uuid.UUID) (result *model.Account, err e
 		if len(al.Items) > 0 {
 			result = &al.Items[0]
 		} else {
-			err = fmt.Errorf("Account %q not found", uuid.String())
+			err = fmt.Errorf("Account %q %w", uuid.String(), ErrNotFound)
 		}
 	}
 
@@ -83,17 +76,21 @@ func (s *AccountService) GetByUUID(uuid uuid.UUID) (result *model.Account, err e
 }
 
 func (s *AccountService) GetById(id int64) (result *model.Account, err error) {
+	return s.GetByIdContext(context.Background(), id)
+}
+
+func (s *AccountService) GetByIdContext(ctx context.Context, id int64) (result *model.Account, err error) {
 	al := new(model.Account)
 	errorReport := new(model.ErrorReport)
 	idString := strconv.FormatInt(id, 10)
 
-	_, err = s.sling.New().Get(idString).Receive(al, errorReport)
+	_, err = receive(ctx, s.sling.New().Get(idString), al, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get Account %q.", idString)
 		return
 	}
 	if err == nil && al == nil {
-		err = fmt.Errorf("Account %q not found", idString)
+		err = fmt.Errorf("Account %q %w", idString, ErrNotFound)
 		return
 	}
 
@@ -0,0 +1,180 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package keyhub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	grantTypeJWTBearer = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+
+	// maxTokenResponseSize Token responses larger than this are rejected
+	maxTokenResponseSize = 1 << 20
+)
+
+// TokenSourceFunc Creates the token source of a Client once the endpoints of the issuer are discovered.
+// The TokenURL of endpoint requests a vault session and ctx carries the http.Client to use as oauth2.HTTPClient.
+type TokenSourceFunc func(ctx context.Context, endpoint oauth2.Endpoint) (oauth2.TokenSource, error)
+
+// AssertionFunc Returns the signed JWT that is exchanged for an access token, e.g. a workload identity token
+type AssertionFunc func(ctx context.Context) (string, error)
+
+// vaultEndpoint Return endpoint with a TokenURL that requests a vault session along with the token
+func vaultEndpoint(endpoint oauth2.Endpoint) oauth2.Endpoint {
+	endpoint.TokenURL = endpoint.TokenURL + "?authVault=access"
+	return endpoint
+}
+
+// contextClient Return the http.Client stored as oauth2.HTTPClient in ctx
+func contextClient(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && client != nil {
+		return client
+	}
+	return http.DefaultClient
+}
+
+// NewJWTBearerTokenSource Create a token source exchanging the JWT returned by assertion for an access token (RFC 7523)
+func NewJWTBearerTokenSource(ctx context.Context, tokenURL string, scopes []string, assertion AssertionFunc) oauth2.TokenSource {
+	return &jwtBearerTokenSource{
+		ctx:       ctx,
+		tokenURL:  tokenURL,
+		scopes:    scopes,
+		assertion: assertion,
+	}
+}
+
+type jwtBearerTokenSource struct {
+	ctx       context.Context
+	tokenURL  string
+	scopes    []string
+	assertion AssertionFunc
+}
+
+func (s *jwtBearerTokenSource) Token() (*oauth2.Token, error) {
+	assertion, err := s.assertion(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not obtain jwt assertion: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {grantTypeJWTBearer},
+		"assertion":  {assertion},
+	}
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := contextClient(s.ctx).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &oauth2.RetrieveError{Response: resp, Body: body}
+	}
+
+	return parseToken(body)
+}
+
+// JWTAssertionFromFile Return an AssertionFunc reading the JWT from path on every exchange, e.g. a projected service account token
+func JWTAssertionFromFile(path string) AssertionFunc {
+	return func(ctx context.Context) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+}
+
+// NewFileTokenSource Create a token source reading the token from path every time a token is needed.
+// The file holds a token endpoint response, all members besides the standard ones (like vaultSession) are kept as token extras.
+func NewFileTokenSource(path string) oauth2.TokenSource {
+	return &fileTokenSource{path: path}
+}
+
+type fileTokenSource struct {
+	path string
+}
+
+func (s *fileTokenSource) Token() (*oauth2.Token, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	token, err := parseToken(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not read token from %s: %w", s.path, err)
+	}
+	return token, nil
+}
+
+// parseToken Parse a token endpoint response, which may use expiry instead of expires_in when it was written by this package
+func parseToken(data []byte) (*oauth2.Token, error) {
+	var fields struct {
+		AccessToken  string          `json:"access_token"`
+		TokenType    string          `json:"token_type"`
+		RefreshToken string          `json:"refresh_token"`
+		ExpiresIn    json.RawMessage `json:"expires_in"`
+		Expiry       time.Time       `json:"expiry"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	extra := make(map[string]interface{})
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return nil, err
+	}
+	if fields.AccessToken == "" {
+		return nil, fmt.Errorf("token response contains no access_token")
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  fields.AccessToken,
+		TokenType:    fields.TokenType,
+		RefreshToken: fields.RefreshToken,
+		Expiry:       fields.Expiry,
+	}
+	// expires_in is a number, but some servers send it as a string
+	if expiresIn, err := strconv.ParseInt(strings.Trim(string(fields.ExpiresIn), `"`), 10, 64); err == nil && expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+
+	return token.WithExtra(extra), nil
+}
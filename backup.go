@@ -0,0 +1,342 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package keyhub
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+
+	"filippo.io/age"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+const (
+	// BackupFormat Identifies a backup archive of VaultService.Backup
+	BackupFormat = "go-keyhub-vault-backup"
+	// BackupVersion Version of the archive layout written by VaultService.Backup, Restore reads this version and older
+	BackupVersion = 1
+)
+
+// ConflictPolicy Decides what Restore does with a record whose name is already taken in the target group
+type ConflictPolicy string
+
+const (
+	// CONFLICT_SKIP Leave the existing record alone and do not restore the record
+	CONFLICT_SKIP ConflictPolicy = "skip"
+	// CONFLICT_OVERWRITE Replace the fields and secrets of the existing record by those of the backup
+	CONFLICT_OVERWRITE ConflictPolicy = "overwrite"
+	// CONFLICT_RENAME Restore the record under a free name, "<name> (restored)", "<name> (restored 2)", ...
+	CONFLICT_RENAME ConflictPolicy = "rename"
+)
+
+// RestoreAction What Restore did, or would do in a dry run, with a record of the backup
+type RestoreAction string
+
+const (
+	RESTORE_CREATED     RestoreAction = "created"
+	RESTORE_OVERWRITTEN RestoreAction = "overwritten"
+	RESTORE_RENAMED     RestoreAction = "renamed"
+	RESTORE_SKIPPED     RestoreAction = "skipped"
+)
+
+// backupHeader First line of an archive
+type backupHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// BackupRecord A vault record in an archive, including its secrets
+type BackupRecord struct {
+	GroupUUID     string                    `json:"groupUuid"`
+	GroupName     string                    `json:"groupName"`
+	UUID          string                    `json:"uuid"`
+	Name          string                    `json:"name"`
+	URL           string                    `json:"url,omitempty"`
+	Username      string                    `json:"username,omitempty"`
+	Color         string                    `json:"color,omitempty"`
+	Filename      string                    `json:"filename,omitempty"`
+	EndDate       string                    `json:"endDate,omitempty"`
+	WarningPeriod model.RecordWarningPeriod `json:"warningPeriod,omitempty"`
+	Password      *string                   `json:"password,omitempty"`
+	Totp          *string                   `json:"totp,omitempty"`
+	File          []byte                    `json:"file,omitempty"`
+	Comment       *string                   `json:"comment,omitempty"`
+}
+
+// newBackupRecord Return record of group, retrieved with its secrets, as it is stored in an archive
+func newBackupRecord(group *model.Group, record *model.VaultRecord) BackupRecord {
+	entry := BackupRecord{
+		GroupUUID:     group.UUID,
+		GroupName:     group.Name,
+		UUID:          record.UUID,
+		Name:          record.Name,
+		URL:           record.URL,
+		Username:      record.Username,
+		Color:         record.Color,
+		Filename:      record.Filename,
+		WarningPeriod: record.WarningPeriod,
+	}
+	if !record.EndDate.IsZero() {
+		entry.EndDate = record.EndDate.Format("2006-01-02")
+	}
+	if secret := record.AdditionalObjects.Secret; secret != nil {
+		entry.Password, entry.Totp, entry.Comment = secret.Password, secret.Totp, secret.Comment
+		if secret.File != nil {
+			entry.File = *secret.File
+		}
+	}
+	return entry
+}
+
+// apply Set the fields and secrets of the backed up record on record
+func (b *BackupRecord) apply(record *model.VaultRecord) error {
+	record.URL, record.Username, record.Color, record.Filename = b.URL, b.Username, b.Color, b.Filename
+	record.WarningPeriod = b.WarningPeriod
+	record.EndDate = time.Time{}
+	if b.EndDate != "" {
+		endDate, err := time.Parse("2006-01-02", b.EndDate)
+		if err != nil {
+			return fmt.Errorf("invalid end date of VaultRecord %q in backup: %w", b.UUID, err)
+		}
+		record.EndDate = endDate
+	}
+
+	secret := &model.VaultRecordSecretAdditionalObject{DType: "vault.VaultRecordSecrets", Password: b.Password, Totp: b.Totp, Comment: b.Comment}
+	if b.File != nil {
+		file := b.File
+		secret.File = &file
+	}
+	if record.AdditionalObjects == nil {
+		record.AdditionalObjects = &model.VaultRecordAdditionalObjects{}
+	}
+	record.AdditionalObjects.Secret = secret
+	return nil
+}
+
+// Backup Write every record of groups, including secrets, file attachments, EndDate and WarningPeriod, to w as an
+// archive encrypted to recipients, e.g. age.X25519Recipient or agessh recipients. Records are streamed one at a time.
+// The archive is only complete when Backup returns without error, a truncated archive fails to decrypt.
+func (s *VaultService) Backup(ctx context.Context, groups []model.Group, w io.Writer, recipients []age.Recipient) (count int, err error) {
+	if len(recipients) == 0 {
+		return 0, errors.New("a backup needs at least one recipient")
+	}
+
+	encrypted, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return 0, err
+	}
+	buffered := bufio.NewWriter(encrypted)
+	encoder := json.NewEncoder(buffered)
+
+	if err = encoder.Encode(backupHeader{Format: BackupFormat, Version: BackupVersion, CreatedAt: time.Now().UTC()}); err != nil {
+		return 0, err
+	}
+
+	additional := &model.VaultRecordAdditionalQueryParams{Secret: true}
+	for i := range groups {
+		group := &groups[i]
+		for record, err := range s.All(ctx, group, nil) {
+			if err != nil {
+				return count, err
+			}
+			// Lists leave out secrets, so every record is retrieved again
+			full, err := s.GetBySelfContext(ctx, &record, additional)
+			if err != nil {
+				return count, err
+			}
+			if full.AdditionalObjects == nil || full.AdditionalObjects.Secret == nil {
+				return count, fmt.Errorf("VaultRecord %q was returned without its secrets", record.UUID)
+			}
+			if err = encoder.Encode(newBackupRecord(group, full)); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+
+	if err = buffered.Flush(); err != nil {
+		return count, err
+	}
+	return count, encrypted.Close()
+}
+
+// ReadBackup Decrypt an archive of VaultService.Backup with identities and iterate its records
+func ReadBackup(r io.Reader, identities []age.Identity) (iter.Seq2[BackupRecord, error], error) {
+	decrypted, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bufio.NewReader(decrypted))
+
+	header := backupHeader{}
+	if err := decoder.Decode(&header); err != nil {
+		return nil, fmt.Errorf("could not read backup header: %w", err)
+	}
+	if header.Format != BackupFormat {
+		return nil, fmt.Errorf("not a vault backup: format %q", header.Format)
+	}
+	if header.Version < 1 || header.Version > BackupVersion {
+		return nil, fmt.Errorf("unsupported vault backup version %d, this version reads up to %d", header.Version, BackupVersion)
+	}
+
+	return func(yield func(BackupRecord, error) bool) {
+		for {
+			record := BackupRecord{}
+			err := decoder.Decode(&record)
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(BackupRecord{}, fmt.Errorf("could not read backup: %w", err))
+				return
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}, nil
+}
+
+// RestoreOption Configures VaultService.Restore
+type RestoreOption func(*restoreOptions)
+
+type restoreOptions struct {
+	dryRun    bool
+	conflicts ConflictPolicy
+	groupUUID string
+}
+
+// WithDryRun Report what Restore would do without changing anything
+func WithDryRun() RestoreOption {
+	return func(o *restoreOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithConflictPolicy Set what happens to records whose name is taken in the target group, defaults to CONFLICT_SKIP
+func WithConflictPolicy(policy ConflictPolicy) RestoreOption {
+	return func(o *restoreOptions) {
+		o.conflicts = policy
+	}
+}
+
+// WithSourceGroup Only restore the records backed up from the group with groupUUID
+func WithSourceGroup(groupUUID string) RestoreOption {
+	return func(o *restoreOptions) {
+		o.groupUUID = groupUUID
+	}
+}
+
+// RestoreResult What happened to a record of the backup, Name is the name of the record in the target group
+type RestoreResult struct {
+	UUID   string
+	Name   string
+	Action RestoreAction
+}
+
+// Restore Recreate the records of an archive of Backup in target, decrypting it with identities. Records are matched to
+// those of target by name, a taken name is handled according to WithConflictPolicy. Restored records get new uuids.
+// The results list every record read, also when Restore stops on an error.
+func (s *VaultService) Restore(ctx context.Context, r io.Reader, identities []age.Identity, target *model.Group, opts ...RestoreOption) (results []RestoreResult, err error) {
+	options := &restoreOptions{conflicts: CONFLICT_SKIP}
+	for _, opt := range opts {
+		opt(options)
+	}
+	switch options.conflicts {
+	case CONFLICT_SKIP, CONFLICT_OVERWRITE, CONFLICT_RENAME:
+	default:
+		return nil, fmt.Errorf("unknown conflict policy %q", options.conflicts)
+	}
+
+	records, err := ReadBackup(r, identities)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]*model.VaultRecord)
+	for record, err := range s.All(ctx, target, nil) {
+		if err != nil {
+			return nil, err
+		}
+		existing[record.Name] = &record
+	}
+
+	for entry, err := range records {
+		if err != nil {
+			return results, err
+		}
+		if options.groupUUID != "" && entry.GroupUUID != options.groupUUID {
+			continue
+		}
+
+		result := RestoreResult{UUID: entry.UUID, Name: entry.Name, Action: RESTORE_CREATED}
+		record := model.NewVaultRecord(entry.Name, &model.VaultRecordSecretAdditionalObject{})
+		if current, taken := existing[entry.Name]; taken {
+			switch options.conflicts {
+			case CONFLICT_SKIP:
+				result.Action = RESTORE_SKIPPED
+			case CONFLICT_OVERWRITE:
+				result.Action = RESTORE_OVERWRITTEN
+				overwritten := *current
+				record = &overwritten
+			case CONFLICT_RENAME:
+				result.Action = RESTORE_RENAMED
+				result.Name = freeName(existing, entry.Name)
+				record.Name = result.Name
+			}
+		}
+		if result.Action == RESTORE_SKIPPED {
+			results = append(results, result)
+			continue
+		}
+
+		if err = entry.apply(record); err != nil {
+			return results, err
+		}
+		if !options.dryRun {
+			if result.Action == RESTORE_OVERWRITTEN {
+				record, err = s.UpdateContext(ctx, target, record)
+			} else {
+				record, err = s.CreateContext(ctx, target, record)
+			}
+			if err != nil {
+				return results, err
+			}
+		}
+		existing[result.Name] = record
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// freeName Return "<name> (restored)", or "<name> (restored n)" when that is taken too
+func freeName(existing map[string]*model.VaultRecord, name string) string {
+	candidate := name + " (restored)"
+	for n := 2; ; n++ {
+		if _, taken := existing[candidate]; !taken {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (restored %d)", name, n)
+	}
+}
@@ -16,8 +16,9 @@
 package keyhub
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"iter"
 	"strconv"
 
 	"github.com/dghubble/sling"
@@ -38,12 +39,17 @@ func newClientApplicationService(sling *sling.Sling) *ClientApplicationService {
 
 // Create a new client application in Keyhub
 func (s *ClientApplicationService) Create(client *model.ClientApplication) (result *model.ClientApplication, err error) {
+	return s.CreateContext(context.Background(), client)
+}
+
+// CreateContext Create a new client application in Keyhub
+func (s *ClientApplicationService) CreateContext(ctx context.Context, client *model.ClientApplication) (result *model.ClientApplication, err error) {
 	clients := new(model.ClientList)
 	results := new(model.ClientList)
 	errorReport := new(model.ErrorReport)
 	clients.Items = append(clients.Items, *client)
 
-	_, err = s.sling.New().Post("").BodyProvider(khJsonBodyProvider{payload: clients}).Receive(results, errorReport)
+	_, err = receive(ctx, s.sling.New().Post("").BodyProvider(khJsonBodyProvider{payload: clients}), results, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not create ClientApplication.")
 	}
@@ -59,40 +65,33 @@ func (s *ClientApplicationService) Create(client *model.ClientApplication) (resu
 }
 
 // List all available clients.
-func (s *ClientApplicationService) List() (clients []model.ClientApplication, err error) {
-	searchRange := model.NewRange()
-
-	var response *http.Response
-
-	for ok := true; ok; ok = searchRange.NextPage() {
-
-		errorReport := new(model.ErrorReport)
-		results := new(model.ClientList)
-		response, err = s.sling.New().Get("").Add(searchRange.GetRequestRangeHeader()).Add(searchRange.GetRequestModeHeader()).Receive(results, errorReport)
-		searchRange.ParseResponse(response)
-
-		if errorReport.Code > 0 {
-			err = errorReport.Wrap("Could not get ClientApplications.")
-		}
-		if err == nil {
-			if len(results.Items) > 0 {
-				clients = append(clients, results.Items...)
-			}
-		}
+func (s *ClientApplicationService) List(opts ...ListOption) (clients []model.ClientApplication, err error) {
+	return s.ListContext(context.Background(), opts...)
+}
 
-	}
-	return
+// ListContext List all available clients, no further pages are fetched once ctx is done
+func (s *ClientApplicationService) ListContext(ctx context.Context, opts ...ListOption) (clients []model.ClientApplication, err error) {
+	return collect(s.All(ctx, nil, opts...))
+}
 
+// All Iterate all clients matching query, pages are fetched while ranging over the clients
+func (s *ClientApplicationService) All(ctx context.Context, query *model.ClientQueryParams, opts ...ListOption) iter.Seq2[model.ClientApplication, error] {
+	return paginate[model.ClientApplication](ctx, s.sling.New().Get("").QueryStruct(query), opts, "Could not get ClientApplications.")
 }
 
 // GetByUUID Retrieve a client by uuid
 func (s *ClientApplicationService) GetByUUID(uuid uuid.UUID) (result *model.ClientApplication, err error) {
+	return s.GetByUUIDContext(context.Background(), uuid)
+}
+
+// GetByUUIDContext Retrieve a client by uuid
+func (s *ClientApplicationService) GetByUUIDContext(ctx context.Context, uuid uuid.UUID) (result *model.ClientApplication, err error) {
 	al := new(model.ClientList)
 	errorReport := new(model.ErrorReport)
 
 	params := &model.ClientQueryParams{UUID: uuid.String()}
 	params.Additional = []string{"secret", "audit"}
-	_, err = s.sling.New().Get("").QueryStruct(params).Receive(al, errorReport)
+	_, err = receive(ctx, s.sling.New().Get("").QueryStruct(params), al, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get ClientApplication %q.", uuid)
 	}
@@ -100,7 +99,7 @@ func (s *ClientApplicationService) GetByUUID(uuid uuid.UUID) (result *model.Clie
 		if len(al.Items) > 0 {
 			result = &al.Items[0]
 		} else {
-			err = fmt.Errorf("ClientApplication %q not found", uuid.String())
+			err = fmt.Errorf("ClientApplication %q %w", uuid.String(), ErrNotFound)
 		}
 	}
 
@@ -109,17 +108,22 @@ func (s *ClientApplicationService) GetByUUID(uuid uuid.UUID) (result *model.Clie
 
 // GetById Retrieve a client by keyhub id
 func (s *ClientApplicationService) GetById(id int64) (result *model.ClientApplication, err error) {
+	return s.GetByIdContext(context.Background(), id)
+}
+
+// GetByIdContext Retrieve a client by keyhub id
+func (s *ClientApplicationService) GetByIdContext(ctx context.Context, id int64) (result *model.ClientApplication, err error) {
 	al := new(model.ClientApplication)
 	errorReport := new(model.ErrorReport)
 	idString := strconv.FormatInt(id, 10)
 
-	_, err = s.sling.New().Get(idString).Receive(al, errorReport)
+	_, err = receive(ctx, s.sling.New().Get(idString), al, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get ClientApplication %q.", idString)
 		return
 	}
 	if err == nil && al == nil {
-		err = fmt.Errorf("ClientApplication %q not found", idString)
+		err = fmt.Errorf("ClientApplication %q %w", idString, ErrNotFound)
 		return
 	}
 
@@ -0,0 +1,77 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Command docker-credential-keyhub is a docker credential helper serving registry credentials from KeyHub vault
+// records, so registry passwords no longer end up in ~/.docker/config.json:
+//
+//	{"credsStore": "keyhub"}
+//
+// Docker passes no flags to a helper, it is configured through the environment: KEYHUB_ISSUER, KEYHUB_CLIENT_ID and
+// KEYHUB_CLIENT_SECRET for the client, and KEYHUB_DOCKER_GROUP with the uuid of the group docker login stores in.
+// KEYHUB_DOCKER_COLOR limits the registry records to those of a color, e.g. BLUE, list only reports records when it is set.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub"
+	"github.com/topicuskeyhub/go-keyhub/dockercredential"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s get|store|erase|list\n", os.Args[0])
+		os.Exit(2)
+	}
+	action := os.Args[1]
+	if action == "version" {
+		fmt.Println("docker-credential-keyhub (go-keyhub)")
+		return
+	}
+
+	issuer := os.Getenv("KEYHUB_ISSUER")
+	if issuer == "" {
+		log.Fatal("ERROR KEYHUB_ISSUER is not set")
+	}
+	client, err := keyhub.New(issuer, keyhub.WithClientCredentials(os.Getenv("KEYHUB_CLIENT_ID"), os.Getenv("KEYHUB_CLIENT_SECRET")))
+	if err != nil {
+		log.Fatalf("ERROR %s", err)
+	}
+
+	ctx := context.Background()
+	helper := &dockercredential.Helper{Vaults: client.Vaults}
+	helper.Query.Color = strings.ToUpper(os.Getenv("KEYHUB_DOCKER_COLOR"))
+	if group := os.Getenv("KEYHUB_DOCKER_GROUP"); group != "" && (action == "store" || action == "erase") {
+		groupUUID, err := uuid.Parse(group)
+		if err != nil {
+			log.Fatalf("ERROR invalid KEYHUB_DOCKER_GROUP: %s", err)
+		}
+		helper.Group, err = client.Groups.GetByUUIDContext(ctx, groupUUID)
+		if err != nil {
+			log.Fatalf("ERROR %s", err)
+		}
+	}
+
+	if err := helper.Serve(ctx, action, os.Stdin, os.Stdout); err != nil {
+		// Docker reads the error from stdout
+		fmt.Fprintln(os.Stdout, err)
+		os.Exit(1)
+	}
+}
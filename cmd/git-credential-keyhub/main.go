@@ -0,0 +1,68 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Command git-credential-keyhub is a git credential helper serving passwords from KeyHub vault records.
+//
+//	git config --global credential.helper "keyhub -g <group uuid>"
+//
+// Records match on the host and path of their URL and on their username. With -g, passwords git reports as working
+// are stored in that group. The client secret is best passed through KEYHUB_CLIENT_SECRET.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/topicuskeyhub/go-keyhub"
+	"github.com/topicuskeyhub/go-keyhub/gitcredential"
+)
+
+func main() {
+	issuer := flag.String("i", os.Getenv("KEYHUB_ISSUER"), "Specify issuer, defaults to $KEYHUB_ISSUER")
+	clientid := flag.String("ci", os.Getenv("KEYHUB_CLIENT_ID"), "Specify client id, defaults to $KEYHUB_CLIENT_ID")
+	clientsecret := flag.String("cs", os.Getenv("KEYHUB_CLIENT_SECRET"), "Specify client secret, defaults to $KEYHUB_CLIENT_SECRET")
+	group := flag.String("g", "", "Store credentials in the group with this uuid, nothing is stored when empty")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] get|store|erase\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || *issuer == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client, err := keyhub.New(*issuer, keyhub.WithClientCredentials(*clientid, *clientsecret))
+	if err != nil {
+		log.Fatalf("ERROR %s", err)
+	}
+
+	ctx := context.Background()
+	helper := &gitcredential.Helper{Vaults: client.Vaults}
+	if *group != "" && flag.Arg(0) == "store" {
+		helper.Group, err = gitcredential.GroupByUUID(ctx, client.Groups, *group)
+		if err != nil {
+			log.Fatalf("ERROR %s", err)
+		}
+	}
+
+	if err := helper.Serve(ctx, flag.Arg(0), os.Stdin, os.Stdout); err != nil {
+		log.Fatalf("ERROR %s", err)
+	}
+}
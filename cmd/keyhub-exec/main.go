@@ -0,0 +1,96 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Command keyhub-exec runs a command with secrets from KeyHub vault records in its environment.
+//
+//	keyhub-exec -e DB_USER=<record uuid>#username -e DB_PASSWORD=<record uuid> -- ./server --port 8080
+//
+// The client secret is best passed through KEYHUB_CLIENT_SECRET, so it does not show up in the process list.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/topicuskeyhub/go-keyhub"
+	"github.com/topicuskeyhub/go-keyhub/envexec"
+)
+
+// envFlag Collects the repeated -e flags
+type envFlag map[string]envexec.Reference
+
+func (f envFlag) String() string {
+	return fmt.Sprint(len(f), " variables")
+}
+
+func (f envFlag) Set(value string) error {
+	name, reference, found := strings.Cut(value, "=")
+	if !found || name == "" {
+		return errors.New("expected NAME=<record uuid>[#field]")
+	}
+	ref, err := envexec.ParseReference(reference)
+	if err != nil {
+		return err
+	}
+	f[name] = ref
+	return nil
+}
+
+func main() {
+	env := envFlag{}
+	runner := &envexec.Runner{Env: env, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+
+	issuer := flag.String("i", os.Getenv("KEYHUB_ISSUER"), "Specify issuer, defaults to $KEYHUB_ISSUER")
+	clientid := flag.String("ci", os.Getenv("KEYHUB_CLIENT_ID"), "Specify client id, defaults to $KEYHUB_CLIENT_ID")
+	clientsecret := flag.String("cs", os.Getenv("KEYHUB_CLIENT_SECRET"), "Specify client secret, defaults to $KEYHUB_CLIENT_SECRET")
+	verbose := flag.Bool("v", false, "Log restarts")
+	flag.Var(env, "e", "Set environment variable NAME to a field (password, username, url, totp or file) of a record: NAME=<record uuid>[#field]")
+	flag.DurationVar(&runner.Watch, "w", 0, "Restart the command when a record changed, checking at this interval")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] -- command [args...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || *issuer == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *verbose {
+		runner.Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+
+	client, err := keyhub.New(*issuer, keyhub.WithClientCredentials(*clientid, *clientsecret))
+	if err != nil {
+		log.Fatalf("ERROR %s", err)
+	}
+	runner.Vaults = client.Vaults
+
+	err = runner.Run(context.Background(), flag.Arg(0), flag.Args()[1:]...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		log.Fatalf("ERROR %s", err)
+	}
+}
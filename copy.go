@@ -0,0 +1,138 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package keyhub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+// CopyVerificationError The copy of a record, re-read from the target group, differs from the original in Field.
+// The copy is left in place for inspection and the original is never deleted. Secret values are not part of the error.
+type CopyVerificationError struct {
+	UUID     string
+	CopyUUID string
+	Field    string
+}
+
+func (e *CopyVerificationError) Error() string {
+	return fmt.Sprintf("copy %q of VaultRecord %q differs in %s", e.CopyUUID, e.UUID, e.Field)
+}
+
+// Copy Recreate the vault record with uuid of src in dst with all its fields, secrets and file attachment. The copy is
+// re-read from dst and compared to the original, a difference is returned as *CopyVerificationError.
+// The copy gets a new uuid and its own audit trail.
+func (s *VaultService) Copy(ctx context.Context, src *model.Group, dst *model.Group, uuid uuid.UUID) (result *model.VaultRecord, err error) {
+	additional := &model.VaultRecordAdditionalQueryParams{Secret: true}
+
+	original, err := s.GetByUUIDContext(ctx, src, uuid, additional)
+	if err != nil {
+		return nil, err
+	}
+	if original.AdditionalObjects == nil || original.AdditionalObjects.Secret == nil {
+		return nil, fmt.Errorf("VaultRecord %q was returned without its secrets", original.UUID)
+	}
+
+	secret := *original.AdditionalObjects.Secret
+	record := model.NewVaultRecord(original.Name, &secret)
+	record.URL, record.Username, record.Color, record.Filename = original.URL, original.Username, original.Color, original.Filename
+	record.Types, record.EndDate, record.WarningPeriod = original.Types, original.EndDate, original.WarningPeriod
+
+	created, err := s.CreateContext(ctx, dst, record)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = s.GetBySelfContext(ctx, created, additional)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify copy %q of VaultRecord %q: %w", created.UUID, original.UUID, err)
+	}
+	if field := differentField(original, result); field != "" {
+		return nil, &CopyVerificationError{UUID: original.UUID, CopyUUID: result.UUID, Field: field}
+	}
+
+	return result, nil
+}
+
+// Move Copy the vault record with uuid of src to dst like Copy, and delete the original once the copy is verified
+func (s *VaultService) Move(ctx context.Context, src *model.Group, dst *model.Group, uuid uuid.UUID) (result *model.VaultRecord, err error) {
+	result, err = s.Copy(ctx, src, dst, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	// The copy exists, so finish the move even when ctx is cancelled
+	if err = s.DeleteByUUIDContext(context.WithoutCancel(ctx), src, uuid); err != nil {
+		return result, fmt.Errorf("VaultRecord %q was copied to %q but the original could not be deleted: %w", uuid.String(), result.UUID, err)
+	}
+
+	return result, nil
+}
+
+// differentField Return the name of the first field in which copy differs from original, or an empty string
+func differentField(original *model.VaultRecord, copy *model.VaultRecord) string {
+	switch {
+	case copy.Name != original.Name:
+		return "name"
+	case copy.URL != original.URL:
+		return "url"
+	case copy.Username != original.Username:
+		return "username"
+	case copy.Color != original.Color:
+		return "color"
+	case copy.Filename != original.Filename:
+		return "filename"
+	case !slices.Equal(copy.Types, original.Types):
+		return "types"
+	case !copy.EndDate.Equal(original.EndDate):
+		return "endDate"
+	case copy.WarningPeriod != original.WarningPeriod:
+		return "warningPeriod"
+	}
+
+	if copy.AdditionalObjects == nil || copy.AdditionalObjects.Secret == nil {
+		return "secret"
+	}
+	want, got := original.AdditionalObjects.Secret, copy.AdditionalObjects.Secret
+	switch {
+	case !equalValue(want.Password, got.Password):
+		return "password"
+	case !equalValue(want.Totp, got.Totp):
+		return "totp"
+	case !equalValue(want.Comment, got.Comment):
+		return "comment"
+	case want.File == nil && got.File != nil, want.File != nil && (got.File == nil || !bytes.Equal(*want.File, *got.File)):
+		return "file"
+	}
+	return ""
+}
+
+// equalValue Return true when a and b hold the same value, nil equals an empty string
+func equalValue(a *string, b *string) bool {
+	var left, right string
+	if a != nil {
+		left = *a
+	}
+	if b != nil {
+		right = *b
+	}
+	return left == right
+}
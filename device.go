@@ -0,0 +1,219 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package keyhub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DeviceFlow Authenticates the user running the program with the OAuth2 device authorization flow (RFC 8628)
+type DeviceFlow struct {
+	// ClientID Client application the user signs in to, it must allow the device authorization grant
+	ClientID string
+	// Scopes Requested scopes, defaults to the scopes of the Client
+	Scopes []string
+	// Output Receives the verification uri and user code, defaults to os.Stderr
+	Output io.Writer
+	// CacheFile Path of the file the token is cached in between runs, no caching when empty.
+	// The file is written with mode 0600 and can also be read by NewFileTokenSource.
+	CacheFile string
+}
+
+// cachedToken File format of DeviceFlow.CacheFile, a token endpoint response with an absolute expiry
+type cachedToken struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry"`
+	VaultSession string    `json:"vaultSession,omitempty"`
+}
+
+// WithDeviceFlow Authenticate the user running the program through the device authorization flow.
+// The user signs in when the Client is created, unless a cached token can be used or refreshed. Waiting for the user
+// ends when the device code expires.
+// Only the last of the options configuring credentials takes effect.
+func WithDeviceFlow(flow *DeviceFlow) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.clientID = flow.ClientID
+		cfg.tokenSourceFunc = func(ctx context.Context, endpoint oauth2.Endpoint) (oauth2.TokenSource, error) {
+			if len(flow.Scopes) == 0 {
+				flowWithScopes := *flow
+				flowWithScopes.Scopes = cfg.scopes
+				return flowWithScopes.TokenSource(ctx, endpoint)
+			}
+			return flow.TokenSource(ctx, endpoint)
+		}
+	}
+}
+
+// TokenSource Return a refreshing token source for the user, signing in through the device flow when no usable token is cached.
+// Waiting for the user to sign in stops when ctx is done, refreshing the token afterwards does not depend on ctx.
+func (f *DeviceFlow) TokenSource(ctx context.Context, endpoint oauth2.Endpoint) (oauth2.TokenSource, error) {
+	if endpoint.DeviceAuthURL == "" {
+		return nil, fmt.Errorf("issuer does not support the device authorization flow")
+	}
+
+	conf := &oauth2.Config{
+		ClientID: f.ClientID,
+		Endpoint: endpoint,
+		Scopes:   f.Scopes,
+	}
+	refreshCtx := context.WithoutCancel(ctx)
+
+	if token, err := f.cachedToken(refreshCtx, conf); err == nil {
+		return f.cachingTokenSource(conf.TokenSource(refreshCtx, token), token), nil
+	}
+
+	token, err := f.login(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	if err = f.store(token); err != nil {
+		return nil, err
+	}
+
+	return f.cachingTokenSource(conf.TokenSource(refreshCtx, token), token), nil
+}
+
+// login Request a device code, ask the user to sign in and poll for the token
+func (f *DeviceFlow) login(ctx context.Context, conf *oauth2.Config) (*oauth2.Token, error) {
+	deviceAuth, err := conf.DeviceAuth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not start device authorization: %w", err)
+	}
+
+	output := f.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	if deviceAuth.VerificationURIComplete != "" {
+		fmt.Fprintf(output, "To sign in to KeyHub, open %s\nor open %s and enter code %s\n", deviceAuth.VerificationURIComplete, deviceAuth.VerificationURI, deviceAuth.UserCode)
+	} else {
+		fmt.Fprintf(output, "To sign in to KeyHub, open %s and enter code %s\n", deviceAuth.VerificationURI, deviceAuth.UserCode)
+	}
+
+	token, err := conf.DeviceAccessToken(ctx, deviceAuth)
+	if err != nil {
+		return nil, fmt.Errorf("device authorization failed: %w", err)
+	}
+	return token, nil
+}
+
+// cachedToken Read the token from the cache file, refreshing it when it has expired
+func (f *DeviceFlow) cachedToken(ctx context.Context, conf *oauth2.Config) (*oauth2.Token, error) {
+	if f.CacheFile == "" {
+		return nil, os.ErrNotExist
+	}
+
+	token, err := NewFileTokenSource(f.CacheFile).Token()
+	if err != nil {
+		return nil, err
+	}
+	if token.Valid() {
+		return token, nil
+	}
+	if token.RefreshToken == "" {
+		return nil, fmt.Errorf("cached token expired")
+	}
+
+	token, err = conf.TokenSource(ctx, token).Token()
+	if err != nil {
+		return nil, err
+	}
+	return token, f.store(token)
+}
+
+// store Write token to the cache file, replacing the previous file atomically
+func (f *DeviceFlow) store(token *oauth2.Token) error {
+	if f.CacheFile == "" {
+		return nil
+	}
+
+	cached := cachedToken{
+		AccessToken:  token.AccessToken,
+		TokenType:    token.TokenType,
+		RefreshToken: token.RefreshToken,
+		Expiry:       token.Expiry,
+	}
+	cached.VaultSession, _ = token.Extra("vaultSession").(string)
+
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(f.CacheFile)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.CacheFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// CreateTemp already uses mode 0600, chmod guards against a permissive umask on other platforms
+	if err = tmp.Chmod(0600); err == nil {
+		_, err = tmp.Write(data)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.CacheFile)
+}
+
+// cachingTokenSource Wrap source so every refreshed token is written to the cache file
+func (f *DeviceFlow) cachingTokenSource(source oauth2.TokenSource, current *oauth2.Token) oauth2.TokenSource {
+	if f.CacheFile == "" {
+		return source
+	}
+	return &cachingTokenSource{flow: f, source: source, current: current.AccessToken}
+}
+
+type cachingTokenSource struct {
+	flow   *DeviceFlow
+	source oauth2.TokenSource
+
+	mu      sync.Mutex
+	current string
+}
+
+func (s *cachingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A token that could not be cached is still usable, storing it is retried on the next call
+	if token.AccessToken != s.current && s.flow.store(token) == nil {
+		s.current = token.AccessToken
+	}
+	return token, nil
+}
@@ -0,0 +1,314 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Package dockercredential Implements the docker credential helper protocol on top of KeyHub vault records.
+// A registry maps to the record whose URL has the registry host and a path that is a prefix of the registry path,
+// Docker Hub matches records for docker.io, index.docker.io and registry-1.docker.io alike.
+package dockercredential
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+// dockerHub Host Docker Hub credentials are stored for
+const dockerHub = "index.docker.io"
+
+// ErrCredentialsNotFound The message docker expects when a helper has no credentials for a registry
+var ErrCredentialsNotFound = errors.New("credentials not found in native keychain")
+
+// Credentials The credentials of a registry as exchanged with docker
+type Credentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// Vaults The part of keyhub.VaultService used by the helper
+type Vaults interface {
+	SearchForClient(ctx context.Context, query model.VaultRecordSearchQueryParams, opts ...keyhub.ListOption) iter.Seq2[model.VaultRecord, error]
+	GetBySelfContext(ctx context.Context, vaultRecord *model.VaultRecord, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error)
+	CreateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (*model.VaultRecord, error)
+	UpdateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (*model.VaultRecord, error)
+	DeleteByUUIDContext(ctx context.Context, group *model.Group, uuid uuid.UUID) error
+}
+
+// Helper A docker credential helper serving the vault records accessible by the client
+type Helper struct {
+	Vaults Vaults
+	// Group Group that store and erase work on, both fail when nil so registry secrets shared through KeyHub are
+	// never changed by a docker login or logout
+	Group *model.Group
+	// Query Narrows the records considered to be registry credentials, e.g. by Color. List reports nothing without it.
+	// AccessibleByClient, Url and Username are filled in by the helper, records created by store get its Color.
+	Query model.VaultRecordSearchQueryParams
+}
+
+// Serve Handle action get, store, erase or list reading the request from r and writing the answer to w as docker
+// expects. The caller reports a returned error by printing it to stdout and exiting with status 1.
+func (h *Helper) Serve(ctx context.Context, action string, r io.Reader, w io.Writer) error {
+	switch action {
+	case "get":
+		serverURL, err := readServerURL(r)
+		if err != nil {
+			return err
+		}
+		credentials, err := h.Get(ctx, serverURL)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(credentials)
+	case "store":
+		credentials := new(Credentials)
+		if err := json.NewDecoder(r).Decode(credentials); err != nil {
+			return fmt.Errorf("invalid credentials: %w", err)
+		}
+		return h.Store(ctx, credentials)
+	case "erase":
+		serverURL, err := readServerURL(r)
+		if err != nil {
+			return err
+		}
+		return h.Erase(ctx, serverURL)
+	case "list":
+		registries, err := h.List(ctx)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(registries)
+	}
+	return fmt.Errorf("unknown credential action %q", action)
+}
+
+// readServerURL Read the server url docker passes to get and erase
+func readServerURL(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	serverURL := strings.TrimSpace(string(data))
+	if serverURL == "" {
+		return "", errors.New("no server URL")
+	}
+	return serverURL, nil
+}
+
+// Get Return the credentials of the record matching serverURL with the longest URL path
+func (h *Helper) Get(ctx context.Context, serverURL string) (*Credentials, error) {
+	record, err := h.find(ctx, serverURL, "", false)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err = h.Vaults.GetBySelfContext(ctx, record, &model.VaultRecordAdditionalQueryParams{Secret: true})
+	if err != nil {
+		return nil, err
+	}
+	if record.Password() == nil || *record.Password() == "" {
+		return nil, ErrCredentialsNotFound
+	}
+
+	return &Credentials{ServerURL: serverURL, Username: record.Username, Secret: *record.Password()}, nil
+}
+
+// Store Update the password of the record in Group matching the registry and username, or create one
+func (h *Helper) Store(ctx context.Context, credentials *Credentials) error {
+	if h.Group == nil {
+		return errors.New("no group configured to store registry credentials in")
+	}
+	if credentials.ServerURL == "" || credentials.Secret == "" {
+		return errors.New("credentials without server URL or secret")
+	}
+
+	record, err := h.find(ctx, credentials.ServerURL, credentials.Username, true)
+	if err != nil && !errors.Is(err, ErrCredentialsNotFound) {
+		return err
+	}
+
+	if record != nil {
+		record, err = h.Vaults.GetBySelfContext(ctx, record, &model.VaultRecordAdditionalQueryParams{Secret: true})
+		if err != nil {
+			return err
+		}
+		if record.Password() != nil && *record.Password() == credentials.Secret {
+			return nil
+		}
+		if record.AdditionalObjects == nil {
+			record.AdditionalObjects = &model.VaultRecordAdditionalObjects{}
+		}
+		if record.AdditionalObjects.Secret == nil {
+			record.AdditionalObjects.Secret = &model.VaultRecordSecretAdditionalObject{DType: "vault.VaultRecordSecrets"}
+		}
+		record.AdditionalObjects.Secret.Password = &credentials.Secret
+		_, err = h.Vaults.UpdateContext(ctx, h.Group, record)
+		return err
+	}
+
+	host, path := registry(credentials.ServerURL)
+	record = model.NewVaultRecord(strings.TrimSuffix(host+"/"+path, "/"), &model.VaultRecordSecretAdditionalObject{Password: &credentials.Secret})
+	record.URL = credentials.ServerURL
+	record.Username = credentials.Username
+	record.Color = h.Query.Color
+	_, err = h.Vaults.CreateContext(ctx, h.Group, record)
+	return err
+}
+
+// Erase Delete the records in Group stored for exactly serverURL, records for a parent path are kept
+func (h *Helper) Erase(ctx context.Context, serverURL string) error {
+	if h.Group == nil {
+		return errors.New("no group configured to erase registry credentials from")
+	}
+
+	records, err := h.matching(ctx, serverURL, "", true)
+	if err != nil {
+		return err
+	}
+	_, path := registry(serverURL)
+	for _, record := range records {
+		if _, recordPath := registry(record.URL); recordPath != path {
+			continue
+		}
+		recordUUID, err := uuid.Parse(record.UUID)
+		if err != nil {
+			return fmt.Errorf("VaultRecord with invalid uuid %q: %w", record.UUID, err)
+		}
+		if err := h.Vaults.DeleteByUUIDContext(ctx, h.Group, recordUUID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List Return the usernames of the registry records by record URL. Without a Query nothing is listed, as every web
+// login accessible by the client would be reported as a registry otherwise.
+func (h *Helper) List(ctx context.Context) (map[string]string, error) {
+	registries := make(map[string]string)
+	if !h.narrowed() {
+		return registries, nil
+	}
+	for record, err := range h.Vaults.SearchForClient(ctx, h.Query) {
+		if err != nil {
+			return nil, err
+		}
+		if record.URL != "" {
+			registries[record.URL] = record.Username
+		}
+	}
+	return registries, nil
+}
+
+// narrowed Return true when Query narrows the records beyond the fields the helper fills in
+func (h *Helper) narrowed() bool {
+	query := h.Query
+	query.AccessibleByClient, query.Url, query.Username = "", "", ""
+	return !reflect.ValueOf(query).IsZero()
+}
+
+// find Return the record matching serverURL, and username when set, with the longest URL path. With inGroup only
+// records of Group are considered.
+func (h *Helper) find(ctx context.Context, serverURL string, username string, inGroup bool) (*model.VaultRecord, error) {
+	records, err := h.matching(ctx, serverURL, username, inGroup)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrCredentialsNotFound
+	}
+
+	var best *model.VaultRecord
+	bestLength := -1
+	for i := range records {
+		if _, path := registry(records[i].URL); len(path) > bestLength {
+			best, bestLength = &records[i], len(path)
+		}
+	}
+	return best, nil
+}
+
+// matching Return the records matching serverURL, and username when set. With inGroup only records of Group are
+// returned.
+func (h *Helper) matching(ctx context.Context, serverURL string, username string, inGroup bool) ([]model.VaultRecord, error) {
+	host, path := registry(serverURL)
+	if host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", serverURL)
+	}
+
+	query := h.Query
+	query.Url = host
+	if host == dockerHub {
+		// Finds records for docker.io and registry-1.docker.io too
+		query.Url = "docker.io"
+	}
+	query.Username = username
+
+	var records []model.VaultRecord
+	for record, err := range h.Vaults.SearchForClient(ctx, query) {
+		if err != nil {
+			return nil, err
+		}
+		if username != "" && record.Username != username {
+			continue
+		}
+		if inGroup && !h.inGroup(&record) {
+			continue
+		}
+		recordHost, recordPath := registry(record.URL)
+		if recordHost != host || recordPath != "" && path != recordPath && !strings.HasPrefix(path, recordPath+"/") {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// inGroup Return true when record belongs to Group
+func (h *Helper) inGroup(record *model.VaultRecord) bool {
+	if record.Self() == nil || h.Group.Self() == nil {
+		return false
+	}
+	return strings.HasPrefix(record.Self().Href, h.Group.Self().Href+"/")
+}
+
+// registry Return the lowercase host and the path of a registry url, which may lack a scheme
+func registry(serverURL string) (host string, path string) {
+	if !strings.Contains(serverURL, "://") {
+		serverURL = "//" + serverURL
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", ""
+	}
+
+	host = strings.ToLower(u.Host)
+	path = strings.Trim(u.Path, "/")
+	switch host {
+	case "docker.io", "registry-1.docker.io", dockerHub:
+		host = dockerHub
+		// Docker Hub is addressed as https://index.docker.io/v1/
+		path = strings.Trim(strings.TrimPrefix(path, "v1"), "/")
+	}
+	return host, path
+}
@@ -0,0 +1,242 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package dockercredential
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"iter"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+var _ Vaults = (*keyhub.VaultService)(nil)
+
+const groupHref = "https://keyhub.example.com/keyhub/rest/v1/group/1"
+
+// fakeVaults Serves records from memory, search only applies the url and color filters like KeyHub would
+type fakeVaults struct {
+	records []*model.VaultRecord
+	updated []*model.VaultRecord
+	created []*model.VaultRecord
+}
+
+func (f *fakeVaults) add(id int, group string, recordURL string, username string, password string) {
+	record := model.NewVaultRecord(recordURL, &model.VaultRecordSecretAdditionalObject{Password: &password})
+	record.UUID = uuid.NewSHA1(uuid.Nil, []byte{byte(id)}).String()
+	record.URL = recordURL
+	record.Username = username
+	record.Links = []model.Link{{Rel: "self", Href: group + "/vault/record/" + record.UUID}}
+	f.records = append(f.records, record)
+}
+
+func (f *fakeVaults) SearchForClient(ctx context.Context, query model.VaultRecordSearchQueryParams, opts ...keyhub.ListOption) iter.Seq2[model.VaultRecord, error] {
+	return func(yield func(model.VaultRecord, error) bool) {
+		for _, record := range f.records {
+			if !strings.Contains(strings.ToLower(record.URL), strings.ToLower(query.Url)) {
+				continue
+			}
+			if query.Color != "" && record.Color != query.Color {
+				continue
+			}
+			found := *record
+			found.AdditionalObjects = nil
+			if !yield(found, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (f *fakeVaults) GetBySelfContext(ctx context.Context, vaultRecord *model.VaultRecord, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error) {
+	for _, record := range f.records {
+		if record.Self().Href == vaultRecord.Self().Href {
+			found := *record
+			found.AdditionalObjects = nil
+			if record.AdditionalObjects != nil {
+				secret := *record.AdditionalObjects.Secret
+				found.AdditionalObjects = &model.VaultRecordAdditionalObjects{Secret: &secret}
+			}
+			return &found, nil
+		}
+	}
+	return nil, keyhub.ErrNotFound
+}
+
+func (f *fakeVaults) CreateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (*model.VaultRecord, error) {
+	f.created = append(f.created, vaultRecord)
+	return vaultRecord, nil
+}
+
+func (f *fakeVaults) UpdateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (*model.VaultRecord, error) {
+	f.updated = append(f.updated, vaultRecord)
+	return vaultRecord, nil
+}
+
+func (f *fakeVaults) DeleteByUUIDContext(ctx context.Context, group *model.Group, id uuid.UUID) error {
+	for i, record := range f.records {
+		if record.UUID == id.String() {
+			f.records = append(f.records[:i], f.records[i+1:]...)
+			return nil
+		}
+	}
+	return keyhub.ErrNotFound
+}
+
+func newTestHelper() (*Helper, *fakeVaults) {
+	vaults := &fakeVaults{}
+	vaults.add(1, groupHref, "registry.example.com", "ci", "registry")
+	vaults.add(2, groupHref, "https://registry.example.com/team", "ci", "team")
+	vaults.add(3, groupHref, "docker.io", "hub", "hub")
+	vaults.add(4, "https://keyhub.example.com/keyhub/rest/v1/group/2", "registry.example.com:5000", "shared", "shared")
+
+	group := model.NewEmptyGroup("registries")
+	group.Links = []model.Link{{Rel: "self", Href: groupHref}}
+	return &Helper{Vaults: vaults, Group: group}, vaults
+}
+
+func TestGet(t *testing.T) {
+	helper, _ := newTestHelper()
+
+	cases := []struct {
+		serverURL string
+		want      string
+	}{
+		{"registry.example.com", `{"ServerURL":"registry.example.com","Username":"ci","Secret":"registry"}`},
+		{"https://Registry.example.com/team/app", `{"ServerURL":"https://Registry.example.com/team/app","Username":"ci","Secret":"team"}`},
+		{"https://index.docker.io/v1/", `{"ServerURL":"https://index.docker.io/v1/","Username":"hub","Secret":"hub"}`},
+		{"registry.example.com:5000", `{"ServerURL":"registry.example.com:5000","Username":"shared","Secret":"shared"}`},
+	}
+
+	for _, c := range cases {
+		var out bytes.Buffer
+		if err := helper.Serve(context.Background(), "get", strings.NewReader(c.serverURL+"\n"), &out); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if strings.TrimSpace(out.String()) != c.want {
+			t.Errorf("Result differs for %s, want `%s`, got `%s`", c.serverURL, c.want, out.String())
+		}
+	}
+
+	err := helper.Serve(context.Background(), "get", strings.NewReader("ghcr.io"), &bytes.Buffer{})
+	if !errors.Is(err, ErrCredentialsNotFound) {
+		t.Errorf("Result differs, want ErrCredentialsNotFound, got %v", err)
+	}
+}
+
+func TestStoreAndErase(t *testing.T) {
+	helper, vaults := newTestHelper()
+
+	input := `{"ServerURL":"https://registry.example.com/team","Username":"ci","Secret":"rotated"}`
+	if err := helper.Serve(context.Background(), "store", strings.NewReader(input), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(vaults.updated) != 1 || vaults.updated[0].URL != "https://registry.example.com/team" || *vaults.updated[0].Password() != "rotated" {
+		t.Errorf("Result differs, want the team record updated, got `%+v`", vaults.updated)
+	}
+
+	// The record for port 5000 belongs to another group and is left alone
+	input = `{"ServerURL":"registry.example.com:5000","Username":"shared","Secret":"mine"}`
+	if err := helper.Serve(context.Background(), "store", strings.NewReader(input), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(vaults.created) != 1 || vaults.created[0].Name != "registry.example.com:5000" || *vaults.created[0].Password() != "mine" {
+		t.Errorf("Result differs, want a record created, got `%+v`", vaults.created)
+	}
+
+	if err := helper.Serve(context.Background(), "erase", strings.NewReader("registry.example.com:5000"), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(vaults.records) != 4 {
+		t.Errorf("Result differs, want the record of the other group kept, got %d records", len(vaults.records))
+	}
+	if err := helper.Serve(context.Background(), "erase", strings.NewReader("https://registry.example.com/team/"), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(vaults.records) != 3 {
+		t.Errorf("Result differs, want 3 records left, got %d", len(vaults.records))
+	}
+	if _, err := helper.Get(context.Background(), "registry.example.com/team"); err != nil {
+		t.Errorf("Result differs, want the host record kept, got %v", err)
+	}
+
+	helper.Group = nil
+	if err := helper.Serve(context.Background(), "store", strings.NewReader(input), &bytes.Buffer{}); err == nil {
+		t.Errorf("Expected an error storing without group")
+	}
+}
+
+func TestWithoutSecret(t *testing.T) {
+	vaults := &fakeVaults{}
+	vaults.add(1, groupHref, "registry.example.com", "ci", "")
+	vaults.records[0].AdditionalObjects = nil
+	group := model.NewEmptyGroup("registries")
+	group.Links = []model.Link{{Rel: "self", Href: groupHref}}
+	helper := &Helper{Vaults: vaults, Group: group}
+
+	if _, err := helper.Get(context.Background(), "registry.example.com"); !errors.Is(err, ErrCredentialsNotFound) {
+		t.Errorf("Result differs, want ErrCredentialsNotFound, got %v", err)
+	}
+
+	input := `{"ServerURL":"registry.example.com","Username":"ci","Secret":"first"}`
+	if err := helper.Serve(context.Background(), "store", strings.NewReader(input), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(vaults.updated) != 1 || *vaults.updated[0].Password() != "first" {
+		t.Errorf("Result differs, want the record updated, got `%+v`", vaults.updated)
+	}
+}
+
+func TestList(t *testing.T) {
+	helper, _ := newTestHelper()
+
+	var out bytes.Buffer
+	if err := helper.Serve(context.Background(), "list", strings.NewReader(""), &out); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if strings.TrimSpace(out.String()) != "{}" {
+		t.Errorf("Result differs, want nothing listed without a query, got `%s`", out.String())
+	}
+
+	helper, vaults := newTestHelper()
+	for _, record := range vaults.records[:3] {
+		record.Color = model.VaultRecordColorBlue
+	}
+	vaults.add(5, groupHref, "https://intranet.example.com", "jdoe", "web")
+	helper.Query.Color = model.VaultRecordColorBlue
+
+	out.Reset()
+	if err := helper.Serve(context.Background(), "list", strings.NewReader(""), &out); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	want := `{"docker.io":"hub","https://registry.example.com/team":"ci","registry.example.com":"ci"}`
+	if strings.TrimSpace(out.String()) != want {
+		t.Errorf("Result differs, want `%s`, got `%s`", want, out.String())
+	}
+
+	input := `{"ServerURL":"ghcr.io","Username":"ci","Secret":"token"}`
+	if err := helper.Serve(context.Background(), "store", strings.NewReader(input), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(vaults.created) != 1 || vaults.created[0].Color != model.VaultRecordColorBlue {
+		t.Errorf("Result differs, want a blue record created, got `%+v`", vaults.created)
+	}
+}
@@ -0,0 +1,220 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Package envexec Starts a process with secrets from KeyHub vault records in its environment.
+// Secrets are only passed through the environment of the child process, they are never written to disk.
+package envexec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub/model"
+	"github.com/topicuskeyhub/go-keyhub/secretref"
+)
+
+const (
+	FieldPassword = secretref.FieldPassword
+	FieldUsername = secretref.FieldUsername
+	FieldURL      = secretref.FieldURL
+	FieldTOTP     = secretref.FieldTOTP
+	FieldFile     = secretref.FieldFile
+)
+
+// RecordFinder Finds vault records accessible by the client, implemented by keyhub.VaultService
+type RecordFinder interface {
+	FindByUUIDForClientContext(ctx context.Context, uuid uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error)
+}
+
+// Reference Points to a field of a vault record
+type Reference struct {
+	UUID  uuid.UUID
+	Field string
+}
+
+// ParseReference Parse a reference of the form <record uuid>#<field> or a keyhub:// reference of the secretref package,
+// the field defaults to password
+func ParseReference(value string) (Reference, error) {
+	if secretref.IsRef(value) {
+		ref, err := secretref.Parse(value)
+		if err != nil {
+			return Reference{}, err
+		}
+		return Reference{UUID: ref.Record, Field: ref.Field}, nil
+	}
+
+	id, field, found := strings.Cut(value, "#")
+	if !found {
+		field = FieldPassword
+	}
+
+	recordUUID, err := uuid.Parse(id)
+	if err != nil {
+		return Reference{}, fmt.Errorf("invalid record uuid in reference %q: %w", value, err)
+	}
+
+	switch field {
+	case FieldPassword, FieldUsername, FieldURL, FieldTOTP, FieldFile:
+	default:
+		return Reference{}, fmt.Errorf("unknown field %q in reference %q", field, value)
+	}
+
+	return Reference{UUID: recordUUID, Field: field}, nil
+}
+
+// Value Return the referenced field of record
+func (r Reference) Value(record *model.VaultRecord) (string, error) {
+	return secretref.Value(record, r.Field)
+}
+
+// Runner Runs a command with the referenced secrets added to its environment
+type Runner struct {
+	Vaults RecordFinder
+	// Env Maps the names of environment variables to the secrets they hold
+	Env map[string]Reference
+	// Watch Interval at which the records are checked for changes, the command is restarted with the new secrets
+	// when the LastModifiedAt of a record changed. Records are not watched when 0.
+	Watch time.Duration
+	// StopTimeout Time a command gets to stop after SIGTERM before it is killed, defaults to 10 seconds
+	StopTimeout time.Duration
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Logger Receives a message for every restart, nothing is logged when nil
+	Logger *slog.Logger
+}
+
+// Run Resolve the secrets and run the command until it exits, signals received by this process are forwarded to it.
+// The returned error is an *exec.ExitError when the command failed.
+func (r *Runner) Run(ctx context.Context, name string, args ...string) error {
+	env, modified, err := r.resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	var ticks <-chan time.Time
+	if r.Watch > 0 {
+		ticker := time.NewTicker(r.Watch)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		cmd := exec.Command(name, args...)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = r.Stdin, r.Stdout, r.Stderr
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- cmd.Wait()
+		}()
+
+		restart := false
+		for !restart {
+			select {
+			case err := <-done:
+				return err
+			case sig := <-signals:
+				_ = cmd.Process.Signal(sig)
+			case <-ctx.Done():
+				r.stop(cmd, done)
+				return ctx.Err()
+			case <-ticks:
+				newEnv, newModified, err := r.resolve(ctx)
+				if err != nil {
+					// Keep the command running on the secrets it has
+					r.log("could not check vault records", "error", err)
+					continue
+				}
+				if newModified.Equal(modified) {
+					continue
+				}
+				r.log("vault record changed, restarting command", "command", name)
+				r.stop(cmd, done)
+				env, modified, restart = newEnv, newModified, true
+			}
+		}
+	}
+}
+
+// resolve Return the environment variables holding the secrets and the last modification of the records
+func (r *Runner) resolve(ctx context.Context) (env []string, modified time.Time, err error) {
+	records := make(map[uuid.UUID]*model.VaultRecord)
+	additional := &model.VaultRecordAdditionalQueryParams{Audit: true, Secret: true}
+
+	for name, reference := range r.Env {
+		record, ok := records[reference.UUID]
+		if !ok {
+			record, err = r.Vaults.FindByUUIDForClientContext(ctx, reference.UUID, additional)
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			records[reference.UUID] = record
+			if record.AdditionalObjects != nil && record.AdditionalObjects.Audit != nil && record.LastModifiedAt().After(modified) {
+				modified = record.LastModifiedAt()
+			}
+		}
+
+		value, err := reference.Value(record)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("could not resolve %s: %w", name, err)
+		}
+		env = append(env, name+"="+value)
+	}
+
+	return env, modified, nil
+}
+
+// stop Ask the command to terminate, and kill it when it has not exited after StopTimeout
+func (r *Runner) stop(cmd *exec.Cmd, done chan error) {
+	timeout := r.StopTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		// Not every platform supports SIGTERM
+		_ = cmd.Process.Kill()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		_ = cmd.Process.Kill()
+		<-done
+	}
+}
+
+func (r *Runner) log(msg string, args ...any) {
+	if r.Logger != nil {
+		r.Logger.Info(msg, args...)
+	}
+}
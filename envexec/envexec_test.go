@@ -0,0 +1,137 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package envexec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+var _ RecordFinder = (*keyhub.VaultService)(nil)
+
+var recordUUID = uuid.MustParse("00000000-0000-0000-0000-0000000000c1")
+
+// fakeVaults Returns a record whose password becomes "v2" after changeAfter lookups
+type fakeVaults struct {
+	mu          sync.Mutex
+	lookups     int
+	changeAfter int
+}
+
+func (f *fakeVaults) FindByUUIDForClientContext(ctx context.Context, id uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lookups++
+
+	password, modified := "v1", time.Unix(1000, 0)
+	if f.changeAfter > 0 && f.lookups > f.changeAfter {
+		password, modified = "v2", time.Unix(2000, 0)
+	}
+	record := model.NewVaultRecord("db", &model.VaultRecordSecretAdditionalObject{Password: &password})
+	record.UUID = id.String()
+	record.Username = "app"
+	record.AdditionalObjects.Audit = &model.AuditAdditionalObject{LastModifiedAt: modified}
+	return record, nil
+}
+
+// TestHelperProcess Not a real test, the command started by the runner. It prints its secrets and exits when
+// the password is v2 or no watching is tested, otherwise it waits to be restarted.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("ENVEXEC_HELPER") != "1" {
+		return
+	}
+	fmt.Printf("%s:%s\n", os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"))
+	if os.Getenv("DB_PASSWORD") == "v1" && os.Getenv("ENVEXEC_WAIT") == "1" {
+		time.Sleep(time.Minute)
+	}
+	os.Exit(0)
+}
+
+func helperRunner(vaults RecordFinder, stdout *bytes.Buffer) *Runner {
+	return &Runner{
+		Vaults: vaults,
+		Env: map[string]Reference{
+			"DB_USER":     {UUID: recordUUID, Field: FieldUsername},
+			"DB_PASSWORD": {UUID: recordUUID, Field: FieldPassword},
+		},
+		Stdout: stdout,
+	}
+}
+
+func TestRun(t *testing.T) {
+
+	t.Setenv("ENVEXEC_HELPER", "1")
+	vaults := &fakeVaults{}
+	stdout := new(bytes.Buffer)
+
+	err := helperRunner(vaults, stdout).Run(context.Background(), os.Args[0], "-test.run=TestHelperProcess")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if got := stdout.String(); got != "app:v1\n" {
+		t.Errorf("Result differs, want `app:v1`, got `%v`", got)
+	}
+	if vaults.lookups != 1 {
+		t.Errorf("Expected the record to be looked up once, got %d", vaults.lookups)
+	}
+}
+
+func TestRunRestartsOnChange(t *testing.T) {
+
+	t.Setenv("ENVEXEC_HELPER", "1")
+	t.Setenv("ENVEXEC_WAIT", "1")
+	stdout := new(bytes.Buffer)
+
+	runner := helperRunner(&fakeVaults{changeAfter: 2}, stdout)
+	runner.Watch = 50 * time.Millisecond
+	runner.StopTimeout = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := runner.Run(ctx, os.Args[0], "-test.run=TestHelperProcess"); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if got := stdout.String(); !strings.HasSuffix(got, "app:v2\n") || !strings.HasPrefix(got, "app:v1\n") {
+		t.Errorf("Expected a restart with the new password, got `%v`", got)
+	}
+}
+
+func TestParseReference(t *testing.T) {
+
+	reference, err := ParseReference(recordUUID.String())
+	if err != nil || reference.Field != FieldPassword {
+		t.Fatalf("Unexpected result %+v, %v", reference, err)
+	}
+	reference, err = ParseReference(recordUUID.String() + "#totp")
+	if err != nil || reference.Field != FieldTOTP || reference.UUID != recordUUID {
+		t.Fatalf("Unexpected result %+v, %v", reference, err)
+	}
+	for _, value := range []string{"not-a-uuid", recordUUID.String() + "#secret"} {
+		if _, err := ParseReference(value); err == nil {
+			t.Errorf("Expected an error for %s", value)
+		}
+	}
+}
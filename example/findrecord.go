@@ -27,7 +27,7 @@ func main() {
 
 	flag.Parse()
 
-	client, err := keyhub.NewClientDefault(issuer, clientid, clientsecret)
+	client, err := keyhub.New(issuer, keyhub.WithClientCredentials(clientid, clientsecret))
 
 	if err != nil {
 		log.Fatalf("ERROR %s", err)
@@ -0,0 +1,139 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+// invalidVariableRegex Characters not used in generated variable names
+var invalidVariableRegex = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// Variable A field of a vault record as an environment variable
+type Variable struct {
+	Name  string
+	Value string
+	// Record UUID of the record the value comes from
+	Record string
+}
+
+// CollisionError Several records map to the same name, Names holds the uuids of the records per name
+type CollisionError struct {
+	Names map[string][]string
+}
+
+func (e *CollisionError) Error() string {
+	var collisions []string
+	for _, name := range slices.Sorted(maps.Keys(e.Names)) {
+		collisions = append(collisions, fmt.Sprintf("%s (%s)", name, strings.Join(e.Names[name], ", ")))
+	}
+	return "several records map to the same name: " + strings.Join(collisions, "; ")
+}
+
+// VariableName Return the name of the variable holding field of the record with the given name: the record name in
+// uppercase with every run of other characters than letters and digits replaced by an underscore, followed by the field
+func VariableName(recordName string, field string) string {
+	name := strings.Trim(invalidVariableRegex.ReplaceAllString(strings.ToUpper(slug.Make(recordName)), "_"), "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name + "_" + strings.ToUpper(field)
+}
+
+// Variables Map the fields of records, retrieved with their secrets, to variables sorted by name. Fields default to
+// username, password and url, fields a record does not have are left out. Colliding names return a *CollisionError.
+func Variables(records []model.VaultRecord, fields ...string) ([]Variable, error) {
+	if len(fields) == 0 {
+		fields = []string{FieldUsername, FieldPassword, FieldURL}
+	}
+
+	var variables []Variable
+	byName := make(map[string][]string)
+	for i := range records {
+		record := &records[i]
+		for _, field := range fields {
+			value, ok, err := fieldValue(record, field)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			name := VariableName(record.Name, field)
+			byName[name] = append(byName[name], record.UUID)
+			variables = append(variables, Variable{Name: name, Value: string(value), Record: record.UUID})
+		}
+	}
+
+	collisions := make(map[string][]string)
+	for name, uuids := range byName {
+		if len(uuids) > 1 {
+			collisions[name] = uuids
+		}
+	}
+	if len(collisions) > 0 {
+		return nil, &CollisionError{Names: collisions}
+	}
+
+	slices.SortFunc(variables, func(a, b Variable) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return variables, nil
+}
+
+// WriteDotenv Write variables as a .env file, values are double quoted with \, ", $ and newlines escaped
+func WriteDotenv(w io.Writer, variables []Variable) error {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`)
+	for _, variable := range variables {
+		if _, err := fmt.Fprintf(w, "%s=\"%s\"\n", variable.Name, escaper.Replace(variable.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteShell Write variables as a script of export statements for sh compatible shells, values are single quoted
+func WriteShell(w io.Writer, variables []Variable) error {
+	for _, variable := range variables {
+		value := strings.ReplaceAll(variable.Value, `'`, `'\''`)
+		if _, err := fmt.Fprintf(w, "export %s='%s'\n", variable.Name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON Write variables as a flat JSON object of names and values
+func WriteJSON(w io.Writer, variables []Variable) error {
+	object := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		object[variable.Name] = variable.Value
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(object)
+}
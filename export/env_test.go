@@ -0,0 +1,106 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package export
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+func TestVariables(t *testing.T) {
+
+	db := testRecord("00000000-0000-0000-0000-0000000000f1", "Production DB", "app", `it's "$ecret"`)
+	db.URL = "https://db.example.com:5432/app"
+	api := testRecord("00000000-0000-0000-0000-0000000000f2", "3rd-party API", "", "token")
+
+	variables, err := Variables([]model.VaultRecord{db, api})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	var out bytes.Buffer
+	if err := WriteDotenv(&out, variables); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expected := `PRODUCTION_DB_PASSWORD="it's \"\$ecret\""
+PRODUCTION_DB_URL="https://db.example.com:5432/app"
+PRODUCTION_DB_USERNAME="app"
+_3RD_PARTY_API_PASSWORD="token"
+`
+	if out.String() != expected {
+		t.Errorf("Result differs, want\n%s\ngot\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	if err := WriteShell(&out, variables[:1]); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if expected := "export PRODUCTION_DB_PASSWORD='it'\\''s \"$ecret\"'\n"; out.String() != expected {
+		t.Errorf("Result differs, want `%s`, got `%s`", expected, out.String())
+	}
+
+	out.Reset()
+	if err := WriteJSON(&out, variables[3:]); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if expected := "{\n  \"_3RD_PARTY_API_PASSWORD\": \"token\"\n}\n"; out.String() != expected {
+		t.Errorf("Result differs, want `%s`, got `%s`", expected, out.String())
+	}
+}
+
+func TestVariablesCollisions(t *testing.T) {
+
+	records := []model.VaultRecord{
+		testRecord("00000000-0000-0000-0000-0000000000f1", "Production DB", "app", "one"),
+		testRecord("00000000-0000-0000-0000-0000000000f2", "production-db", "app", "two"),
+	}
+
+	_, err := Variables(records, FieldPassword)
+	var collision *CollisionError
+	if !errors.As(err, &collision) || len(collision.Names["PRODUCTION_DB_PASSWORD"]) != 2 {
+		t.Fatalf("Expected a collision on PRODUCTION_DB_PASSWORD, got %v", err)
+	}
+
+	records[1].URL = "db.example.com"
+	records[0].URL = "https://DB.example.com/"
+	_, err = NetrcEntries(records)
+	if !errors.As(err, &collision) || len(collision.Names["app@db.example.com"]) != 2 {
+		t.Fatalf("Expected a collision on app@db.example.com, got %v", err)
+	}
+}
+
+func TestNetrc(t *testing.T) {
+
+	git := testRecord("00000000-0000-0000-0000-0000000000f1", "Git", "ci", "pass word")
+	git.URL = "https://git.example.com/group/repo.git"
+	noURL := testRecord("00000000-0000-0000-0000-0000000000f2", "No url", "ci", "secret")
+
+	entries, err := NetrcEntries([]model.VaultRecord{git, noURL})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	var out bytes.Buffer
+	if err := WriteNetrc(&out, entries); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if expected := "machine git.example.com login ci password \"pass word\"\n"; out.String() != expected {
+		t.Errorf("Result differs, want `%s`, got `%s`", expected, out.String())
+	}
+}
@@ -0,0 +1,121 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub/kdbx"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+// KeePassUUIDField Custom field of a KeePass entry holding the uuid of the vault record it was exported from
+const KeePassUUIDField = "KeyHub UUID"
+
+// KeePassGroup The records of a group, listed with their secrets, that become a folder in the database
+type KeePassGroup struct {
+	Group   *model.Group
+	Records []model.VaultRecord
+}
+
+// KeePassDatabase Map groups to a database named name with a folder per group. The entries keep the uuid of their
+// record, TOTP secrets are stored as otpauth:// uri in the otp field KeePassXC and KeePass 2.x use.
+func KeePassDatabase(name string, groups []KeePassGroup) (*kdbx.Database, error) {
+	database := &kdbx.Database{Name: name, Root: kdbx.Group{UUID: uuid.New(), Name: name}}
+	for _, group := range groups {
+		folder := kdbx.Group{UUID: parseUUID(group.Group.UUID), Name: group.Group.Name}
+		for i := range group.Records {
+			entry, err := keePassEntry(&group.Records[i])
+			if err != nil {
+				return nil, err
+			}
+			folder.Entries = append(folder.Entries, *entry)
+		}
+		database.Root.Groups = append(database.Root.Groups, folder)
+	}
+	return database, nil
+}
+
+// WriteKeePass Write groups as a KDBX 4 database protected by masterKey
+func WriteKeePass(w io.Writer, name string, groups []KeePassGroup, masterKey string) error {
+	if masterKey == "" {
+		return fmt.Errorf("master key is required")
+	}
+	database, err := KeePassDatabase(name, groups)
+	if err != nil {
+		return err
+	}
+	return kdbx.Write(w, database, masterKey, kdbx.DefaultArgon2)
+}
+
+// keePassEntry Map record, retrieved with its secrets, to an entry
+func keePassEntry(record *model.VaultRecord) (*kdbx.Entry, error) {
+	if record.AdditionalObjects == nil || record.AdditionalObjects.Secret == nil {
+		return nil, fmt.Errorf("VaultRecord %q was retrieved without secrets", record.UUID)
+	}
+	secret := record.AdditionalObjects.Secret
+
+	entry := &kdbx.Entry{UUID: parseUUID(record.UUID), Expiry: record.EndDate}
+	entry.Strings = append(entry.Strings,
+		kdbx.String{Key: kdbx.KeyTitle, Value: record.Name},
+		kdbx.String{Key: kdbx.KeyUserName, Value: record.Username},
+		kdbx.String{Key: kdbx.KeyPassword, Value: stringValue(secret.Password)},
+		kdbx.String{Key: kdbx.KeyURL, Value: record.URL},
+		kdbx.String{Key: kdbx.KeyNotes, Value: stringValue(secret.Comment)},
+	)
+
+	if totp := stringValue(secret.Totp); totp != "" {
+		if !strings.HasPrefix(strings.ToLower(totp), "otpauth://") {
+			parsed, err := model.ParseTOTP(totp)
+			if err != nil {
+				return nil, fmt.Errorf("VaultRecord %q: %w", record.UUID, err)
+			}
+			totp = parsed.URL("KeyHub", record.Name)
+		}
+		entry.Strings = append(entry.Strings, kdbx.String{Key: kdbx.KeyOTP, Value: totp})
+	}
+	entry.Strings = append(entry.Strings, kdbx.String{Key: KeePassUUIDField, Value: record.UUID})
+
+	if secret.File != nil && len(*secret.File) > 0 {
+		filename := record.Filename
+		if filename == "" {
+			filename = record.Name
+		}
+		entry.Attachments = append(entry.Attachments, kdbx.Attachment{Name: filename, Data: *secret.File})
+	}
+
+	return entry, nil
+}
+
+// parseUUID Return id as uuid, or a random one when KeyHub did not return a valid uuid
+func parseUUID(id string) uuid.UUID {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.New()
+	}
+	return parsed
+}
+
+// stringValue Return the value of s, or an empty string when nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,230 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Package export Writes vault records, retrieved with their secrets by VaultService.ListWithSecrets, in formats other
+// tools consume
+package export
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/topicuskeyhub/go-keyhub/model"
+	"github.com/topicuskeyhub/go-keyhub/secretref"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FieldPassword = secretref.FieldPassword
+	FieldUsername = secretref.FieldUsername
+	FieldURL      = secretref.FieldURL
+	FieldFile     = secretref.FieldFile
+
+	// LabelRecordUUID Label of a Secret holding the uuid of the vault record it was exported from
+	LabelRecordUUID = "keyhub.topicus.nl/record-uuid"
+	// AnnotationLastModifiedAt Annotation of a Secret holding the moment the vault record was last modified
+	AnnotationLastModifiedAt = "keyhub.topicus.nl/last-modified-at"
+)
+
+var (
+	// invalidKeyRegex Characters not allowed in the keys of a Secret
+	invalidKeyRegex = regexp.MustCompile(`[^-._a-zA-Z0-9]+`)
+	// invalidNameRegex Characters not used in generated Secret names
+	invalidNameRegex = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// KubernetesMapping Rules for turning vault records into Secret manifests, one Secret per record
+type KubernetesMapping struct {
+	Namespace string
+	// Type Type of the Secrets, defaults to Opaque
+	Type string
+	// Fields Fields of a record that become keys of its Secret, defaults to password and username.
+	// Fields a record does not have are left out.
+	Fields []string
+	// Name Return the name of the Secret of record, defaults to the record name as a lowercase DNS subdomain
+	Name func(record *model.VaultRecord) string
+	// Keys Names of the keys holding the fields, a field without entry uses its own name
+	Keys map[string]string
+	// Labels Added to every Secret
+	Labels map[string]string
+	// Annotations Added to every Secret
+	Annotations map[string]string
+}
+
+// KubernetesSecret A v1/Secret manifest
+type KubernetesSecret struct {
+	APIVersion string             `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string             `json:"kind" yaml:"kind"`
+	Metadata   KubernetesMetadata `json:"metadata" yaml:"metadata"`
+	Type       string             `json:"type" yaml:"type"`
+	// Data Base64 encoded values by key
+	Data map[string]string `json:"data" yaml:"data"`
+}
+
+type KubernetesMetadata struct {
+	Name        string            `json:"name" yaml:"name"`
+	Namespace   string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// KubernetesSecrets Map records, retrieved with their secrets, to Secret manifests. Records that map to the same
+// Secret name are reported as an error.
+func KubernetesSecrets(records []model.VaultRecord, mapping KubernetesMapping) ([]KubernetesSecret, error) {
+	fields := mapping.Fields
+	if len(fields) == 0 {
+		fields = []string{FieldPassword, FieldUsername}
+	}
+	secretType := mapping.Type
+	if secretType == "" {
+		secretType = "Opaque"
+	}
+
+	secrets := make([]KubernetesSecret, 0, len(records))
+	names := make(map[string]string)
+	for i := range records {
+		record := &records[i]
+
+		name := kubernetesName(record.Name)
+		if mapping.Name != nil {
+			name = mapping.Name(record)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("VaultRecord %q maps to an empty Secret name", record.UUID)
+		}
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("VaultRecords %q and %q both map to Secret %q", other, record.UUID, name)
+		}
+		names[name] = record.UUID
+
+		secret := KubernetesSecret{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata: KubernetesMetadata{
+				Name:        name,
+				Namespace:   mapping.Namespace,
+				Labels:      maps.Clone(mapping.Labels),
+				Annotations: maps.Clone(mapping.Annotations),
+			},
+			Type: secretType,
+			Data: make(map[string]string),
+		}
+		if secret.Metadata.Labels == nil {
+			secret.Metadata.Labels = make(map[string]string)
+		}
+		if secret.Metadata.Annotations == nil {
+			secret.Metadata.Annotations = make(map[string]string)
+		}
+		secret.Metadata.Labels[LabelRecordUUID] = record.UUID
+		if record.AdditionalObjects != nil && record.AdditionalObjects.Audit != nil {
+			secret.Metadata.Annotations[AnnotationLastModifiedAt] = record.LastModifiedAt().UTC().Format(time.RFC3339)
+		}
+
+		for _, field := range fields {
+			value, ok, err := fieldValue(record, field)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			key := field
+			if mapped, ok := mapping.Keys[field]; ok {
+				key = mapped
+			}
+			if key == "" || invalidKeyRegex.MatchString(key) {
+				return nil, fmt.Errorf("invalid Secret key %q for field %s", key, field)
+			}
+			secret.Data[key] = base64.StdEncoding.EncodeToString(value)
+		}
+
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, nil
+}
+
+// kubernetesName Return name as a valid Secret name: lowercase alphanumerics and dashes, at most 253 characters
+func kubernetesName(name string) string {
+	name = strings.Trim(invalidNameRegex.ReplaceAllString(slug.Make(name), "-"), "-")
+	if len(name) > 253 {
+		name = strings.TrimRight(name[:253], "-")
+	}
+	return name
+}
+
+// fieldValue Return field of record, ok is false when the record does not have it
+func fieldValue(record *model.VaultRecord, field string) (value []byte, ok bool, err error) {
+	var secret *model.VaultRecordSecretAdditionalObject
+	if record.AdditionalObjects != nil {
+		secret = record.AdditionalObjects.Secret
+	}
+
+	switch field {
+	case FieldUsername:
+		return []byte(record.Username), record.Username != "", nil
+	case FieldURL:
+		return []byte(record.URL), record.URL != "", nil
+	case FieldPassword:
+		if secret == nil {
+			return nil, false, fmt.Errorf("VaultRecord %q was retrieved without secrets", record.UUID)
+		}
+		if secret.Password == nil || *secret.Password == "" {
+			return nil, false, nil
+		}
+		return []byte(*secret.Password), true, nil
+	case FieldFile:
+		if secret == nil {
+			return nil, false, fmt.Errorf("VaultRecord %q was retrieved without secrets", record.UUID)
+		}
+		if secret.File == nil || len(*secret.File) == 0 {
+			return nil, false, nil
+		}
+		return *secret.File, true, nil
+	}
+	return nil, false, fmt.Errorf("unknown field %q", field)
+}
+
+// WriteKubernetesYAML Write secrets as a multi document YAML stream
+func WriteKubernetesYAML(w io.Writer, secrets []KubernetesSecret) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	for _, secret := range secrets {
+		if err := encoder.Encode(secret); err != nil {
+			return err
+		}
+	}
+	return encoder.Close()
+}
+
+// WriteKubernetesJSON Write secrets as a JSON v1/List
+func WriteKubernetesJSON(w io.Writer, secrets []KubernetesSecret) error {
+	list := struct {
+		APIVersion string             `json:"apiVersion"`
+		Kind       string             `json:"kind"`
+		Items      []KubernetesSecret `json:"items"`
+	}{APIVersion: "v1", Kind: "List", Items: secrets}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(list)
+}
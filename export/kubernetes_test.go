@@ -0,0 +1,111 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/topicuskeyhub/go-keyhub/model"
+	"gopkg.in/yaml.v3"
+)
+
+// testRecord A record as returned by VaultService.List with secrets
+func testRecord(uuid string, name string, username string, password string) model.VaultRecord {
+	record := model.NewVaultRecord(name, &model.VaultRecordSecretAdditionalObject{Password: &password})
+	record.UUID = uuid
+	record.Username = username
+	record.AdditionalObjects.Audit = &model.AuditAdditionalObject{LastModifiedAt: time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)}
+	return *record
+}
+
+func TestKubernetesSecrets(t *testing.T) {
+
+	file := []byte{0, 1, 2, 0xff}
+	db := testRecord("00000000-0000-0000-0000-0000000000f1", "Production DB", "app", "s3cret")
+	db.AdditionalObjects.Secret.File = &file
+
+	secrets, err := KubernetesSecrets([]model.VaultRecord{db}, KubernetesMapping{
+		Namespace: "prod",
+		Fields:    []string{FieldUsername, FieldPassword, FieldURL, FieldFile},
+		Keys:      map[string]string{FieldFile: "tls.key"},
+		Labels:    map[string]string{"app": "api"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	var out bytes.Buffer
+	if err := WriteKubernetesYAML(&out, secrets); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var manifest map[string]any
+	if err := yaml.Unmarshal(out.Bytes(), &manifest); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	expected := `apiVersion: v1
+kind: Secret
+metadata:
+  name: production-db
+  namespace: prod
+  labels:
+    app: api
+    keyhub.topicus.nl/record-uuid: 00000000-0000-0000-0000-0000000000f1
+  annotations:
+    keyhub.topicus.nl/last-modified-at: "2024-06-10T12:00:00Z"
+type: Opaque
+data:
+  password: czNjcmV0
+  tls.key: AAEC/w==
+  username: YXBw
+`
+	if out.String() != expected {
+		t.Errorf("Result differs, want\n%s\ngot\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	if err := WriteKubernetesJSON(&out, secrets); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var list struct {
+		Kind  string
+		Items []KubernetesSecret
+	}
+	if err := json.Unmarshal(out.Bytes(), &list); err != nil || list.Kind != "List" || len(list.Items) != 1 {
+		t.Fatalf("Unexpected JSON list %s, %v", out.String(), err)
+	}
+}
+
+func TestKubernetesSecretsCollisions(t *testing.T) {
+
+	records := []model.VaultRecord{
+		testRecord("00000000-0000-0000-0000-0000000000f1", "Production DB", "app", "s3cret"),
+		testRecord("00000000-0000-0000-0000-0000000000f2", "production_db", "app", "other"),
+	}
+	_, err := KubernetesSecrets(records, KubernetesMapping{})
+	if err == nil || !strings.Contains(err.Error(), "production-db") {
+		t.Fatalf("Expected a collision on production-db, got %v", err)
+	}
+
+	_, err = KubernetesSecrets(records[:1], KubernetesMapping{Keys: map[string]string{FieldPassword: "db password"}})
+	if err == nil {
+		t.Fatalf("Expected an error for an invalid key")
+	}
+}
@@ -0,0 +1,110 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package export
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+// NetrcEntry Login of a vault record for a machine
+type NetrcEntry struct {
+	Machine  string
+	Login    string
+	Password string
+	// Record UUID of the record the login comes from
+	Record string
+}
+
+// NetrcEntries Map records, retrieved with their secrets, with a URL, username and password to .netrc entries sorted by
+// machine and login. The machine is the host of the URL. Records lacking one of them are left out, several records for
+// the same machine and login return a *CollisionError.
+func NetrcEntries(records []model.VaultRecord) ([]NetrcEntry, error) {
+	var entries []NetrcEntry
+	byLogin := make(map[string][]string)
+
+	for i := range records {
+		record := &records[i]
+		password, ok, err := fieldValue(record, FieldPassword)
+		if err != nil {
+			return nil, err
+		}
+		machine := netrcMachine(record.URL)
+		if !ok || machine == "" || record.Username == "" {
+			continue
+		}
+
+		key := record.Username + "@" + machine
+		byLogin[key] = append(byLogin[key], record.UUID)
+		entries = append(entries, NetrcEntry{Machine: machine, Login: record.Username, Password: string(password), Record: record.UUID})
+	}
+
+	collisions := make(map[string][]string)
+	for key, uuids := range byLogin {
+		if len(uuids) > 1 {
+			collisions[key] = uuids
+		}
+	}
+	if len(collisions) > 0 {
+		return nil, &CollisionError{Names: collisions}
+	}
+
+	slices.SortFunc(entries, func(a, b NetrcEntry) int {
+		if c := strings.Compare(a.Machine, b.Machine); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Login, b.Login)
+	})
+	return entries, nil
+}
+
+// netrcMachine Return the host of a record URL, which may lack a scheme
+func netrcMachine(recordURL string) string {
+	if recordURL == "" {
+		return ""
+	}
+	if !strings.Contains(recordURL, "://") {
+		recordURL = "//" + recordURL
+	}
+	u, err := url.Parse(recordURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// WriteNetrc Write entries in .netrc format. Tokens with whitespace or quotes are double quoted with backslash escapes,
+// which curl and most other readers support.
+func WriteNetrc(w io.Writer, entries []NetrcEntry) error {
+	for _, entry := range entries {
+		if _, err := fmt.Fprintf(w, "machine %s login %s password %s\n", netrcToken(entry.Machine), netrcToken(entry.Login), netrcToken(entry.Password)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func netrcToken(token string) string {
+	if token != "" && !strings.ContainsAny(token, " \t\r\n\"\\") {
+		return token
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(token) + `"`
+}
@@ -0,0 +1,347 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Package generate Generates secrets from crypto/rand, e.g. to satisfy the password policy of a KeyHub group.
+// Every generator implements keyhub.SecretGenerator, so it can be passed to VaultService.Rotate directly.
+package generate
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/sethvargo/go-diceware/diceware"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+const (
+	Lowercase = "abcdefghijklmnopqrstuvwxyz"
+	Uppercase = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	Digits    = "0123456789"
+	Symbols   = "!#$%&()*+,-./:;<=>?@[]^_{}~"
+	// Ambiguous Characters that are easily mistaken for one another when read or typed
+	Ambiguous = "Il1|O0oB8S5Z2`'\""
+)
+
+// Generator Generates secrets and estimates their strength
+type Generator interface {
+	Generate() (string, error)
+	// Entropy Return the number of bits of entropy of the generated secrets
+	Entropy() float64
+}
+
+// CharacterClass A set of characters of which a password contains at least Min
+type CharacterClass struct {
+	Characters string
+	Min        int
+}
+
+// Password Generates random passwords from character classes
+type Password struct {
+	Length  int
+	Classes []CharacterClass
+	// ExcludeAmbiguous Leave out the characters in Ambiguous
+	ExcludeAmbiguous bool
+	// Exclude Characters that are never used, e.g. the ones a target system does not accept
+	Exclude string
+}
+
+// DefaultPassword A 24 character password with at least one lowercase letter, uppercase letter, digit and symbol
+func DefaultPassword() Password {
+	return Password{
+		Length: 24,
+		Classes: []CharacterClass{
+			{Characters: Lowercase, Min: 1},
+			{Characters: Uppercase, Min: 1},
+			{Characters: Digits, Min: 1},
+			{Characters: Symbols, Min: 1},
+		},
+		ExcludeAmbiguous: true,
+	}
+}
+
+// classes Return the character sets of p after applying the exclusions
+func (p Password) classes() ([]CharacterClass, string, error) {
+	if p.Length <= 0 {
+		return nil, "", errors.New("password needs at least one character")
+	}
+
+	exclude := p.Exclude
+	if p.ExcludeAmbiguous {
+		exclude += Ambiguous
+	}
+
+	var classes []CharacterClass
+	var all strings.Builder
+	required := 0
+	for _, class := range p.Classes {
+		characters := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(exclude, r) || strings.ContainsRune(all.String(), r) {
+				return -1
+			}
+			return r
+		}, class.Characters)
+		if characters == "" {
+			if class.Min > 0 {
+				return nil, "", fmt.Errorf("character class %q has no characters left", class.Characters)
+			}
+			continue
+		}
+		all.WriteString(characters)
+		classes = append(classes, CharacterClass{Characters: characters, Min: class.Min})
+		required += max(class.Min, 0)
+	}
+
+	if all.Len() == 0 {
+		return nil, "", errors.New("password has no characters to choose from")
+	}
+	if required > p.Length {
+		return nil, "", fmt.Errorf("password of %d characters can not hold the %d required characters", p.Length, required)
+	}
+	return classes, all.String(), nil
+}
+
+// Generate Return a new password
+func (p Password) Generate() (string, error) {
+	classes, all, err := p.classes()
+	if err != nil {
+		return "", err
+	}
+
+	password := make([]rune, 0, p.Length)
+	for _, class := range classes {
+		for range class.Min {
+			c, err := pick([]rune(class.Characters))
+			if err != nil {
+				return "", err
+			}
+			password = append(password, c)
+		}
+	}
+	for len(password) < p.Length {
+		c, err := pick([]rune(all))
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	// Shuffle so the required characters are not always in front
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return string(password), nil
+}
+
+// Entropy Return the entropy of a password of Length characters chosen from all classes,
+// the minimum per class lowers it slightly
+func (p Password) Entropy() float64 {
+	_, all, err := p.classes()
+	if err != nil {
+		return 0
+	}
+	return float64(p.Length) * math.Log2(float64(len([]rune(all))))
+}
+
+// Passphrase Generates diceware style passphrases of random words
+type Passphrase struct {
+	Words     int
+	Separator string
+	// Capitalize Start every word with an uppercase letter
+	Capitalize bool
+	// Digit Append a random digit to a random word, for systems that require one
+	Digit bool
+	// WordList Words to choose from, defaults to the EFF large word list of 7776 words
+	WordList diceware.WordList
+}
+
+// DefaultPassphrase Six words from the EFF large word list separated by dashes
+func DefaultPassphrase() Passphrase {
+	return Passphrase{Words: 6, Separator: "-"}
+}
+
+func (p Passphrase) wordList() diceware.WordList {
+	if p.WordList == nil {
+		return diceware.WordListEffLarge()
+	}
+	return p.WordList
+}
+
+// Generate Return a new passphrase
+func (p Passphrase) Generate() (string, error) {
+	if p.Words <= 0 {
+		return "", errors.New("passphrase needs at least one word")
+	}
+	list := p.wordList()
+
+	words := make([]string, p.Words)
+	for i := range words {
+		// Roll a die for every digit of the index, like diceware does on paper
+		index := 0
+		for range list.Digits() {
+			roll, err := randomInt(6)
+			if err != nil {
+				return "", err
+			}
+			index = index*10 + roll + 1
+		}
+		words[i] = list.WordAt(index)
+		if p.Capitalize && words[i] != "" {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+
+	if p.Digit {
+		i, err := randomInt(len(words))
+		if err != nil {
+			return "", err
+		}
+		digit, err := randomInt(10)
+		if err != nil {
+			return "", err
+		}
+		words[i] += fmt.Sprint(digit)
+	}
+
+	return strings.Join(words, p.Separator), nil
+}
+
+// Entropy Return the entropy of a passphrase, the separator and capitalization add nothing as they are not random
+func (p Passphrase) Entropy() float64 {
+	if p.Words <= 0 {
+		return 0
+	}
+	entropy := float64(p.Words*p.wordList().Digits()) * math.Log2(6)
+	if p.Digit {
+		entropy += math.Log2(10) + math.Log2(float64(p.Words))
+	}
+	return entropy
+}
+
+const (
+	pronounceableConsonants = "bcdfghjkmnprstvz"
+	pronounceableVowels     = "aeiu"
+)
+
+// Pronounceable Generates passwords of alternating consonants and vowels, which are easier to read out and type
+type Pronounceable struct {
+	Length int
+	// Capitalize Start the password with an uppercase letter
+	Capitalize bool
+	// Digits Number of random digits appended to the letters
+	Digits int
+}
+
+// Generate Return a new pronounceable password
+func (p Pronounceable) Generate() (string, error) {
+	if p.Length <= 0 {
+		return "", errors.New("pronounceable password needs at least one letter")
+	}
+
+	var password strings.Builder
+	for i := range p.Length {
+		letters := pronounceableConsonants
+		if i%2 == 1 {
+			letters = pronounceableVowels
+		}
+		c, err := pick([]rune(letters))
+		if err != nil {
+			return "", err
+		}
+		if i == 0 && p.Capitalize {
+			c = []rune(strings.ToUpper(string(c)))[0]
+		}
+		password.WriteRune(c)
+	}
+	for range p.Digits {
+		c, err := pick([]rune(Digits))
+		if err != nil {
+			return "", err
+		}
+		password.WriteRune(c)
+	}
+
+	return password.String(), nil
+}
+
+// Entropy Return the entropy of a pronounceable password
+func (p Pronounceable) Entropy() float64 {
+	if p.Length <= 0 {
+		return 0
+	}
+	consonants := (p.Length + 1) / 2
+	vowels := p.Length / 2
+	return float64(consonants)*math.Log2(float64(len(pronounceableConsonants))) +
+		float64(vowels)*math.Log2(float64(len(pronounceableVowels))) +
+		float64(p.Digits)*math.Log2(10)
+}
+
+// Estimate Return an estimate of the entropy of any password in bits, assuming every character is chosen at random
+// from the character classes it uses. Passwords made up by people have far less entropy than this.
+func Estimate(password string) float64 {
+	if password == "" {
+		return 0
+	}
+
+	pool := 0
+	var other []rune
+	for _, class := range []string{Lowercase, Uppercase, Digits, Symbols} {
+		if strings.ContainsAny(password, class) {
+			pool += len(class)
+		}
+	}
+	for _, r := range password {
+		if !strings.ContainsRune(Lowercase+Uppercase+Digits+Symbols, r) && !strings.ContainsRune(string(other), r) {
+			other = append(other, r)
+		}
+	}
+	pool += len(other)
+
+	return float64(len([]rune(password))) * math.Log2(float64(pool))
+}
+
+// NewVaultRecord Create a vault record holding a password from generator, to be stored with VaultService.Create
+func NewVaultRecord(name string, generator Generator) (*model.VaultRecord, error) {
+	password, err := generator.Generate()
+	if err != nil {
+		return nil, err
+	}
+	return model.NewVaultRecord(name, &model.VaultRecordSecretAdditionalObject{Password: &password}), nil
+}
+
+func pick(characters []rune) (rune, error) {
+	i, err := randomInt(len(characters))
+	if err != nil {
+		return 0, err
+	}
+	return characters[i], nil
+}
+
+// randomInt Return a uniformly distributed number in [0, n) from crypto/rand
+func randomInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
@@ -0,0 +1,134 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package generate
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestPassword(t *testing.T) {
+
+	policy := DefaultPassword()
+	for range 100 {
+		password, err := policy.Generate()
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if len(password) != 24 {
+			t.Fatalf("Result differs, want length 24, got `%v`", password)
+		}
+		for _, class := range []string{Lowercase, Uppercase, Digits, Symbols} {
+			if !strings.ContainsAny(password, class) {
+				t.Fatalf("Password `%v` misses a character of `%v`", password, class)
+			}
+		}
+		if strings.ContainsAny(password, Ambiguous) {
+			t.Fatalf("Password `%v` contains an ambiguous character", password)
+		}
+	}
+
+	digits := Password{Length: 8, Classes: []CharacterClass{{Characters: Digits}}, Exclude: "13579"}
+	password, err := digits.Generate()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if strings.Trim(password, "02468") != "" {
+		t.Errorf("Password `%v` contains excluded characters", password)
+	}
+	if entropy := digits.Entropy(); math.Abs(entropy-8*math.Log2(5)) > 0.001 {
+		t.Errorf("Result differs, want `%v`, got `%v`", 8*math.Log2(5), entropy)
+	}
+
+	impossible := []Password{
+		{Length: 2, Classes: []CharacterClass{{Characters: Lowercase, Min: 2}, {Characters: Digits, Min: 1}}},
+		{Length: 8, Classes: []CharacterClass{{Characters: "01", Min: 1}}, ExcludeAmbiguous: true},
+		{Length: 8},
+		{Classes: []CharacterClass{{Characters: Lowercase}}},
+		{Length: -1, Classes: []CharacterClass{{Characters: Lowercase}}},
+	}
+	for _, policy := range impossible {
+		if _, err := policy.Generate(); err == nil {
+			t.Errorf("Expected an error for %+v", policy)
+		}
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+
+	policy := Passphrase{Words: 5, Separator: " ", Capitalize: true, Digit: true}
+	phrase, err := policy.Generate()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	words := strings.Split(phrase, " ")
+	if len(words) != 5 {
+		t.Fatalf("Result differs, want 5 words, got `%v`", phrase)
+	}
+	for _, word := range words {
+		if word == "" || !unicode.IsUpper(rune(word[0])) {
+			t.Fatalf("Passphrase `%v` has a word that is not capitalized", phrase)
+		}
+	}
+	if !strings.ContainsAny(phrase, Digits) {
+		t.Errorf("Passphrase `%v` has no digit", phrase)
+	}
+
+	// Every word of the EFF large list adds log2(7776) bits
+	if entropy := DefaultPassphrase().Entropy(); math.Abs(entropy-6*math.Log2(7776)) > 0.001 {
+		t.Errorf("Result differs, want `%v`, got `%v`", 6*math.Log2(7776), entropy)
+	}
+}
+
+func TestPronounceable(t *testing.T) {
+
+	policy := Pronounceable{Length: 10, Capitalize: true, Digits: 2}
+	password, err := policy.Generate()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(password) != 12 || !unicode.IsUpper(rune(password[0])) || strings.Trim(password[10:], Digits) != "" {
+		t.Fatalf("Result differs, got `%v`", password)
+	}
+	for i := 1; i < 10; i += 2 {
+		if !strings.ContainsRune(pronounceableVowels, rune(password[i])) {
+			t.Fatalf("Password `%v` has no vowel at position %d", password, i)
+		}
+	}
+}
+
+func TestEstimate(t *testing.T) {
+
+	if entropy := Estimate(""); entropy != 0 {
+		t.Errorf("Result differs, want 0, got `%v`", entropy)
+	}
+	if entropy := Estimate("abcd1234"); math.Abs(entropy-8*math.Log2(36)) > 0.001 {
+		t.Errorf("Result differs, want `%v`, got `%v`", 8*math.Log2(36), entropy)
+	}
+}
+
+func TestNewVaultRecord(t *testing.T) {
+
+	record, err := NewVaultRecord("database", DefaultPassword())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if record.Password() == nil || len(*record.Password()) != 24 {
+		t.Fatalf("Expected a generated password in the record")
+	}
+}
@@ -0,0 +1,272 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Package gitcredential Implements the git credential helper protocol on top of KeyHub vault records.
+// A record matches a request when the host of its URL equals the requested host, its URL path is a prefix of the
+// requested path and, when git asks for a specific user, its username equals that user.
+package gitcredential
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+// Credential The attributes git exchanges with a credential helper
+type Credential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+}
+
+// ReadCredential Read the attributes git writes to a helper, unknown attributes are ignored
+func ReadCredential(r io.Reader) (*Credential, error) {
+	credential := &Credential{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid credential line %q", line)
+		}
+		switch key {
+		case "protocol":
+			credential.Protocol = value
+		case "host":
+			credential.Host = value
+		case "path":
+			credential.Path = value
+		case "username":
+			credential.Username = value
+		case "password":
+			credential.Password = value
+		case "url":
+			u, err := url.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid credential url: %w", err)
+			}
+			credential.Protocol, credential.Host, credential.Path = u.Scheme, u.Host, strings.TrimPrefix(u.Path, "/")
+			if u.User != nil {
+				credential.Username = u.User.Username()
+			}
+		}
+	}
+	return credential, scanner.Err()
+}
+
+// Write Write the attributes of c that are set in the format git reads from a helper
+func (c *Credential) Write(w io.Writer) error {
+	for _, attribute := range [][2]string{{"protocol", c.Protocol}, {"host", c.Host}, {"path", c.Path}, {"username", c.Username}, {"password", c.Password}} {
+		if attribute[1] == "" {
+			continue
+		}
+		if strings.ContainsAny(attribute[1], "\n\x00") {
+			return fmt.Errorf("credential %s contains a newline or NUL", attribute[0])
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", attribute[0], attribute[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// URL Return the url the credential is for
+func (c *Credential) URL() string {
+	u := url.URL{Scheme: c.Protocol, Host: c.Host, Path: "/" + c.Path}
+	return u.String()
+}
+
+// Vaults The part of keyhub.VaultService used by the helper
+type Vaults interface {
+	SearchForClient(ctx context.Context, query model.VaultRecordSearchQueryParams, opts ...keyhub.ListOption) iter.Seq2[model.VaultRecord, error]
+	GetBySelfContext(ctx context.Context, vaultRecord *model.VaultRecord, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error)
+	CreateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (*model.VaultRecord, error)
+	UpdateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (*model.VaultRecord, error)
+}
+
+// Helper A git credential helper serving the vault records accessible by the client
+type Helper struct {
+	Vaults Vaults
+	// Group Group that store creates and updates records in, store does nothing when nil
+	Group *model.Group
+}
+
+// Serve Handle operation get, store or erase with the attributes in r, writing the answer to w as git expects.
+// Erase is ignored, so a single failed login never removes a secret from KeyHub.
+func (h *Helper) Serve(ctx context.Context, operation string, r io.Reader, w io.Writer) error {
+	request, err := ReadCredential(r)
+	if err != nil {
+		return err
+	}
+
+	switch operation {
+	case "get":
+		credential, err := h.Get(ctx, request)
+		if errors.Is(err, keyhub.ErrNotFound) {
+			// Answering nothing lets git try the next helper or prompt
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return credential.Write(w)
+	case "store":
+		return h.Store(ctx, request)
+	case "erase":
+		return nil
+	}
+	return fmt.Errorf("unknown credential operation %q", operation)
+}
+
+// Get Return the credential of the best matching record, the one with the longest URL path
+func (h *Helper) Get(ctx context.Context, request *Credential) (*Credential, error) {
+	record, err := h.find(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err = h.Vaults.GetBySelfContext(ctx, record, &model.VaultRecordAdditionalQueryParams{Secret: true})
+	if err != nil {
+		return nil, err
+	}
+	if record.Password() == nil {
+		return nil, fmt.Errorf("VaultRecord %q has no password %w", record.UUID, keyhub.ErrNotFound)
+	}
+
+	credential := *request
+	credential.Username = record.Username
+	credential.Password = *record.Password()
+	return &credential, nil
+}
+
+// Store Update the password of the matching record in Group, or create a record when there is none
+func (h *Helper) Store(ctx context.Context, request *Credential) error {
+	if h.Group == nil || request.Password == "" {
+		return nil
+	}
+
+	record, err := h.find(ctx, request)
+	if err != nil && !errors.Is(err, keyhub.ErrNotFound) {
+		return err
+	}
+
+	if record != nil && record.Username == request.Username && h.inGroup(record) {
+		record, err = h.Vaults.GetBySelfContext(ctx, record, &model.VaultRecordAdditionalQueryParams{Secret: true})
+		if err != nil {
+			return err
+		}
+		if record.Password() != nil && *record.Password() == request.Password {
+			return nil
+		}
+		if record.AdditionalObjects == nil {
+			record.AdditionalObjects = &model.VaultRecordAdditionalObjects{}
+		}
+		if record.AdditionalObjects.Secret == nil {
+			record.AdditionalObjects.Secret = &model.VaultRecordSecretAdditionalObject{DType: "vault.VaultRecordSecrets"}
+		}
+		record.AdditionalObjects.Secret.Password = &request.Password
+		_, err = h.Vaults.UpdateContext(ctx, h.Group, record)
+		return err
+	}
+
+	record = model.NewVaultRecord(strings.TrimSuffix(request.Host+"/"+request.Path, "/"), &model.VaultRecordSecretAdditionalObject{Password: &request.Password})
+	record.URL = request.URL()
+	record.Username = request.Username
+	_, err = h.Vaults.CreateContext(ctx, h.Group, record)
+	return err
+}
+
+// inGroup Return true when record belongs to Group
+func (h *Helper) inGroup(record *model.VaultRecord) bool {
+	if record.Self() == nil || h.Group.Self() == nil {
+		return false
+	}
+	return strings.HasPrefix(record.Self().Href, h.Group.Self().Href+"/")
+}
+
+// find Return the matching record with the longest URL path, without secrets
+func (h *Helper) find(ctx context.Context, request *Credential) (*model.VaultRecord, error) {
+	if request.Host == "" {
+		return nil, fmt.Errorf("credential without host %w", keyhub.ErrNotFound)
+	}
+
+	query := model.VaultRecordSearchQueryParams{Url: request.Host, Username: request.Username}
+
+	var best *model.VaultRecord
+	bestLength := -1
+	for record, err := range h.Vaults.SearchForClient(ctx, query) {
+		if err != nil {
+			return nil, err
+		}
+		length, ok := matches(record, request)
+		if ok && length > bestLength {
+			best, bestLength = &record, length
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("VaultRecord for %s %w", request.URL(), keyhub.ErrNotFound)
+	}
+	return best, nil
+}
+
+// matches Return whether record matches request and the length of the matching path
+func matches(record model.VaultRecord, request *Credential) (int, bool) {
+	if request.Username != "" && record.Username != request.Username {
+		return 0, false
+	}
+
+	recordURL := record.URL
+	if !strings.Contains(recordURL, "://") {
+		recordURL = "//" + recordURL
+	}
+	u, err := url.Parse(recordURL)
+	if err != nil || !strings.EqualFold(u.Host, request.Host) {
+		return 0, false
+	}
+	if u.Scheme != "" && request.Protocol != "" && !strings.EqualFold(u.Scheme, request.Protocol) {
+		return 0, false
+	}
+
+	recordPath := strings.Trim(strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git"), "/")
+	requestPath := strings.Trim(strings.TrimSuffix(strings.Trim(request.Path, "/"), ".git"), "/")
+	if recordPath != "" && requestPath != recordPath && !strings.HasPrefix(requestPath, recordPath+"/") {
+		return 0, false
+	}
+	return len(recordPath), true
+}
+
+// GroupByUUID Return the group with uuid, a helper to set Helper.Group from configuration
+func GroupByUUID(ctx context.Context, groups *keyhub.GroupService, id string) (*model.Group, error) {
+	groupUUID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid group uuid %q: %w", id, err)
+	}
+	return groups.GetByUUIDContext(ctx, groupUUID)
+}
@@ -0,0 +1,198 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package gitcredential
+
+import (
+	"bytes"
+	"context"
+	"iter"
+	"strings"
+	"testing"
+
+	"github.com/topicuskeyhub/go-keyhub"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+var _ Vaults = (*keyhub.VaultService)(nil)
+
+const groupHref = "https://keyhub.example.com/keyhub/rest/v1/group/1"
+
+// fakeVaults Serves records from memory, search only applies the host filter like KeyHub's url filter would
+type fakeVaults struct {
+	records []*model.VaultRecord
+	updated []*model.VaultRecord
+	created []*model.VaultRecord
+}
+
+func (f *fakeVaults) add(uuid string, recordURL string, username string, password string) {
+	record := model.NewVaultRecord(recordURL, &model.VaultRecordSecretAdditionalObject{Password: &password})
+	record.UUID = uuid
+	record.URL = recordURL
+	record.Username = username
+	record.Links = []model.Link{{Rel: "self", Href: groupHref + "/vault/record/" + uuid}}
+	f.records = append(f.records, record)
+}
+
+func (f *fakeVaults) SearchForClient(ctx context.Context, query model.VaultRecordSearchQueryParams, opts ...keyhub.ListOption) iter.Seq2[model.VaultRecord, error] {
+	return func(yield func(model.VaultRecord, error) bool) {
+		for _, record := range f.records {
+			if !strings.Contains(strings.ToLower(record.URL), strings.ToLower(query.Url)) {
+				continue
+			}
+			found := *record
+			found.AdditionalObjects = nil
+			if !yield(found, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (f *fakeVaults) GetBySelfContext(ctx context.Context, vaultRecord *model.VaultRecord, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error) {
+	for _, record := range f.records {
+		if record.Self().Href == vaultRecord.Self().Href {
+			found := *record
+			found.AdditionalObjects = nil
+			if record.AdditionalObjects != nil {
+				secret := *record.AdditionalObjects.Secret
+				found.AdditionalObjects = &model.VaultRecordAdditionalObjects{Secret: &secret}
+			}
+			return &found, nil
+		}
+	}
+	return nil, keyhub.ErrNotFound
+}
+
+func (f *fakeVaults) CreateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (*model.VaultRecord, error) {
+	f.created = append(f.created, vaultRecord)
+	return vaultRecord, nil
+}
+
+func (f *fakeVaults) UpdateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (*model.VaultRecord, error) {
+	f.updated = append(f.updated, vaultRecord)
+	return vaultRecord, nil
+}
+
+func newTestHelper() (*Helper, *fakeVaults) {
+	vaults := &fakeVaults{}
+	vaults.add("1", "https://git.example.com", "ci", "host")
+	vaults.add("2", "https://git.example.com/team/", "ci", "team")
+	vaults.add("3", "https://git.example.com/team/app.git", "deploy", "app")
+	vaults.add("4", "git.example.org", "ci", "other")
+	// A record KeyHub returns without a secret object
+	vaults.add("5", "https://git.example.io", "ci", "")
+	vaults.records[4].AdditionalObjects = nil
+	return &Helper{Vaults: vaults}, vaults
+}
+
+func TestReadCredential(t *testing.T) {
+	credential, err := ReadCredential(strings.NewReader("protocol=https\nhost=git.example.com\npath=team/app.git\nwwwauth[]=Basic\n\nignored=yes\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if credential.Protocol != "https" || credential.Host != "git.example.com" || credential.Path != "team/app.git" {
+		t.Errorf("Result differs, got `%+v`", credential)
+	}
+
+	credential, err = ReadCredential(strings.NewReader("url=https://ci@git.example.com:8443/team\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if credential.Host != "git.example.com:8443" || credential.Path != "team" || credential.Username != "ci" {
+		t.Errorf("Result differs, got `%+v`", credential)
+	}
+
+	if _, err := ReadCredential(strings.NewReader("nonsense\n")); err == nil {
+		t.Errorf("Expected an error for a line without =")
+	}
+}
+
+func TestGet(t *testing.T) {
+	helper, _ := newTestHelper()
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"protocol=https\nhost=git.example.com\npath=team/app.git\nusername=ci\n", "protocol=https\nhost=git.example.com\npath=team/app.git\nusername=ci\npassword=team\n"},
+		{"protocol=https\nhost=git.example.com\npath=team/app.git\n", "protocol=https\nhost=git.example.com\npath=team/app.git\nusername=deploy\npassword=app\n"},
+		{"protocol=https\nhost=GIT.example.com\npath=other/repo.git\n", "protocol=https\nhost=GIT.example.com\npath=other/repo.git\nusername=ci\npassword=host\n"},
+		{"protocol=https\nhost=git.example.org\n", "protocol=https\nhost=git.example.org\nusername=ci\npassword=other\n"},
+		{"protocol=http\nhost=git.example.com\n", ""},
+		{"protocol=https\nhost=git.example.com\nusername=nobody\n", ""},
+		{"protocol=https\nhost=git.example.io\n", ""},
+	}
+
+	for _, c := range cases {
+		var out bytes.Buffer
+		if err := helper.Serve(context.Background(), "get", strings.NewReader(c.input), &out); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if out.String() != c.want {
+			t.Errorf("Result differs for %q, want `%s`, got `%s`", c.input, c.want, out.String())
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	helper, vaults := newTestHelper()
+	input := "protocol=https\nhost=git.example.com\npath=team/app.git\nusername=deploy\npassword=new\n"
+
+	if err := helper.Serve(context.Background(), "store", strings.NewReader(input), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(vaults.updated) != 0 || len(vaults.created) != 0 {
+		t.Errorf("Store without group changed records")
+	}
+
+	group := model.NewEmptyGroup("git")
+	group.Links = []model.Link{{Rel: "self", Href: groupHref}}
+	helper.Group = group
+
+	if err := helper.Serve(context.Background(), "store", strings.NewReader(input), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(vaults.updated) != 1 || vaults.updated[0].UUID != "3" || *vaults.updated[0].Password() != "new" {
+		t.Errorf("Result differs, want record 3 updated, got `%+v`", vaults.updated)
+	}
+
+	input = "protocol=https\nhost=git.example.io\nusername=ci\npassword=first\n"
+	if err := helper.Serve(context.Background(), "store", strings.NewReader(input), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(vaults.updated) != 2 || vaults.updated[1].UUID != "5" || *vaults.updated[1].Password() != "first" {
+		t.Errorf("Result differs, want record 5 updated, got `%+v`", vaults.updated)
+	}
+
+	input = "protocol=https\nhost=git.example.net\npath=app.git\nusername=deploy\npassword=created\n"
+	if err := helper.Serve(context.Background(), "store", strings.NewReader(input), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(vaults.created) != 1 {
+		t.Fatalf("Result differs, want 1 created record, got %d", len(vaults.created))
+	}
+	created := vaults.created[0]
+	if created.Name != "git.example.net/app.git" || created.URL != "https://git.example.net/app.git" || created.Username != "deploy" || *created.Password() != "created" {
+		t.Errorf("Result differs, got `%+v`", created)
+	}
+
+	if err := helper.Serve(context.Background(), "erase", strings.NewReader(input), &bytes.Buffer{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if err := helper.Serve(context.Background(), "unknown", strings.NewReader(input), &bytes.Buffer{}); err == nil {
+		t.Errorf("Expected an error for an unknown operation")
+	}
+}
@@ -16,8 +16,9 @@
 package keyhub
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"iter"
 	"strconv"
 
 	"github.com/dghubble/sling"
@@ -36,12 +37,16 @@ func newGroupService(sling *sling.Sling) *GroupService {
 }
 
 func (s *GroupService) Create(group *model.Group) (result *model.Group, err error) {
+	return s.CreateContext(context.Background(), group)
+}
+
+func (s *GroupService) CreateContext(ctx context.Context, group *model.Group) (result *model.Group, err error) {
 	groups := new(model.GroupList)
 	results := new(model.GroupList)
 	errorReport := new(model.ErrorReport)
 	groups.Items = append(groups.Items, *group)
 
-	_, err = s.sling.New().Post("").BodyProvider(khJsonBodyProvider{payload: groups}).Receive(results, errorReport)
+	_, err = receive(ctx, s.sling.New().Post("").BodyProvider(khJsonBodyProvider{payload: groups}), results, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not create Group.")
 	}
@@ -57,12 +62,16 @@ func (s *GroupService) Create(group *model.Group) (result *model.Group, err erro
 }
 
 func (s *GroupService) CreateMembership(group *model.Group, list *model.GroupAccountList) (results *model.GroupAccountList, err error) {
+	return s.CreateMembershipContext(context.Background(), group, list)
+}
+
+func (s *GroupService) CreateMembershipContext(ctx context.Context, group *model.Group, list *model.GroupAccountList) (results *model.GroupAccountList, err error) {
 
 	idString := strconv.FormatInt(group.Self().ID, 10)
 
 	errorReport := new(model.ErrorReport)
 
-	_, err = s.sling.New().Post(idString+"/account").BodyProvider(khJsonBodyProvider{payload: list}).Receive(results, errorReport)
+	_, err = receive(ctx, s.sling.New().Post(idString+"/account").BodyProvider(khJsonBodyProvider{payload: list}), results, errorReport)
 
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not create memberschip.")
@@ -76,34 +85,25 @@ func (s *GroupService) CreateMembership(group *model.Group, list *model.GroupAcc
 	return
 }
 
-func (s *GroupService) List() (groups []model.Group, err error) {
-	groups = []model.Group{}
-	searchRange := model.NewRange()
-
-	var response *http.Response
-
-	for ok := true; ok; ok = searchRange.NextPage() {
-
-		errorReport := new(model.ErrorReport)
-		results := new(model.GroupList)
-		response, err = s.sling.New().Get("").Add(searchRange.GetRequestRangeHeader()).Add(searchRange.GetRequestModeHeader()).Receive(results, errorReport)
-		searchRange.ParseResponse(response)
-
-		if errorReport.Code > 0 {
-			err = errorReport.Wrap("Could not get Groups.")
-		}
-		if err == nil {
-			if len(results.Items) > 0 {
-				groups = append(groups, results.Items...)
-			}
-		}
+func (s *GroupService) List(opts ...ListOption) (groups []model.Group, err error) {
+	return s.ListContext(context.Background(), opts...)
+}
 
-	}
+// ListContext Retrieve all groups, no further pages are fetched once ctx is done
+func (s *GroupService) ListContext(ctx context.Context, opts ...ListOption) (groups []model.Group, err error) {
+	return collect(s.All(ctx, nil, opts...))
+}
 
-	return
+// All Iterate all groups matching query, pages are fetched while ranging over the groups
+func (s *GroupService) All(ctx context.Context, query *model.GroupQueryParams, opts ...ListOption) iter.Seq2[model.Group, error] {
+	return paginate[model.Group](ctx, s.sling.New().Get("").QueryStruct(query), opts, "Could not get Groups.")
 }
 
 func (s *GroupService) GetByUUID(uuid uuid.UUID) (result *model.Group, err error) {
+	return s.GetByUUIDContext(context.Background(), uuid)
+}
+
+func (s *GroupService) GetByUUIDContext(ctx context.Context, uuid uuid.UUID) (result *model.Group, err error) {
 	results := new(model.GroupList)
 	errorReport := new(model.ErrorReport)
 
@@ -112,7 +112,7 @@ func (s *GroupService) GetByUUID(uuid uuid.UUID) (result *model.Group, err error
 		Additional: &model.GroupAdditionalQueryParams{Admins: true},
 	}
 
-	_, err = s.sling.New().Get("").QueryStruct(params).Receive(results, errorReport)
+	_, err = receive(ctx, s.sling.New().Get("").QueryStruct(params), results, errorReport)
 
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get Group %q.", uuid.String())
@@ -121,7 +121,7 @@ func (s *GroupService) GetByUUID(uuid uuid.UUID) (result *model.Group, err error
 		if len(results.Items) > 0 {
 			result = &results.Items[0]
 		} else {
-			err = fmt.Errorf("Group %q not found", uuid.String())
+			err = fmt.Errorf("Group %q %w", uuid.String(), ErrNotFound)
 		}
 	}
 
@@ -129,6 +129,10 @@ func (s *GroupService) GetByUUID(uuid uuid.UUID) (result *model.Group, err error
 }
 
 func (s *GroupService) GetById(id int64) (result *model.Group, err error) {
+	return s.GetByIdContext(context.Background(), id)
+}
+
+func (s *GroupService) GetByIdContext(ctx context.Context, id int64) (result *model.Group, err error) {
 	al := new(model.Group)
 	errorReport := new(model.ErrorReport)
 	idString := strconv.FormatInt(id, 10)
@@ -137,13 +141,13 @@ func (s *GroupService) GetById(id int64) (result *model.Group, err error) {
 		Additional: &model.GroupAdditionalQueryParams{Admins: true},
 	}
 
-	_, err = s.sling.New().Get(idString).QueryStruct(params).Receive(al, errorReport)
+	_, err = receive(ctx, s.sling.New().Get(idString).QueryStruct(params), al, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get Group %q.", idString)
 		return
 	}
 	if err == nil && al == nil {
-		err = fmt.Errorf("Group %q not found", idString)
+		err = fmt.Errorf("Group %q %w", idString, ErrNotFound)
 		return
 	}
 
@@ -0,0 +1,114 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Bitwarden item types
+const (
+	bitwardenLogin      = 1
+	bitwardenSecureNote = 2
+	bitwardenCard       = 3
+	bitwardenIdentity   = 4
+	bitwardenSSHKey     = 5
+)
+
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	FolderID string `json:"folderId"`
+	Type     int    `json:"type"`
+	Name     string `json:"name"`
+	Notes    string `json:"notes"`
+	Fields   []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"fields"`
+	Login *struct {
+		URIs []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Totp     string `json:"totp"`
+	} `json:"login"`
+	PasswordHistory []json.RawMessage `json:"passwordHistory"`
+}
+
+// ReadBitwarden Read the entries of an unencrypted Bitwarden JSON export
+func ReadBitwarden(r io.Reader) ([]Entry, error) {
+	export := bitwardenExport{}
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid Bitwarden export: %w", err)
+	}
+	if export.Encrypted {
+		return nil, errors.New("encrypted Bitwarden exports are not supported, export as unencrypted JSON")
+	}
+
+	folders := make(map[string]string)
+	for _, folder := range export.Folders {
+		folders[folder.ID] = folder.Name
+	}
+
+	entries := make([]Entry, 0, len(export.Items))
+	for _, item := range export.Items {
+		entry := Entry{Name: item.Name, Folder: folders[item.FolderID], Notes: item.Notes}
+
+		switch item.Type {
+		case bitwardenLogin, bitwardenSecureNote:
+		case bitwardenCard:
+			entry.Skipped = append(entry.Skipped, "card")
+		case bitwardenIdentity:
+			entry.Skipped = append(entry.Skipped, "identity")
+		case bitwardenSSHKey:
+			entry.Skipped = append(entry.Skipped, "ssh key")
+		default:
+			entry.Skipped = append(entry.Skipped, fmt.Sprintf("item type %d", item.Type))
+		}
+
+		if login := item.Login; login != nil {
+			entry.Username, entry.Password, entry.TOTP = login.Username, login.Password, login.Totp
+			for i, uri := range login.URIs {
+				if i == 0 {
+					entry.URL = uri.URI
+				} else if uri.URI != "" {
+					entry.Skipped = append(entry.Skipped, "uri "+uri.URI)
+				}
+			}
+		}
+		for _, field := range item.Fields {
+			entry.Skipped = append(entry.Skipped, "field "+field.Name)
+		}
+		if len(item.PasswordHistory) > 0 {
+			entry.Skipped = append(entry.Skipped, "password history")
+		}
+
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,113 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvField The field of an entry a column holds
+type csvField int
+
+const (
+	// csvIgnored Column that is not imported and not reported, e.g. a favorite flag
+	csvIgnored csvField = iota + 1
+	csvName
+	csvFolder
+	csvURL
+	csvUsername
+	csvPassword
+	csvTOTP
+	csvNotes
+)
+
+// readCSV Read a CSV export with a header row, columns are matched case insensitively. Values of unknown columns are
+// reported as skipped, an export without name column fails.
+func readCSV(r io.Reader, columns map[string]csvField) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV export: %w", err)
+	}
+
+	fields := make([]csvField, len(header))
+	hasName := false
+	for i, name := range header {
+		// Strip a byte order mark
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		fields[i] = columns[header[i]]
+		hasName = hasName || fields[i] == csvName
+	}
+	if !hasName {
+		return nil, fmt.Errorf("CSV export without a name column: %s", strings.Join(header, ","))
+	}
+
+	var entries []Entry
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV export: %w", err)
+		}
+
+		entry := Entry{}
+		for i, value := range row {
+			field := csvField(0)
+			if i < len(fields) {
+				field = fields[i]
+			}
+
+			switch field {
+			case csvIgnored:
+			case csvName:
+				entry.Name = value
+			case csvFolder:
+				entry.Folder = strings.ReplaceAll(value, `\`, "/")
+			case csvURL:
+				entry.URL = value
+			case csvUsername:
+				entry.Username = value
+			case csvPassword:
+				entry.Password = value
+			case csvTOTP:
+				entry.TOTP = value
+			case csvNotes:
+				entry.Notes = value
+			default:
+				if value == "" {
+					continue
+				}
+				if i < len(header) {
+					entry.Skipped = append(entry.Skipped, header[i])
+				} else {
+					entry.Skipped = append(entry.Skipped, fmt.Sprintf("column %d", i+1))
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+}
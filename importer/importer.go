@@ -0,0 +1,215 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Package importer Imports the entries of other password managers into a KeyHub group.
+// The Read functions parse an export into entries, Importer creates a vault record for every entry and reports the
+// fields that were left out or shortened.
+package importer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+// Entry An entry of another password manager
+type Entry struct {
+	Name string
+	// Folder Path of the folder, group or vault holding the entry in the source, it is only used in the report
+	Folder   string
+	URL      string
+	Username string
+	Password string
+	// TOTP A base32 secret or an otpauth:// URL
+	TOTP  string
+	Notes string
+	// Filename Name of the attachment, a vault record holds at most one
+	Filename string
+	File     []byte
+	// Skipped Names of the fields of the source entry that have no place in a vault record
+	Skipped []string
+}
+
+// path Return the name of the entry including its folder
+func (e *Entry) path() string {
+	if e.Folder == "" {
+		return e.Name
+	}
+	return e.Folder + "/" + e.Name
+}
+
+// IssueKind Kind of a problem with a field of an entry
+type IssueKind string
+
+const (
+	// IssueSkipped The field was not imported
+	IssueSkipped IssueKind = "skipped"
+	// IssueTruncated The field was shortened to the limit of the vault record
+	IssueTruncated IssueKind = "truncated"
+	// IssueFailed The entry could not be created
+	IssueFailed IssueKind = "failed"
+)
+
+// Issue A problem with a field of an entry, Entry is the folder and name of the entry in the source
+type Issue struct {
+	Entry  string
+	Field  string
+	Kind   IssueKind
+	Detail string
+}
+
+func (i Issue) String() string {
+	text := fmt.Sprintf("%s: %s %s", i.Entry, i.Field, i.Kind)
+	if i.Detail != "" {
+		text += " (" + i.Detail + ")"
+	}
+	return text
+}
+
+// Report The outcome of an import
+type Report struct {
+	// Created Number of records created, or that would be created in a dry run
+	Created int
+	Issues  []Issue
+}
+
+// Limits Maximum lengths of the fields of a vault record, in characters for text and in bytes for File.
+// Longer text is truncated, a longer file is skipped. Passwords and TOTP secrets are never shortened.
+type Limits struct {
+	Name     int
+	URL      int
+	Username int
+	Filename int
+	Comment  int
+	File     int
+}
+
+// DefaultLimits The limits used when an Importer has none
+var DefaultLimits = Limits{Name: 255, URL: 255, Username: 255, Filename: 255, Comment: 10000, File: 1 << 20}
+
+// Creator Creates vault records, implemented by keyhub.VaultService
+type Creator interface {
+	CreateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (*model.VaultRecord, error)
+}
+
+// Importer Creates vault records for entries
+type Importer struct {
+	Vaults Creator
+	// Limits Defaults to DefaultLimits
+	Limits *Limits
+	// DryRun Report what would be imported without creating records
+	DryRun bool
+}
+
+// Import Create a vault record in group for every entry. An entry that cannot be created is reported as failed and
+// the import continues, only a done ctx stops it.
+func (i *Importer) Import(ctx context.Context, group *model.Group, entries []Entry) (*Report, error) {
+	limits := DefaultLimits
+	if i.Limits != nil {
+		limits = *i.Limits
+	}
+
+	report := &Report{}
+	for index := range entries {
+		entry := &entries[index]
+		record, issues := entry.Record(limits)
+		report.Issues = append(report.Issues, issues...)
+
+		if !i.DryRun {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			if _, err := i.Vaults.CreateContext(ctx, group, record); err != nil {
+				if ctx.Err() != nil {
+					return report, ctx.Err()
+				}
+				report.Issues = append(report.Issues, Issue{Entry: entry.path(), Field: "record", Kind: IssueFailed, Detail: err.Error()})
+				continue
+			}
+		}
+		report.Created++
+	}
+	return report, nil
+}
+
+// Record Map the entry to a vault record within limits, reporting the fields that were skipped or truncated
+func (e *Entry) Record(limits Limits) (*model.VaultRecord, []Issue) {
+	var issues []Issue
+	issue := func(field string, kind IssueKind, detail string) {
+		issues = append(issues, Issue{Entry: e.path(), Field: field, Kind: kind, Detail: detail})
+	}
+	for _, field := range e.Skipped {
+		issue(field, IssueSkipped, "")
+	}
+	truncate := func(field string, value string, limit int) string {
+		if utf8.RuneCountInString(value) <= limit {
+			return value
+		}
+		issue(field, IssueTruncated, fmt.Sprintf("%d of %d characters kept", limit, utf8.RuneCountInString(value)))
+		return string([]rune(value)[:limit])
+	}
+
+	secret := &model.VaultRecordSecretAdditionalObject{}
+	if e.Password != "" {
+		password := e.Password
+		secret.Password = &password
+	}
+	if e.TOTP != "" {
+		if _, err := model.ParseTOTP(e.TOTP); err != nil {
+			issue("totp", IssueSkipped, err.Error())
+		} else {
+			totp := e.TOTP
+			secret.Totp = &totp
+		}
+	}
+	if e.Notes != "" {
+		comment := truncate("notes", e.Notes, limits.Comment)
+		secret.Comment = &comment
+	}
+
+	record := model.NewVaultRecord(truncate("name", e.name(), limits.Name), secret)
+	record.URL = truncate("url", e.URL, limits.URL)
+	record.Username = truncate("username", e.Username, limits.Username)
+
+	if len(e.File) > 0 {
+		if len(e.File) > limits.File {
+			issue("attachment "+e.Filename, IssueSkipped, fmt.Sprintf("%d bytes exceeds the limit of %d", len(e.File), limits.File))
+		} else {
+			file := e.File
+			secret.File = &file
+			record.Filename = truncate("filename", e.Filename, limits.Filename)
+		}
+	}
+
+	return record, issues
+}
+
+// name Return the name of the entry, falling back to the host of its URL or its username
+func (e *Entry) name() string {
+	if name := strings.TrimSpace(e.Name); name != "" {
+		return name
+	}
+	if u, err := url.Parse(e.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	if e.Username != "" {
+		return e.Username
+	}
+	return "Untitled"
+}
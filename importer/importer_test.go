@@ -0,0 +1,258 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub"
+	"github.com/topicuskeyhub/go-keyhub/kdbx"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+var _ Creator = (*keyhub.VaultService)(nil)
+
+type fakeCreator struct {
+	created []*model.VaultRecord
+}
+
+func (f *fakeCreator) CreateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (*model.VaultRecord, error) {
+	if vaultRecord.Name == "fails" {
+		return nil, errors.New("name already in use")
+	}
+	f.created = append(f.created, vaultRecord)
+	return vaultRecord, nil
+}
+
+func TestRecord(t *testing.T) {
+	entry := Entry{
+		Name:     "Database",
+		Folder:   "Team/Prod",
+		URL:      "https://db.example.com/" + strings.Repeat("x", 300),
+		Username: "app",
+		Password: strings.Repeat("p", 500),
+		TOTP:     "not base32!",
+		Notes:    "notes",
+		Filename: "ca.pem",
+		File:     []byte("certificate"),
+		Skipped:  []string{"field pin"},
+	}
+
+	record, issues := entry.Record(DefaultLimits)
+	if record.Name != "Database" || record.Username != "app" || len([]rune(record.URL)) != 255 || record.Filename != "ca.pem" {
+		t.Errorf("Result differs, got `%+v`", record)
+	}
+	if *record.Password() != entry.Password || *record.Comment() != "notes" || record.Totp() != nil || string(*record.AdditionalObjects.Secret.File) != "certificate" {
+		t.Errorf("Result differs, got `%+v`", record.AdditionalObjects.Secret)
+	}
+
+	var kinds []string
+	for _, issue := range issues {
+		if issue.Entry != "Team/Prod/Database" {
+			t.Errorf("Result differs, want entry Team/Prod/Database, got %s", issue.Entry)
+		}
+		kinds = append(kinds, issue.Field+" "+string(issue.Kind))
+	}
+	if want := []string{"field pin skipped", "totp skipped", "url truncated"}; !slices.Equal(kinds, want) {
+		t.Errorf("Result differs, want %v, got %v", want, kinds)
+	}
+
+	record, issues = (&Entry{URL: "https://vpn.example.com/login", File: []byte("large")}).Record(Limits{Name: 10, URL: 100, File: 4})
+	if record.Name != "vpn.exampl" || record.AdditionalObjects.Secret.File != nil || len(issues) != 2 {
+		t.Errorf("Result differs, got `%+v` with %v", record, issues)
+	}
+}
+
+func TestImport(t *testing.T) {
+	creator := &fakeCreator{}
+	importer := &Importer{Vaults: creator, DryRun: true}
+	entries := []Entry{{Name: "one"}, {Name: "fails"}, {Name: "two", Skipped: []string{"tags"}}}
+
+	report, err := importer.Import(context.Background(), model.NewEmptyGroup("target"), entries)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if report.Created != 3 || len(creator.created) != 0 || len(report.Issues) != 1 {
+		t.Errorf("Result differs, want a dry run of 3 records, got `%+v`", report)
+	}
+
+	importer.DryRun = false
+	report, err = importer.Import(context.Background(), model.NewEmptyGroup("target"), entries)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if report.Created != 2 || len(creator.created) != 2 || len(report.Issues) != 2 || report.Issues[0].Kind != IssueFailed {
+		t.Errorf("Result differs, want 2 records and a failure, got `%+v`", report)
+	}
+	if report.Issues[0].String() != "fails: record failed (name already in use)" {
+		t.Errorf("Result differs, got %s", report.Issues[0])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := importer.Import(ctx, model.NewEmptyGroup("target"), entries); !errors.Is(err, context.Canceled) {
+		t.Errorf("Result differs, want context.Canceled, got %v", err)
+	}
+}
+
+func TestKeePassEntries(t *testing.T) {
+	recycleBin := uuid.New()
+	database := &kdbx.Database{
+		RecycleBin: &recycleBin,
+		Root: kdbx.Group{Name: "Root",
+			Entries: []kdbx.Entry{{
+				Tags: "prod",
+				Strings: []kdbx.String{
+					{Key: kdbx.KeyTitle, Value: "Database"}, {Key: kdbx.KeyUserName, Value: "app"}, {Key: kdbx.KeyPassword, Value: "s3cret"},
+					{Key: kdbx.KeyURL, Value: "https://db.example.com"}, {Key: kdbx.KeyNotes, Value: "notes"},
+					{Key: "otp", Value: "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ"}, {Key: "PIN", Value: "1234"}, {Key: "Empty"},
+				},
+				Attachments: []kdbx.Attachment{{Name: "ca.pem", Data: []byte("ca")}, {Name: "key.pem", Data: []byte("key")}},
+			}},
+			Groups: []kdbx.Group{
+				{Name: "Team", Groups: []kdbx.Group{{Name: "Prod", Entries: []kdbx.Entry{{Strings: []kdbx.String{{Key: kdbx.KeyTitle, Value: "Nested"}}}}}}},
+				{UUID: recycleBin, Name: "Recycle Bin", Entries: []kdbx.Entry{{Strings: []kdbx.String{{Key: kdbx.KeyTitle, Value: "Deleted"}}}}},
+			},
+		},
+	}
+
+	entries := keePassEntries(database)
+	if len(entries) != 2 || entries[1].Name != "Nested" || entries[1].Folder != "Team/Prod" {
+		t.Fatalf("Result differs, got `%+v`", entries)
+	}
+	entry := entries[0]
+	if entry.Name != "Database" || entry.Username != "app" || entry.Password != "s3cret" || entry.URL != "https://db.example.com" ||
+		entry.Notes != "notes" || entry.TOTP != "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ" || entry.Filename != "ca.pem" {
+		t.Errorf("Result differs, got `%+v`", entry)
+	}
+	if want := []string{"PIN", "tags", "attachment key.pem"}; !slices.Equal(entry.Skipped, want) {
+		t.Errorf("Result differs, want %v, got %v", want, entry.Skipped)
+	}
+}
+
+func TestReadBitwarden(t *testing.T) {
+	export := `{"encrypted": false,
+		"folders": [{"id": "f1", "name": "Infra"}],
+		"items": [
+			{"id": "1", "folderId": "f1", "type": 1, "name": "Router", "notes": null,
+			 "fields": [{"name": "pin", "value": "1", "type": 1}],
+			 "login": {"uris": [{"match": null, "uri": "https://router"}, {"match": null, "uri": "https://router.local"}],
+			           "username": "admin", "password": "pw", "totp": "GEZDGNBVGY3TQOJQ"},
+			 "passwordHistory": [{"lastUsedDate": "2024-01-01T00:00:00Z", "password": "old"}]},
+			{"id": "2", "folderId": null, "type": 2, "name": "Note", "notes": "text", "secureNote": {"type": 0}},
+			{"id": "3", "folderId": null, "type": 3, "name": "Card", "card": {"number": "4111"}}
+		]}`
+
+	entries, err := ReadBitwarden(strings.NewReader(export))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Result differs, want 3 entries, got %d", len(entries))
+	}
+	router := entries[0]
+	if router.Folder != "Infra" || router.URL != "https://router" || router.Username != "admin" || router.Password != "pw" || router.TOTP != "GEZDGNBVGY3TQOJQ" {
+		t.Errorf("Result differs, got `%+v`", router)
+	}
+	if want := []string{"uri https://router.local", "field pin", "password history"}; !slices.Equal(router.Skipped, want) {
+		t.Errorf("Result differs, want %v, got %v", want, router.Skipped)
+	}
+	if entries[1].Notes != "text" || len(entries[1].Skipped) != 0 || !slices.Equal(entries[2].Skipped, []string{"card"}) {
+		t.Errorf("Result differs, got `%+v`", entries[1:])
+	}
+
+	if _, err := ReadBitwarden(strings.NewReader(`{"encrypted": true, "items": []}`)); err == nil {
+		t.Errorf("Expected an error for an encrypted export")
+	}
+}
+
+func TestRead1PUX(t *testing.T) {
+	data := `{"accounts": [{"attrs": {"accountName": "Company"}, "vaults": [{"attrs": {"name": "Shared"}, "items": [
+		{"uuid": "a", "state": "active",
+		 "overview": {"title": "Mail", "url": "https://mail.example.com", "urls": [{"label": "", "url": "https://mail.example.com"}], "tags": []},
+		 "details": {
+			"loginFields": [{"value": "jdoe", "name": "username", "fieldType": "T", "designation": "username"},
+			                {"value": "pw", "name": "password", "fieldType": "P", "designation": "password"},
+			                {"value": "", "name": "remember", "fieldType": "C"}],
+			"notesPlain": "mailbox",
+			"sections": [{"title": "", "fields": [
+				{"title": "one-time password", "id": "TOTP_1", "value": {"totp": "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ"}},
+				{"title": "recovery", "id": "r", "value": {"concealed": "code"}},
+				{"title": "empty", "id": "e", "value": {"string": ""}},
+				{"title": "key", "id": "k", "value": {"file": {"fileName": "key.txt", "documentId": "doc1", "decryptedSize": 3}}}
+			]}],
+			"passwordHistory": []}},
+		{"uuid": "b", "state": "archived", "overview": {"title": "Old"}, "details": {}}
+	]}]}]}`
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for name, content := range map[string]string{"export.attributes": `{"version": 3}`, "export.data": data, "files/doc1__key.txt": "key"} {
+		w, _ := writer.Create(name)
+		w.Write([]byte(content))
+	}
+	writer.Close()
+
+	entries, err := Read1PUX(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Result differs, want 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Name != "Mail" || entry.Folder != "Shared" || entry.URL != "https://mail.example.com" || entry.Username != "jdoe" || entry.Password != "pw" ||
+		entry.Notes != "mailbox" || entry.TOTP != "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ" || entry.Filename != "key.txt" || string(entry.File) != "key" {
+		t.Errorf("Result differs, got `%+v`", entry)
+	}
+	if want := []string{"field recovery"}; !slices.Equal(entry.Skipped, want) {
+		t.Errorf("Result differs, want %v, got %v", want, entry.Skipped)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	onePassword := "\ufeffTitle,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes\n" +
+		"Wiki,https://wiki,jdoe,pw,,false,false,docs,\"multi\nline\"\n"
+	entries, err := Read1PasswordCSV(strings.NewReader(onePassword))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(entries) != 1 || entries[0].Name != "Wiki" || entries[0].Notes != "multi\nline" || !slices.Equal(entries[0].Skipped, []string{"tags"}) {
+		t.Errorf("Result differs, got `%+v`", entries)
+	}
+
+	lastPass := "url,username,password,totp,extra,name,grouping,fav\n" +
+		"https://vpn,jdoe,pw,GEZDGNBVGY3TQOJQ,,VPN,Work\\Infra,0\n" +
+		"http://sn,,,,secret note,Note,,1\n"
+	entries, err = ReadLastPass(strings.NewReader(lastPass))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(entries) != 2 || entries[0].Folder != "Work/Infra" || entries[0].TOTP != "GEZDGNBVGY3TQOJQ" || entries[1].URL != "" || entries[1].Notes != "secret note" {
+		t.Errorf("Result differs, got `%+v`", entries)
+	}
+
+	if _, err := ReadLastPass(strings.NewReader("a,b\n1,2\n")); err == nil {
+		t.Errorf("Expected an error for an export without name column")
+	}
+}
@@ -0,0 +1,103 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package importer
+
+import (
+	"io"
+	"strings"
+
+	"github.com/topicuskeyhub/go-keyhub/kdbx"
+)
+
+// keePassTOTPKeys Fields holding a TOTP secret: KeePassXC, KeePass 2.47 and the KeeOtp plugin
+var keePassTOTPKeys = []string{"otp", "TimeOtp-Secret-Base32", "TOTP Seed"}
+
+// ReadKeePass Read the entries of a KeePass KDBX 4 database protected by a master password. Entries in the recycle
+// bin and the history of entries are left out.
+func ReadKeePass(r io.Reader, password string) ([]Entry, error) {
+	database, err := kdbx.Open(r, password)
+	if err != nil {
+		return nil, err
+	}
+	return keePassEntries(database), nil
+}
+
+// keePassEntries Return the entries of database, folders are the paths of the groups below the root group
+func keePassEntries(database *kdbx.Database) []Entry {
+	var entries []Entry
+	var walk func(group *kdbx.Group, folder string)
+	walk = func(group *kdbx.Group, folder string) {
+		if database.RecycleBin != nil && group.UUID == *database.RecycleBin {
+			return
+		}
+		for i := range group.Entries {
+			entries = append(entries, keePassEntry(&group.Entries[i], folder))
+		}
+		for i := range group.Groups {
+			sub := &group.Groups[i]
+			walk(sub, strings.TrimPrefix(folder+"/"+sub.Name, "/"))
+		}
+	}
+	walk(&database.Root, "")
+	return entries
+}
+
+func keePassEntry(source *kdbx.Entry, folder string) Entry {
+	entry := Entry{Folder: folder}
+	for _, s := range source.Strings {
+		switch {
+		case s.Key == kdbx.KeyTitle:
+			entry.Name = s.Value
+		case s.Key == kdbx.KeyUserName:
+			entry.Username = s.Value
+		case s.Key == kdbx.KeyPassword:
+			entry.Password = s.Value
+		case s.Key == kdbx.KeyURL:
+			entry.URL = s.Value
+		case s.Key == kdbx.KeyNotes:
+			entry.Notes = s.Value
+		case entry.TOTP == "" && isTOTPKey(s.Key):
+			entry.TOTP = s.Value
+		case strings.HasPrefix(s.Key, "TimeOtp-") || strings.HasPrefix(s.Key, "TOTP "):
+			// Settings of the TOTP field, e.g. TimeOtp-Length, KeyHub reads them from an otpauth:// URL only
+			if s.Value != "" {
+				entry.Skipped = append(entry.Skipped, s.Key)
+			}
+		case s.Value != "":
+			entry.Skipped = append(entry.Skipped, s.Key)
+		}
+	}
+	if source.Tags != "" {
+		entry.Skipped = append(entry.Skipped, "tags")
+	}
+	for i, attachment := range source.Attachments {
+		if i == 0 {
+			entry.Filename, entry.File = attachment.Name, attachment.Data
+			continue
+		}
+		entry.Skipped = append(entry.Skipped, "attachment "+attachment.Name)
+	}
+	return entry
+}
+
+func isTOTPKey(key string) bool {
+	for _, totpKey := range keePassTOTPKeys {
+		if key == totpKey {
+			return true
+		}
+	}
+	return false
+}
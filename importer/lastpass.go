@@ -0,0 +1,48 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package importer
+
+import (
+	"io"
+)
+
+// lastPassSecureNote URL LastPass gives secure notes
+const lastPassSecureNote = "http://sn"
+
+var lastPassColumns = map[string]csvField{
+	"url":      csvURL,
+	"username": csvUsername,
+	"password": csvPassword,
+	"totp":     csvTOTP,
+	"extra":    csvNotes,
+	"name":     csvName,
+	"grouping": csvFolder,
+	"fav":      csvIgnored,
+}
+
+// ReadLastPass Read the entries of a LastPass CSV export
+func ReadLastPass(r io.Reader) ([]Entry, error) {
+	entries, err := readCSV(r, lastPassColumns)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].URL == lastPassSecureNote {
+			entries[i].URL = ""
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,234 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// onePasswordColumns Columns of the CSV exports of 1Password 7 and 8
+var onePasswordColumns = map[string]csvField{
+	"title":             csvName,
+	"url":               csvURL,
+	"website":           csvURL,
+	"username":          csvUsername,
+	"password":          csvPassword,
+	"otpauth":           csvTOTP,
+	"one-time password": csvTOTP,
+	"notes":             csvNotes,
+	"notesplain":        csvNotes,
+	"favorite":          csvIgnored,
+	"archived":          csvIgnored,
+	"type":              csvIgnored,
+}
+
+// Read1PasswordCSV Read the entries of a 1Password CSV export
+func Read1PasswordCSV(r io.Reader) ([]Entry, error) {
+	return readCSV(r, onePasswordColumns)
+}
+
+type onePasswordExport struct {
+	Accounts []struct {
+		Vaults []struct {
+			Attrs struct {
+				Name string `json:"name"`
+			} `json:"attrs"`
+			Items []onePasswordItem `json:"items"`
+		} `json:"vaults"`
+	} `json:"accounts"`
+}
+
+type onePasswordItem struct {
+	State    string `json:"state"`
+	Overview struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+		URLs  []struct {
+			URL string `json:"url"`
+		} `json:"urls"`
+		Tags []string `json:"tags"`
+	} `json:"overview"`
+	Details struct {
+		LoginFields []struct {
+			Value       string `json:"value"`
+			Name        string `json:"name"`
+			Designation string `json:"designation"`
+		} `json:"loginFields"`
+		NotesPlain string `json:"notesPlain"`
+		Password   string `json:"password"`
+		Sections   []struct {
+			Fields []struct {
+				Title string                     `json:"title"`
+				ID    string                     `json:"id"`
+				Value map[string]json.RawMessage `json:"value"`
+			} `json:"fields"`
+		} `json:"sections"`
+		PasswordHistory    []json.RawMessage    `json:"passwordHistory"`
+		DocumentAttributes *onePasswordDocument `json:"documentAttributes"`
+	} `json:"details"`
+}
+
+type onePasswordDocument struct {
+	FileName   string `json:"fileName"`
+	DocumentID string `json:"documentId"`
+}
+
+// Read1PUX Read the entries of a 1Password 1PUX export, a zip archive of size bytes. Archived items are left out.
+func Read1PUX(r io.ReaderAt, size int64) ([]Entry, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 1PUX export: %w", err)
+	}
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	data, ok := files["export.data"]
+	if !ok {
+		return nil, fmt.Errorf("invalid 1PUX export: export.data is missing")
+	}
+	export := onePasswordExport{}
+	if err := readZipJSON(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid 1PUX export: %w", err)
+	}
+
+	var entries []Entry
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			for _, item := range vault.Items {
+				if item.State == "archived" {
+					continue
+				}
+				entry, err := onePasswordEntry(&item, vault.Attrs.Name, files)
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries, nil
+}
+
+func onePasswordEntry(item *onePasswordItem, vault string, files map[string]*zip.File) (Entry, error) {
+	details := &item.Details
+	entry := Entry{Name: item.Overview.Title, Folder: vault, URL: item.Overview.URL, Notes: details.NotesPlain, Password: details.Password}
+
+	for _, u := range item.Overview.URLs {
+		if u.URL != "" && u.URL != entry.URL {
+			entry.Skipped = append(entry.Skipped, "url "+u.URL)
+		}
+	}
+	if len(item.Overview.Tags) > 0 {
+		entry.Skipped = append(entry.Skipped, "tags")
+	}
+
+	for _, field := range details.LoginFields {
+		switch {
+		case field.Designation == "username" && entry.Username == "":
+			entry.Username = field.Value
+		case field.Designation == "password" && entry.Password == "":
+			entry.Password = field.Value
+		case field.Value != "":
+			entry.Skipped = append(entry.Skipped, "field "+field.Name)
+		}
+	}
+
+	var documents []onePasswordDocument
+	if details.DocumentAttributes != nil {
+		documents = append(documents, *details.DocumentAttributes)
+	}
+	for _, section := range details.Sections {
+		for _, field := range section.Fields {
+			title := field.Title
+			if title == "" {
+				title = field.ID
+			}
+			if raw, ok := field.Value["totp"]; ok && entry.TOTP == "" {
+				if err := json.Unmarshal(raw, &entry.TOTP); err != nil {
+					return Entry{}, fmt.Errorf("invalid 1PUX export: %w", err)
+				}
+				continue
+			}
+			if raw, ok := field.Value["file"]; ok {
+				document := onePasswordDocument{}
+				if err := json.Unmarshal(raw, &document); err != nil {
+					return Entry{}, fmt.Errorf("invalid 1PUX export: %w", err)
+				}
+				documents = append(documents, document)
+				continue
+			}
+			if !emptyOnePasswordValue(field.Value) {
+				entry.Skipped = append(entry.Skipped, "field "+title)
+			}
+		}
+	}
+	if len(details.PasswordHistory) > 0 {
+		entry.Skipped = append(entry.Skipped, "password history")
+	}
+
+	for i, document := range documents {
+		if i > 0 {
+			entry.Skipped = append(entry.Skipped, "attachment "+document.FileName)
+			continue
+		}
+		file, ok := files["files/"+document.DocumentID+"__"+document.FileName]
+		if !ok {
+			entry.Skipped = append(entry.Skipped, "attachment "+document.FileName)
+			continue
+		}
+		data, err := readZipFile(file)
+		if err != nil {
+			return Entry{}, fmt.Errorf("invalid 1PUX export: %w", err)
+		}
+		entry.Filename, entry.File = document.FileName, data
+	}
+	return entry, nil
+}
+
+// emptyOnePasswordValue Return true when the typed value of a field, e.g. {"string": ""}, holds nothing
+func emptyOnePasswordValue(value map[string]json.RawMessage) bool {
+	for _, raw := range value {
+		switch strings.TrimSpace(string(raw)) {
+		case `""`, "null", "{}", "0":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func readZipJSON(file *zip.File, v any) error {
+	data, err := readZipFile(file)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,285 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.golang file.
+
+// Adapted from golang.org/x/crypto/argon2 to add the Argon2d variant, the Go Authors' notice above applies to this
+// file instead of the Apache License of the rest of this repository.
+
+package kdbx
+
+import (
+	"encoding/binary"
+	"hash"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// golang.org/x/crypto/argon2 only offers Argon2i and Argon2id, KeePass defaults to Argon2d. This is its implementation
+// of Argon2 version 1.3 (RFC 9106) for the d and id variants, lanes are processed one after the other.
+
+const (
+	argon2d  = 0
+	argon2id = 2
+
+	argon2Version = 0x13
+	blockLength   = 128
+	syncPoints    = 4
+)
+
+type block [blockLength]uint64
+
+// argon2Key Derive a key of keyLen bytes, memory is in KiB
+func argon2Key(mode int, password, salt, secret, data []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	if time < 1 || threads < 1 {
+		return nil
+	}
+
+	h0 := argon2InitHash(mode, password, salt, secret, data, time, memory, uint32(threads), keyLen)
+
+	memory = memory / (syncPoints * uint32(threads)) * (syncPoints * uint32(threads))
+	if memory < 2*syncPoints*uint32(threads) {
+		memory = 2 * syncPoints * uint32(threads)
+	}
+	B := argon2InitBlocks(&h0, memory, uint32(threads))
+	argon2ProcessBlocks(mode, B, time, memory, uint32(threads))
+	return argon2ExtractKey(B, memory, uint32(threads), keyLen)
+}
+
+func argon2InitHash(mode int, password, salt, key, data []byte, time, memory, threads, keyLen uint32) [blake2b.Size + 8]byte {
+	var (
+		h0     [blake2b.Size + 8]byte
+		params [24]byte
+		tmp    [4]byte
+	)
+
+	b2, _ := blake2b.New512(nil)
+	binary.LittleEndian.PutUint32(params[0:4], threads)
+	binary.LittleEndian.PutUint32(params[4:8], keyLen)
+	binary.LittleEndian.PutUint32(params[8:12], memory)
+	binary.LittleEndian.PutUint32(params[12:16], time)
+	binary.LittleEndian.PutUint32(params[16:20], argon2Version)
+	binary.LittleEndian.PutUint32(params[20:24], uint32(mode))
+	b2.Write(params[:])
+	for _, value := range [][]byte{password, salt, key, data} {
+		binary.LittleEndian.PutUint32(tmp[:], uint32(len(value)))
+		b2.Write(tmp[:])
+		b2.Write(value)
+	}
+	b2.Sum(h0[:0])
+	return h0
+}
+
+func argon2InitBlocks(h0 *[blake2b.Size + 8]byte, memory, threads uint32) []block {
+	var block0 [1024]byte
+	B := make([]block, memory)
+	for lane := uint32(0); lane < threads; lane++ {
+		j := lane * (memory / threads)
+		binary.LittleEndian.PutUint32(h0[blake2b.Size+4:], lane)
+		for i := uint32(0); i < 2; i++ {
+			binary.LittleEndian.PutUint32(h0[blake2b.Size:], i)
+			blake2bHash(block0[:], h0[:])
+			for k := range B[j+i] {
+				B[j+i][k] = binary.LittleEndian.Uint64(block0[k*8:])
+			}
+		}
+	}
+	return B
+}
+
+func argon2ProcessBlocks(mode int, B []block, time, memory, threads uint32) {
+	lanes := memory / threads
+	segments := lanes / syncPoints
+
+	processSegment := func(n, slice, lane uint32) {
+		var addresses, in, zero block
+		independent := n == 0 && slice < syncPoints/2 && mode == argon2id
+		if independent {
+			in[0] = uint64(n)
+			in[1] = uint64(lane)
+			in[2] = uint64(slice)
+			in[3] = uint64(len(B))
+			in[4] = uint64(time)
+			in[5] = uint64(mode)
+		}
+
+		index := uint32(0)
+		if n == 0 && slice == 0 {
+			// The first two blocks of every lane are already filled
+			index = 2
+			if independent {
+				in[6]++
+				processBlock(&addresses, &in, &zero, false)
+				processBlock(&addresses, &addresses, &zero, false)
+			}
+		}
+
+		offset := lane*lanes + slice*segments + index
+		var random uint64
+		for index < segments {
+			prev := offset - 1
+			if index == 0 && slice == 0 {
+				// Last block of the lane
+				prev += lanes
+			}
+			if independent {
+				if index%blockLength == 0 {
+					in[6]++
+					processBlock(&addresses, &in, &zero, false)
+					processBlock(&addresses, &addresses, &zero, false)
+				}
+				random = addresses[index%blockLength]
+			} else {
+				random = B[prev][0]
+			}
+			newOffset := indexAlpha(random, lanes, segments, threads, n, slice, lane, index)
+			processBlock(&B[offset], &B[prev], &B[newOffset], true)
+			index, offset = index+1, offset+1
+		}
+	}
+
+	for n := uint32(0); n < time; n++ {
+		for slice := uint32(0); slice < syncPoints; slice++ {
+			for lane := uint32(0); lane < threads; lane++ {
+				processSegment(n, slice, lane)
+			}
+		}
+	}
+}
+
+func argon2ExtractKey(B []block, memory, threads, keyLen uint32) []byte {
+	lanes := memory / threads
+	for lane := uint32(0); lane < threads-1; lane++ {
+		for i, v := range B[(lane*lanes)+lanes-1] {
+			B[memory-1][i] ^= v
+		}
+	}
+
+	var block [1024]byte
+	for i, v := range B[memory-1] {
+		binary.LittleEndian.PutUint64(block[i*8:], v)
+	}
+	key := make([]byte, keyLen)
+	blake2bHash(key, block[:])
+	return key
+}
+
+func indexAlpha(rand uint64, lanes, segments, threads, n, slice, lane, index uint32) uint32 {
+	refLane := uint32(rand>>32) % threads
+	if n == 0 && slice == 0 {
+		refLane = lane
+	}
+	m, s := 3*segments, ((slice+1)%syncPoints)*segments
+	if lane == refLane {
+		m += index
+	}
+	if n == 0 {
+		m, s = slice*segments, 0
+		if slice == 0 || lane == refLane {
+			m += index
+		}
+	}
+	if index == 0 || lane == refLane {
+		m--
+	}
+	return phi(rand, uint64(m), uint64(s), refLane, lanes)
+}
+
+func phi(rand, m, s uint64, lane, lanes uint32) uint32 {
+	p := rand & 0xFFFFFFFF
+	p = (p * p) >> 32
+	p = (p * m) >> 32
+	return lane*lanes + uint32((s+m-(p+1))%uint64(lanes))
+}
+
+// processBlock Compress in1 and in2 into out, XORing with the previous content of out when xor is set
+func processBlock(out, in1, in2 *block, xor bool) {
+	var t block
+	for i := range t {
+		t[i] = in1[i] ^ in2[i]
+	}
+	for i := 0; i < blockLength; i += 16 {
+		blamka(&t[i+0], &t[i+1], &t[i+2], &t[i+3], &t[i+4], &t[i+5], &t[i+6], &t[i+7],
+			&t[i+8], &t[i+9], &t[i+10], &t[i+11], &t[i+12], &t[i+13], &t[i+14], &t[i+15])
+	}
+	for i := 0; i < blockLength/8; i += 2 {
+		blamka(&t[i], &t[i+1], &t[16+i], &t[16+i+1], &t[32+i], &t[32+i+1], &t[48+i], &t[48+i+1],
+			&t[64+i], &t[64+i+1], &t[80+i], &t[80+i+1], &t[96+i], &t[96+i+1], &t[112+i], &t[112+i+1])
+	}
+	if xor {
+		for i := range t {
+			out[i] ^= in1[i] ^ in2[i] ^ t[i]
+		}
+	} else {
+		for i := range t {
+			out[i] = in1[i] ^ in2[i] ^ t[i]
+		}
+	}
+}
+
+func blamka(t00, t01, t02, t03, t04, t05, t06, t07, t08, t09, t10, t11, t12, t13, t14, t15 *uint64) {
+	gb(t00, t04, t08, t12)
+	gb(t01, t05, t09, t13)
+	gb(t02, t06, t10, t14)
+	gb(t03, t07, t11, t15)
+
+	gb(t00, t05, t10, t15)
+	gb(t01, t06, t11, t12)
+	gb(t02, t07, t08, t13)
+	gb(t03, t04, t09, t14)
+}
+
+// gb The BlaMka quarter round
+func gb(a, b, c, d *uint64) {
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d ^= *a
+	*d = *d>>32 | *d<<32
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b ^= *c
+	*b = *b>>24 | *b<<40
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d ^= *a
+	*d = *d>>16 | *d<<48
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b ^= *c
+	*b = *b>>63 | *b<<1
+}
+
+// blake2bHash The variable length hash function H' of Argon2
+func blake2bHash(out []byte, in []byte) {
+	var b2 hash.Hash
+	if n := len(out); n < blake2b.Size {
+		b2, _ = blake2b.New(n, nil)
+	} else {
+		b2, _ = blake2b.New512(nil)
+	}
+
+	var buffer [blake2b.Size]byte
+	binary.LittleEndian.PutUint32(buffer[:4], uint32(len(out)))
+	b2.Write(buffer[:4])
+	b2.Write(in)
+
+	if len(out) <= blake2b.Size {
+		b2.Sum(out[:0])
+		return
+	}
+
+	outLen := len(out)
+	b2.Sum(buffer[:0])
+	b2.Reset()
+	copy(out, buffer[:32])
+	out = out[32:]
+	for len(out) > blake2b.Size {
+		b2.Write(buffer[:])
+		b2.Sum(buffer[:0])
+		copy(out, buffer[:32])
+		out = out[32:]
+		b2.Reset()
+	}
+
+	if outLen%blake2b.Size > 0 {
+		r := ((outLen + 31) / 32) - 2
+		b2, _ = blake2b.New(outLen-32*r, nil)
+	}
+	b2.Write(buffer[:])
+	b2.Sum(out[:0])
+}
@@ -0,0 +1,52 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package kdbx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestArgon2d(t *testing.T) {
+	// RFC 9106 section 5.1
+	password := bytes.Repeat([]byte{0x01}, 32)
+	salt := bytes.Repeat([]byte{0x02}, 16)
+	secret := bytes.Repeat([]byte{0x03}, 8)
+	data := bytes.Repeat([]byte{0x04}, 12)
+
+	key := argon2Key(argon2d, password, salt, secret, data, 3, 32, 4, 32)
+	want := "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"
+	if hex.EncodeToString(key) != want {
+		t.Errorf("Result differs, want %s, got %x", want, key)
+	}
+}
+
+func TestArgon2id(t *testing.T) {
+	for _, c := range []struct {
+		time, memory uint32
+		threads      uint8
+		keyLen       uint32
+	}{{1, 64, 1, 32}, {3, 256, 2, 32}, {2, 1024, 4, 64}, {1, 4096, 1, 100}} {
+		key := argon2Key(argon2id, []byte("password"), []byte("somesaltsomesalt"), nil, nil, c.time, c.memory, c.threads, c.keyLen)
+		want := argon2.IDKey([]byte("password"), []byte("somesaltsomesalt"), c.time, c.memory, c.threads, c.keyLen)
+		if !bytes.Equal(key, want) {
+			t.Errorf("Result differs for %+v, want %x, got %x", c, want, key)
+		}
+	}
+}
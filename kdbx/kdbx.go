@@ -0,0 +1,644 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Package kdbx Reads and writes KeePass KDBX 4 databases protected by a master password.
+// AES-256, ChaCha20 and Twofish encrypted databases are supported with the AES-KDF, Argon2d and Argon2id key
+// derivations. Key files, Windows user accounts and the KDBX 3 layout are not supported.
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20"
+	"golang.org/x/crypto/twofish"
+)
+
+// Keys of the standard entry fields, and of the TOTP field KeePassXC uses
+const (
+	KeyTitle    = "Title"
+	KeyUserName = "UserName"
+	KeyPassword = "Password"
+	KeyURL      = "URL"
+	KeyNotes    = "Notes"
+	KeyOTP      = "otp"
+)
+
+// protectedKeys Fields Write encrypts with the inner random stream
+var protectedKeys = map[string]bool{KeyPassword: true, KeyOTP: true}
+
+const (
+	signature1   uint32 = 0x9AA2D903
+	signature2   uint32 = 0xB54BFB67
+	majorVersion        = 4
+)
+
+// Field ids of the outer and the inner header
+const (
+	headerEnd              = 0
+	headerCipherID         = 2
+	headerCompressionFlags = 3
+	headerMasterSeed       = 4
+	headerEncryptionIV     = 7
+	headerKdfParameters    = 11
+
+	innerHeaderEnd       = 0
+	innerHeaderStreamID  = 1
+	innerHeaderStreamKey = 2
+	innerHeaderBinary    = 3
+)
+
+// Ciphers of the protected values
+const (
+	innerStreamSalsa20  uint32 = 2
+	innerStreamChaCha20 uint32 = 3
+)
+
+var (
+	cipherAES256   = uuid.MustParse("31c1f2e6-bf71-4350-be58-05216afc5aff")
+	cipherChaCha20 = uuid.MustParse("d6038a2b-8b6f-4cb5-a524-339a31dbb59a")
+	cipherTwofish  = uuid.MustParse("ad68f29f-576f-4bb9-a36a-d47af965346c")
+	kdfAES         = uuid.MustParse("c9d9f39a-628a-4460-bf74-0d08c18a4fea")
+	kdfArgon2d     = uuid.MustParse("ef636ddf-8c29-444b-91f7-a9a403e30a0c")
+	kdfArgon2id    = uuid.MustParse("9e298b19-56db-4773-b23d-fc3ec6f0a1e6")
+
+	salsa20Nonce = []byte{0xE8, 0x30, 0x09, 0x4B, 0x97, 0x20, 0x5D, 0x2A}
+)
+
+// Highest Argon2 cost Open accepts, the parameters are read before the master password is verified. The memory limit
+// is the one of KeePassXC
+const (
+	maxArgon2Memory     = 2 * 1024 * 1024 // KiB
+	maxArgon2Iterations = 1000
+)
+
+var (
+	// ErrInvalidCredentials The master password is wrong, or the header of the database was modified
+	ErrInvalidCredentials = errors.New("kdbx: invalid master password or corrupted header")
+	// ErrCorrupt The database is damaged or truncated
+	ErrCorrupt = errors.New("kdbx: database is corrupt")
+)
+
+// Database The decrypted content of a KDBX file
+type Database struct {
+	Name string
+	Root Group
+	// RecycleBin UUID of the group holding deleted entries, nil when the recycle bin is disabled
+	RecycleBin *uuid.UUID
+}
+
+// Group A folder of entries
+type Group struct {
+	UUID    uuid.UUID
+	Name    string
+	Notes   string
+	Groups  []Group
+	Entries []Entry
+}
+
+// Entry An entry with its fields in the order of the database, the history of the entry is left out
+type Entry struct {
+	UUID uuid.UUID
+	Tags string
+	// Expiry Moment the entry expires, zero when it does not
+	Expiry      time.Time
+	Strings     []String
+	Attachments []Attachment
+}
+
+// String A field of an entry
+type String struct {
+	Key   string
+	Value string
+}
+
+// Attachment A file attached to an entry
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+// Get Return the value of the field with key, or an empty string
+func (e *Entry) Get(key string) string {
+	for _, s := range e.Strings {
+		if s.Key == key {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// Open Read and decrypt a KDBX 4 database with its master password
+func Open(r io.Reader, password string) (*Database, error) {
+	header, fields, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var storedHash, storedHMAC [32]byte
+	if _, err := io.ReadFull(r, storedHash[:]); err != nil {
+		return nil, ErrCorrupt
+	}
+	if _, err := io.ReadFull(r, storedHMAC[:]); err != nil {
+		return nil, ErrCorrupt
+	}
+	if sha256.Sum256(header) != storedHash {
+		return nil, ErrCorrupt
+	}
+
+	masterSeed := fields[headerMasterSeed]
+	if len(masterSeed) != 32 {
+		return nil, fmt.Errorf("%w: invalid master seed", ErrCorrupt)
+	}
+	kdf, err := readVariantDictionary(fields[headerKdfParameters])
+	if err != nil {
+		return nil, err
+	}
+	transformedKey, err := transformKey(compositeKey(password), kdf)
+	if err != nil {
+		return nil, err
+	}
+	encryptionKey, hmacKey := deriveKeys(masterSeed, transformedKey)
+
+	if !hmac.Equal(headerHMAC(hmacKey, header), storedHMAC[:]) {
+		return nil, ErrInvalidCredentials
+	}
+
+	payload, err := readBlocks(r, hmacKey)
+	if err != nil {
+		return nil, err
+	}
+	payload, err = decrypt(fields[headerCipherID], encryptionKey, fields[headerEncryptionIV], payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields[headerCompressionFlags]) != 4 {
+		return nil, fmt.Errorf("%w: invalid compression flags", ErrCorrupt)
+	}
+	if binary.LittleEndian.Uint32(fields[headerCompressionFlags]) == 1 {
+		gz, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+		if payload, err = io.ReadAll(gz); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+	}
+
+	inner := bytes.NewReader(payload)
+	streamID, streamKey, binaries, err := readInnerHeader(inner)
+	if err != nil {
+		return nil, err
+	}
+	document, err := io.ReadAll(inner)
+	if err != nil {
+		return nil, err
+	}
+	xorStream, err := innerStream(streamID, streamKey)
+	if err != nil {
+		return nil, err
+	}
+	document, err = unprotect(document, xorStream)
+	if err != nil {
+		return nil, err
+	}
+
+	file := xmlFile{}
+	if err := xml.Unmarshal(document, &file); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+	return file.database(binaries)
+}
+
+// readHeader Return the raw outer header, for the checksums, and its fields by id
+func readHeader(r io.Reader) ([]byte, map[byte][]byte, error) {
+	var raw bytes.Buffer
+	tee := io.TeeReader(r, &raw)
+
+	var start [12]byte
+	if _, err := io.ReadFull(tee, start[:]); err != nil {
+		return nil, nil, fmt.Errorf("kdbx: not a KeePass database: %w", err)
+	}
+	if binary.LittleEndian.Uint32(start[0:4]) != signature1 || binary.LittleEndian.Uint32(start[4:8]) != signature2 {
+		return nil, nil, errors.New("kdbx: not a KeePass database")
+	}
+	if major := binary.LittleEndian.Uint32(start[8:12]) >> 16; major != majorVersion {
+		return nil, nil, fmt.Errorf("kdbx: unsupported KDBX version %d, only version 4 is supported", major)
+	}
+
+	fields := make(map[byte][]byte)
+	for {
+		var fieldHeader [5]byte
+		if _, err := io.ReadFull(tee, fieldHeader[:]); err != nil {
+			return nil, nil, ErrCorrupt
+		}
+		size := binary.LittleEndian.Uint32(fieldHeader[1:5])
+		if size > 1<<20 {
+			return nil, nil, ErrCorrupt
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(tee, data); err != nil {
+			return nil, nil, ErrCorrupt
+		}
+		if fieldHeader[0] == headerEnd {
+			return raw.Bytes(), fields, nil
+		}
+		fields[fieldHeader[0]] = data
+	}
+}
+
+// readVariantDictionary Parse the KDF parameters, values are returned in their binary little endian form
+func readVariantDictionary(data []byte) (map[string][]byte, error) {
+	if len(data) < 2 || data[1] != 1 {
+		return nil, fmt.Errorf("%w: unsupported KDF parameters", ErrCorrupt)
+	}
+	data = data[2:]
+
+	values := make(map[string][]byte)
+	for len(data) > 0 {
+		valueType := data[0]
+		if valueType == 0 {
+			return values, nil
+		}
+		if len(data) < 5 {
+			break
+		}
+		keyLength := int(binary.LittleEndian.Uint32(data[1:5]))
+		if keyLength < 0 || len(data) < 5+keyLength+4 {
+			break
+		}
+		key := string(data[5 : 5+keyLength])
+		data = data[5+keyLength:]
+		valueLength := int(binary.LittleEndian.Uint32(data[0:4]))
+		if valueLength < 0 || len(data) < 4+valueLength {
+			break
+		}
+		values[key] = data[4 : 4+valueLength]
+		data = data[4+valueLength:]
+	}
+	return nil, fmt.Errorf("%w: truncated KDF parameters", ErrCorrupt)
+}
+
+// compositeKey Return the composite key of a database protected by a master password only
+func compositeKey(password string) []byte {
+	passwordHash := sha256.Sum256([]byte(password))
+	key := sha256.Sum256(passwordHash[:])
+	return key[:]
+}
+
+// transformKey Run the key derivation function of the database on the composite key
+func transformKey(key []byte, kdf map[string][]byte) ([]byte, error) {
+	kdfUUID, err := uuid.FromBytes(kdf["$UUID"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing KDF", ErrCorrupt)
+	}
+
+	switch kdfUUID {
+	case kdfAES:
+		seed := kdf["S"]
+		if len(seed) != 32 || len(kdf["R"]) != 8 {
+			return nil, fmt.Errorf("%w: invalid AES-KDF parameters", ErrCorrupt)
+		}
+		block, err := aes.NewCipher(seed)
+		if err != nil {
+			return nil, err
+		}
+		transformed := bytes.Clone(key)
+		for rounds := binary.LittleEndian.Uint64(kdf["R"]); rounds > 0; rounds-- {
+			block.Encrypt(transformed[0:16], transformed[0:16])
+			block.Encrypt(transformed[16:32], transformed[16:32])
+		}
+		hash := sha256.Sum256(transformed)
+		return hash[:], nil
+	case kdfArgon2d, kdfArgon2id:
+		if len(kdf["S"]) == 0 || len(kdf["I"]) != 8 || len(kdf["M"]) != 8 || len(kdf["P"]) != 4 || len(kdf["V"]) != 4 {
+			return nil, fmt.Errorf("%w: invalid Argon2 parameters", ErrCorrupt)
+		}
+		if version := binary.LittleEndian.Uint32(kdf["V"]); version != argon2Version {
+			return nil, fmt.Errorf("kdbx: unsupported Argon2 version %#x", version)
+		}
+		iterations := binary.LittleEndian.Uint64(kdf["I"])
+		memory := binary.LittleEndian.Uint64(kdf["M"]) / 1024
+		parallelism := binary.LittleEndian.Uint32(kdf["P"])
+		if iterations < 1 || parallelism < 1 || parallelism > math.MaxUint8 {
+			return nil, fmt.Errorf("%w: invalid Argon2 parameters", ErrCorrupt)
+		}
+		if iterations > maxArgon2Iterations || memory > maxArgon2Memory {
+			return nil, fmt.Errorf("%w: Argon2 cost of %d iterations and %d KiB exceeds the supported %d iterations and %d KiB",
+				ErrCorrupt, iterations, memory, maxArgon2Iterations, maxArgon2Memory)
+		}
+		mode := argon2d
+		if kdfUUID == kdfArgon2id {
+			mode = argon2id
+		}
+		return argon2Key(mode, key, kdf["S"], kdf["K"], kdf["A"], uint32(iterations), uint32(memory), uint8(parallelism), 32), nil
+	}
+	return nil, fmt.Errorf("kdbx: unsupported KDF %s", kdfUUID)
+}
+
+// deriveKeys Return the key of the payload cipher and the key of the block HMACs
+func deriveKeys(masterSeed []byte, transformedKey []byte) (encryptionKey []byte, hmacKey []byte) {
+	encryption := sha256.Sum256(append(bytes.Clone(masterSeed), transformedKey...))
+	mac := sha512.Sum512(append(append(bytes.Clone(masterSeed), transformedKey...), 1))
+	return encryption[:], mac[:]
+}
+
+// headerHMAC Return the HMAC of the outer header, keyed as the block with index MaxUint64
+func headerHMAC(hmacKey []byte, header []byte) []byte {
+	key := blockKey(hmacKey, math.MaxUint64)
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(header)
+	return mac.Sum(nil)
+}
+
+// blockHMAC Return the HMAC of the block with index, covering the index followed by the block
+func blockHMAC(hmacKey []byte, index uint64, data []byte) []byte {
+	key := blockKey(hmacKey, index)
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(binary.LittleEndian.AppendUint64(nil, index))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// blockKey Return the HMAC key of the block with index
+func blockKey(hmacKey []byte, index uint64) [sha512.Size]byte {
+	return sha512.Sum512(append(binary.LittleEndian.AppendUint64(nil, index), hmacKey...))
+}
+
+// readBlocks Read and verify the HMAC block stream holding the encrypted payload
+func readBlocks(r io.Reader, hmacKey []byte) ([]byte, error) {
+	var payload bytes.Buffer
+	for index := uint64(0); ; index++ {
+		var blockHeader [36]byte
+		if _, err := io.ReadFull(r, blockHeader[:]); err != nil {
+			return nil, fmt.Errorf("%w: truncated block %d", ErrCorrupt, index)
+		}
+		size := binary.LittleEndian.Uint32(blockHeader[32:36])
+		if size > math.MaxInt32 {
+			return nil, ErrCorrupt
+		}
+		data := make([]byte, 4+int(size))
+		copy(data, blockHeader[32:36])
+		if _, err := io.ReadFull(r, data[4:]); err != nil {
+			return nil, fmt.Errorf("%w: truncated block %d", ErrCorrupt, index)
+		}
+		if !hmac.Equal(blockHMAC(hmacKey, index, data), blockHeader[0:32]) {
+			return nil, fmt.Errorf("%w: block %d was modified", ErrCorrupt, index)
+		}
+		if size == 0 {
+			return payload.Bytes(), nil
+		}
+		payload.Write(data[4:])
+	}
+}
+
+// decrypt Decrypt the payload with the cipher of the database
+func decrypt(cipherID []byte, key []byte, iv []byte, payload []byte) ([]byte, error) {
+	id, err := uuid.FromBytes(cipherID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing cipher", ErrCorrupt)
+	}
+
+	var block cipher.Block
+	switch id {
+	case cipherChaCha20:
+		stream, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+		stream.XORKeyStream(payload, payload)
+		return payload, nil
+	case cipherAES256:
+		block, err = aes.NewCipher(key)
+	case cipherTwofish:
+		block, err = twofish.NewCipher(key)
+	default:
+		return nil, fmt.Errorf("kdbx: unsupported cipher %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(iv) != block.BlockSize() || len(payload) == 0 || len(payload)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("%w: invalid payload length", ErrCorrupt)
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(payload, payload)
+
+	padding := int(payload[len(payload)-1])
+	if padding < 1 || padding > block.BlockSize() {
+		return nil, fmt.Errorf("%w: invalid padding", ErrCorrupt)
+	}
+	return payload[:len(payload)-padding], nil
+}
+
+// readInnerHeader Return the inner random stream and the attachments of the database
+func readInnerHeader(r io.Reader) (streamID uint32, streamKey []byte, binaries [][]byte, err error) {
+	for {
+		var fieldHeader [5]byte
+		if _, err := io.ReadFull(r, fieldHeader[:]); err != nil {
+			return 0, nil, nil, fmt.Errorf("%w: truncated inner header", ErrCorrupt)
+		}
+		size := binary.LittleEndian.Uint32(fieldHeader[1:5])
+		if size > math.MaxInt32 {
+			return 0, nil, nil, ErrCorrupt
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return 0, nil, nil, fmt.Errorf("%w: truncated inner header", ErrCorrupt)
+		}
+
+		switch fieldHeader[0] {
+		case innerHeaderEnd:
+			return streamID, streamKey, binaries, nil
+		case innerHeaderStreamID:
+			if len(data) != 4 {
+				return 0, nil, nil, ErrCorrupt
+			}
+			streamID = binary.LittleEndian.Uint32(data)
+		case innerHeaderStreamKey:
+			streamKey = data
+		case innerHeaderBinary:
+			if len(data) < 1 {
+				return 0, nil, nil, ErrCorrupt
+			}
+			// The first byte holds flags
+			binaries = append(binaries, data[1:])
+		}
+	}
+}
+
+// innerStream Return the function XORing protected values with the inner random stream. It must be called once with
+// all protected values concatenated in document order.
+func innerStream(id uint32, key []byte) (func(dst, src []byte), error) {
+	switch id {
+	case innerStreamChaCha20:
+		hash := sha512.Sum512(key)
+		stream, err := chacha20.NewUnauthenticatedCipher(hash[:32], hash[32:44])
+		if err != nil {
+			return nil, err
+		}
+		return stream.XORKeyStream, nil
+	case innerStreamSalsa20:
+		hash := sha256.Sum256(key)
+		return func(dst, src []byte) {
+			salsa20.XORKeyStream(dst, src, salsa20Nonce, &hash)
+		}, nil
+	}
+	return nil, fmt.Errorf("kdbx: unsupported inner random stream %d", id)
+}
+
+// unprotect Return document with the protected values decrypted and their Protected attribute removed
+func unprotect(document []byte, xorStream func(dst, src []byte)) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+
+	var (
+		tokens []xml.Token
+		// protected Positions in tokens of the protected values, with their length
+		protected []int
+		lengths   []int
+		encrypted []byte
+		value     *bytes.Buffer
+	)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+		token = xml.CopyToken(token)
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "Value" && hasProtectedAttr(t) {
+				t.Attr = nil
+				token = t
+				value = new(bytes.Buffer)
+			}
+		case xml.CharData:
+			if value != nil {
+				value.Write(t)
+				continue
+			}
+		case xml.EndElement:
+			if value != nil {
+				decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(value.Bytes())))
+				if err != nil {
+					return nil, fmt.Errorf("%w: invalid protected value", ErrCorrupt)
+				}
+				protected = append(protected, len(tokens))
+				lengths = append(lengths, len(decoded))
+				encrypted = append(encrypted, decoded...)
+				tokens = append(tokens, xml.CharData(nil))
+				value = nil
+			}
+		}
+		tokens = append(tokens, token)
+	}
+
+	xorStream(encrypted, encrypted)
+	for i, position := range protected {
+		tokens[position] = xml.CharData(encrypted[:lengths[i]])
+		encrypted = encrypted[lengths[i]:]
+	}
+
+	var out bytes.Buffer
+	encoder := xml.NewEncoder(&out)
+	for _, token := range tokens {
+		if procInst, ok := token.(xml.ProcInst); ok && procInst.Target == "xml" {
+			// The encoder only accepts version 1.0 declarations, the document is known to be UTF-8
+			continue
+		}
+		if err := encoder.EncodeToken(token); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// protect Return document with the values marked Protected encrypted in document order
+func protect(document []byte, xorStream func(dst, src []byte)) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	var out bytes.Buffer
+	encoder := xml.NewEncoder(&out)
+
+	var value *bytes.Buffer
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "Value" && hasProtectedAttr(t) {
+				value = new(bytes.Buffer)
+			}
+		case xml.CharData:
+			if value != nil {
+				value.Write(t)
+				continue
+			}
+		case xml.EndElement:
+			if value != nil {
+				encrypted := value.Bytes()
+				xorStream(encrypted, encrypted)
+				if err := encoder.EncodeToken(xml.CharData(base64.StdEncoding.EncodeToString(encrypted))); err != nil {
+					return nil, err
+				}
+				value = nil
+			}
+		}
+		if err := encoder.EncodeToken(token); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func hasProtectedAttr(element xml.StartElement) bool {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == "Protected" && attr.Value == "True" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,579 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/twofish"
+)
+
+var (
+	rootUUID       = uuid.MustParse("00000000-0000-0000-0000-0000000000e0")
+	entryUUID      = uuid.MustParse("00000000-0000-0000-0000-0000000000e1")
+	recycleBinUUID = uuid.MustParse("00000000-0000-0000-0000-0000000000e2")
+)
+
+func encodeUUID(id uuid.UUID) string {
+	return base64.StdEncoding.EncodeToString(id[:])
+}
+
+// testDocument Values marked Protected are in plain text, writeTestDatabase encrypts them
+var testDocument = `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Meta>
+		<DatabaseName>Team</DatabaseName>
+		<RecycleBinEnabled>True</RecycleBinEnabled>
+		<RecycleBinUUID>` + encodeUUID(recycleBinUUID) + `</RecycleBinUUID>
+	</Meta>
+	<Root>
+		<Group>
+			<UUID>` + encodeUUID(rootUUID) + `</UUID>
+			<Name>Root</Name>
+			<Entry>
+				<UUID>` + encodeUUID(entryUUID) + `</UUID>
+				<Tags>db</Tags>
+				<String><Key>Title</Key><Value>Database</Value></String>
+				<String><Key>UserName</Key><Value>app</Value></String>
+				<String><Key>Password</Key><Value Protected="True">s3cr&lt;et</Value></String>
+				<String><Key>URL</Key><Value>https://db.example.com</Value></String>
+				<String><Key>Notes</Key><Value>line 1
+line 2</Value></String>
+				<Binary><Key>ca.pem</Key><Value Ref="0"/></Binary>
+				<History>
+					<Entry>
+						<UUID>` + encodeUUID(entryUUID) + `</UUID>
+						<String><Key>Password</Key><Value Protected="True">previous</Value></String>
+					</Entry>
+				</History>
+			</Entry>
+			<Entry>
+				<UUID>` + encodeUUID(uuid.New()) + `</UUID>
+				<String><Key>Title</Key><Value>Empty</Value></String>
+				<String><Key>Password</Key><Value Protected="True"></Value></String>
+				<String><Key>otp</Key><Value Protected="True">otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ</Value></String>
+			</Entry>
+			<Group>
+				<UUID>` + encodeUUID(recycleBinUUID) + `</UUID>
+				<Name>Recycle Bin</Name>
+			</Group>
+		</Group>
+	</Root>
+</KeePassFile>`
+
+type testOptions struct {
+	cipher   uuid.UUID
+	kdf      map[string][]byte
+	compress bool
+}
+
+func aesKdf() map[string][]byte {
+	rounds := make([]byte, 8)
+	binary.LittleEndian.PutUint64(rounds, 100)
+	return map[string][]byte{"$UUID": kdfAES[:], "S": randomBytes(32), "R": rounds}
+}
+
+func argon2Kdf(id uuid.UUID) map[string][]byte {
+	iterations, memory, parallelism, version := make([]byte, 8), make([]byte, 8), make([]byte, 4), make([]byte, 4)
+	binary.LittleEndian.PutUint64(iterations, 2)
+	binary.LittleEndian.PutUint64(memory, 64*1024)
+	binary.LittleEndian.PutUint32(parallelism, 2)
+	binary.LittleEndian.PutUint32(version, argon2Version)
+	return map[string][]byte{"$UUID": id[:], "S": randomBytes(32), "I": iterations, "M": memory, "P": parallelism, "V": version}
+}
+
+func randomBytes(n int) []byte {
+	data := make([]byte, n)
+	_, _ = rand.Read(data)
+	return data
+}
+
+func writeField(w *bytes.Buffer, id byte, data []byte) {
+	var fieldHeader [5]byte
+	fieldHeader[0] = id
+	binary.LittleEndian.PutUint32(fieldHeader[1:], uint32(len(data)))
+	w.Write(fieldHeader[:])
+	w.Write(data)
+}
+
+// writeTestDatabase Encrypt document as a KDBX 4 database
+func writeTestDatabase(t *testing.T, document string, password string, binaries [][]byte, options testOptions) []byte {
+	var header bytes.Buffer
+	binary.Write(&header, binary.LittleEndian, []uint32{signature1, signature2, majorVersion << 16})
+
+	masterSeed := randomBytes(32)
+	iv := randomBytes(16)
+	if options.cipher == cipherChaCha20 {
+		iv = randomBytes(12)
+	}
+	compression := make([]byte, 4)
+	if options.compress {
+		compression[0] = 1
+	}
+	var kdf bytes.Buffer
+	kdf.Write([]byte{0, 1})
+	for key, value := range options.kdf {
+		kdf.WriteByte(0x42)
+		binary.Write(&kdf, binary.LittleEndian, uint32(len(key)))
+		kdf.WriteString(key)
+		binary.Write(&kdf, binary.LittleEndian, uint32(len(value)))
+		kdf.Write(value)
+	}
+	kdf.WriteByte(0)
+
+	writeField(&header, headerCipherID, options.cipher[:])
+	writeField(&header, headerCompressionFlags, compression)
+	writeField(&header, headerMasterSeed, masterSeed)
+	writeField(&header, headerEncryptionIV, iv)
+	writeField(&header, headerKdfParameters, kdf.Bytes())
+	writeField(&header, headerEnd, []byte("\r\n\r\n"))
+
+	transformedKey, err := transformKey(compositeKey(password), options.kdf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	encryptionKey, hmacKey := deriveKeys(masterSeed, transformedKey)
+
+	var payload bytes.Buffer
+	streamID, streamKey := make([]byte, 4), randomBytes(64)
+	binary.LittleEndian.PutUint32(streamID, innerStreamChaCha20)
+	writeField(&payload, innerHeaderStreamID, streamID)
+	writeField(&payload, innerHeaderStreamKey, streamKey)
+	for _, b := range binaries {
+		writeField(&payload, innerHeaderBinary, append([]byte{0}, b...))
+	}
+	writeField(&payload, innerHeaderEnd, nil)
+	xorStream, _ := innerStream(innerStreamChaCha20, streamKey)
+	payload.Write(protectTestDocument(t, document, xorStream))
+
+	plain := payload.Bytes()
+	if options.compress {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		gz.Write(plain)
+		gz.Close()
+		plain = compressed.Bytes()
+	}
+
+	var encrypted []byte
+	switch options.cipher {
+	case cipherChaCha20:
+		stream, _ := chacha20.NewUnauthenticatedCipher(encryptionKey, iv)
+		encrypted = make([]byte, len(plain))
+		stream.XORKeyStream(encrypted, plain)
+	default:
+		var block cipher.Block
+		if options.cipher == cipherTwofish {
+			block, _ = twofish.NewCipher(encryptionKey)
+		} else {
+			block, _ = aes.NewCipher(encryptionKey)
+		}
+		padding := block.BlockSize() - len(plain)%block.BlockSize()
+		encrypted = append(bytes.Clone(plain), bytes.Repeat([]byte{byte(padding)}, padding)...)
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+	}
+
+	out := bytes.NewBuffer(bytes.Clone(header.Bytes()))
+	headerHash := sha256.Sum256(header.Bytes())
+	out.Write(headerHash[:])
+	out.Write(headerHMAC(hmacKey, header.Bytes()))
+	for index, data := range [][]byte{encrypted, nil} {
+		sized := binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+		sized = append(sized, data...)
+		out.Write(blockHMAC(hmacKey, uint64(index), sized))
+		out.Write(sized)
+	}
+	return out.Bytes()
+}
+
+// protectTestDocument Encrypt the values marked Protected in document order
+func protectTestDocument(t *testing.T, document string, xorStream func(dst, src []byte)) []byte {
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	var out bytes.Buffer
+	encoder := xml.NewEncoder(&out)
+	protected := false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		switch tok := token.(type) {
+		case xml.ProcInst:
+			continue
+		case xml.StartElement:
+			protected = tok.Name.Local == "Value" && hasProtectedAttr(tok)
+		case xml.CharData:
+			if protected {
+				value := bytes.Clone(tok)
+				xorStream(value, value)
+				token = xml.CharData(base64.StdEncoding.EncodeToString(value))
+			}
+		case xml.EndElement:
+			protected = false
+		}
+		if err := encoder.EncodeToken(token); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+	encoder.Flush()
+	return out.Bytes()
+}
+
+func TestOpen(t *testing.T) {
+	for name, options := range map[string]testOptions{
+		"aes":      {cipher: cipherAES256, kdf: aesKdf()},
+		"chacha20": {cipher: cipherChaCha20, kdf: argon2Kdf(kdfArgon2d), compress: true},
+		"twofish":  {cipher: cipherTwofish, kdf: argon2Kdf(kdfArgon2id)},
+	} {
+		data := writeTestDatabase(t, testDocument, "master", [][]byte{[]byte("-----BEGIN CERTIFICATE-----")}, options)
+
+		database, err := Open(bytes.NewReader(data), "master")
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", name, err.Error())
+		}
+		if database.Name != "Team" || database.RecycleBin == nil || *database.RecycleBin != recycleBinUUID {
+			t.Errorf("Result differs for %s, got `%+v`", name, database)
+		}
+		root := database.Root
+		if root.UUID != rootUUID || len(root.Entries) != 2 || len(root.Groups) != 1 || root.Groups[0].Name != "Recycle Bin" {
+			t.Fatalf("Result differs for %s, got `%+v`", name, root)
+		}
+
+		entry := root.Entries[0]
+		if entry.UUID != entryUUID || entry.Tags != "db" || entry.Get(KeyTitle) != "Database" || entry.Get(KeyUserName) != "app" ||
+			entry.Get(KeyURL) != "https://db.example.com" || entry.Get(KeyNotes) != "line 1\nline 2" {
+			t.Errorf("Result differs for %s, got `%+v`", name, entry)
+		}
+		if entry.Get(KeyPassword) != "s3cr<et" {
+			t.Errorf("Result differs for %s, want password `s3cr<et`, got `%s`", name, entry.Get(KeyPassword))
+		}
+		if len(entry.Attachments) != 1 || entry.Attachments[0].Name != "ca.pem" || string(entry.Attachments[0].Data) != "-----BEGIN CERTIFICATE-----" {
+			t.Errorf("Result differs for %s, got `%+v`", name, entry.Attachments)
+		}
+
+		// The protected values after the history of the first entry depend on the stream order
+		second := root.Entries[1]
+		if second.Get(KeyPassword) != "" || second.Get("otp") != "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ" {
+			t.Errorf("Result differs for %s, got `%+v`", name, second)
+		}
+	}
+}
+
+func TestOpenFailures(t *testing.T) {
+	data := writeTestDatabase(t, testDocument, "master", [][]byte{{1}}, testOptions{cipher: cipherAES256, kdf: aesKdf()})
+
+	if _, err := Open(bytes.NewReader(data), "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Result differs, want ErrInvalidCredentials, got %v", err)
+	}
+
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)-60] ^= 1
+	if _, err := Open(bytes.NewReader(tampered), "master"); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Result differs, want ErrCorrupt, got %v", err)
+	}
+
+	if _, err := Open(bytes.NewReader(data[:len(data)-10]), "master"); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Result differs, want ErrCorrupt for a truncated file, got %v", err)
+	}
+
+	if _, err := Open(strings.NewReader("not a database"), "master"); err == nil {
+		t.Errorf("Expected an error for a file that is no database")
+	}
+}
+
+func TestArgon2Limits(t *testing.T) {
+	for name, tc := range map[string]struct {
+		key   string
+		value uint64
+	}{
+		"memory":     {"M", (maxArgon2Memory + 1) * 1024},
+		"terabytes":  {"M", 1 << 42},
+		"iterations": {"I", maxArgon2Iterations + 1},
+		"maximum":    {"I", math.MaxUint64},
+	} {
+		kdf := argon2Kdf(kdfArgon2d)
+		binary.LittleEndian.PutUint64(kdf[tc.key], tc.value)
+		if _, err := transformKey(compositeKey("master"), kdf); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("Result differs for %s, want ErrCorrupt, got %v", name, err)
+		}
+	}
+
+	kdf := argon2Kdf(kdfArgon2d)
+	binary.LittleEndian.PutUint32(kdf["P"], 0)
+	if _, err := transformKey(compositeKey("master"), kdf); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Result differs for parallelism 0, want ErrCorrupt, got %v", err)
+	}
+
+	if err := Write(io.Discard, &Database{}, "master", Argon2Parameters{Iterations: 2, Memory: maxArgon2Memory + 1, Parallelism: 1}); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Result differs, want an error for Write beyond the limits, got %v", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	expiry := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+	database := &Database{Name: "Emergency", Root: Group{UUID: rootUUID, Name: "Emergency", Groups: []Group{{
+		UUID: uuid.New(),
+		Name: "Team",
+		Entries: []Entry{{
+			UUID:   entryUUID,
+			Expiry: expiry,
+			Strings: []String{
+				{Key: KeyTitle, Value: "Database"}, {Key: KeyPassword, Value: "s3cr<et"}, {Key: KeyNotes, Value: "line 1\r\nline 2"},
+				{Key: KeyOTP, Value: "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ"}, {Key: "KeyHub UUID", Value: entryUUID.String()},
+			},
+			Attachments: []Attachment{{Name: "ca.pem", Data: randomBytes(3 * blockSize)}},
+		}, {
+			UUID:    uuid.New(),
+			Strings: []String{{Key: KeyTitle, Value: "Second"}, {Key: KeyPassword, Value: "other"}},
+		}},
+	}}}}
+
+	var out bytes.Buffer
+	if err := Write(&out, database, "master", Argon2Parameters{Iterations: 1, Memory: 64, Parallelism: 1}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if bytes.Contains(out.Bytes(), []byte("s3cr")) {
+		t.Fatalf("Result differs, the password is readable in the file")
+	}
+
+	read, err := Open(bytes.NewReader(out.Bytes()), "master")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if read.Name != "Emergency" || read.RecycleBin != nil || len(read.Root.Groups) != 1 || len(read.Root.Groups[0].Entries) != 2 {
+		t.Fatalf("Result differs, got `%+v`", read)
+	}
+	entry := read.Root.Groups[0].Entries[0]
+	if entry.UUID != entryUUID || !entry.Expiry.Equal(expiry) || entry.Get(KeyPassword) != "s3cr<et" || entry.Get(KeyNotes) != "line 1\r\nline 2" ||
+		entry.Get(KeyOTP) != "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ" || entry.Get("KeyHub UUID") != entryUUID.String() {
+		t.Errorf("Result differs, got `%+v`", entry.Strings)
+	}
+	if len(entry.Attachments) != 1 || !bytes.Equal(entry.Attachments[0].Data, database.Root.Groups[0].Entries[0].Attachments[0].Data) {
+		t.Errorf("Result differs, the attachment was not kept")
+	}
+	if second := read.Root.Groups[0].Entries[1]; second.Get(KeyPassword) != "other" || !second.Expiry.IsZero() {
+		t.Errorf("Result differs, got `%+v`", second)
+	}
+
+	if _, err := Open(bytes.NewReader(out.Bytes()), "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Result differs, want ErrInvalidCredentials, got %v", err)
+	}
+}
+
+// TestOpenIndependent Read a database written by testdata/generate.js, which shares no code with this package
+func TestOpenIndependent(t *testing.T) {
+	data, err := os.ReadFile("testdata/independent.kdbx")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	database, err := Open(bytes.NewReader(data), "master")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if database.Name != "Independent" || len(database.Root.Entries) != 1 {
+		t.Fatalf("Result differs, got `%+v`", database)
+	}
+	entry := database.Root.Entries[0]
+	if entry.Get(KeyTitle) != "Database" || entry.Get(KeyUserName) != "app" || entry.Get(KeyPassword) != "s3cr<et" ||
+		entry.Get(KeyOTP) != "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ" {
+		t.Errorf("Result differs, got `%+v`", entry.Strings)
+	}
+	if len(entry.Attachments) != 1 || entry.Attachments[0].Name != "ca.pem" || string(entry.Attachments[0].Data) != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("Result differs, got `%+v`", entry.Attachments)
+	}
+}
+
+// TestWriteIndependent Decrypt the output of Write following https://keepass.info/help/kb/kdbx_4.html without the
+// readers of this package, only the Argon2d implementation is shared and TestArgon2d checks it against RFC 9106.
+//
+// KeePassXC compatibility is checked by hand: run this test with KDBX_WRITE_FILE=/tmp/write.kdbx, then
+// `keepassxc-cli show -s /tmp/write.kdbx Team/Database` and
+// `keepassxc-cli attachment-export /tmp/write.kdbx Team/Database ca.pem /dev/stdout` with the master password "master".
+func TestWriteIndependent(t *testing.T) {
+	database := &Database{Name: "Independent", Root: Group{UUID: rootUUID, Name: "Independent", Groups: []Group{{
+		UUID: uuid.New(),
+		Name: "Team",
+		Entries: []Entry{{
+			UUID: entryUUID,
+			Strings: []String{
+				{Key: KeyTitle, Value: "Database"}, {Key: KeyPassword, Value: "s3cr<et"},
+				{Key: KeyOTP, Value: "otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ"},
+			},
+			Attachments: []Attachment{{Name: "ca.pem", Data: []byte("-----BEGIN CERTIFICATE-----")}},
+		}},
+	}}}}
+	var out bytes.Buffer
+	if err := Write(&out, database, "master", Argon2Parameters{Iterations: 2, Memory: 1024, Parallelism: 2}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if file := os.Getenv("KDBX_WRITE_FILE"); file != "" {
+		if err := os.WriteFile(file, out.Bytes(), 0o600); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+	data := out.Bytes()
+
+	// Outer header
+	if binary.LittleEndian.Uint32(data[0:4]) != 0x9AA2D903 || binary.LittleEndian.Uint32(data[4:8]) != 0xB54BFB67 ||
+		binary.LittleEndian.Uint32(data[8:12])>>16 != 4 {
+		t.Fatalf("Result differs, no KDBX 4 signature")
+	}
+	fields := map[byte][]byte{}
+	position := 12
+	for {
+		id, size := data[position], int(binary.LittleEndian.Uint32(data[position+1:position+5]))
+		fields[id] = data[position+5 : position+5+size]
+		position += 5 + size
+		if id == 0 {
+			break
+		}
+	}
+	header, data := data[:position], data[position:]
+	if hash := sha256.Sum256(header); !bytes.Equal(hash[:], data[:32]) {
+		t.Fatalf("Result differs, the header hash does not match")
+	}
+	if uuid.UUID(fields[2]) != uuid.MustParse("31c1f2e6-bf71-4350-be58-05216afc5aff") {
+		t.Fatalf("Result differs, got cipher %x", fields[2])
+	}
+
+	// Variant dictionary with the Argon2d parameters
+	kdf := map[string][]byte{}
+	for dictionary := fields[11][2:]; dictionary[0] != 0; {
+		keySize := int(binary.LittleEndian.Uint32(dictionary[1:5]))
+		key := string(dictionary[5 : 5+keySize])
+		valueSize := int(binary.LittleEndian.Uint32(dictionary[5+keySize : 9+keySize]))
+		kdf[key] = dictionary[9+keySize : 9+keySize+valueSize]
+		dictionary = dictionary[9+keySize+valueSize:]
+	}
+	if uuid.UUID(kdf["$UUID"]) != uuid.MustParse("ef636ddf-8c29-444b-91f7-a9a403e30a0c") {
+		t.Fatalf("Result differs, got KDF %x", kdf["$UUID"])
+	}
+	passwordHash := sha256.Sum256([]byte("master"))
+	composite := sha256.Sum256(passwordHash[:])
+	transformed := argon2Key(argon2d, composite[:], kdf["S"], nil, nil, uint32(binary.LittleEndian.Uint64(kdf["I"])),
+		uint32(binary.LittleEndian.Uint64(kdf["M"])/1024), uint8(binary.LittleEndian.Uint32(kdf["P"])), 32)
+
+	masterSeed := fields[4]
+	encryptionKey := sha256.Sum256(append(bytes.Clone(masterSeed), transformed...))
+	hmacBase := sha512.Sum512(append(append(bytes.Clone(masterSeed), transformed...), 1))
+	mac := func(index uint64, parts ...[]byte) []byte {
+		key := sha512.Sum512(append(binary.LittleEndian.AppendUint64(nil, index), hmacBase[:]...))
+		h := hmac.New(sha256.New, key[:])
+		for _, part := range parts {
+			h.Write(part)
+		}
+		return h.Sum(nil)
+	}
+	if !hmac.Equal(mac(math.MaxUint64, header), data[32:64]) {
+		t.Fatalf("Result differs, the header HMAC does not match")
+	}
+
+	// HMAC blocks, AES-256-CBC and gzip
+	var encrypted []byte
+	for index, blocks := uint64(0), data[64:]; ; index++ {
+		size := int(binary.LittleEndian.Uint32(blocks[32:36]))
+		if !hmac.Equal(mac(index, binary.LittleEndian.AppendUint64(nil, index), blocks[32:36+size]), blocks[:32]) {
+			t.Fatalf("Result differs, the HMAC of block %d does not match", index)
+		}
+		encrypted = append(encrypted, blocks[36:36+size]...)
+		blocks = blocks[36+size:]
+		if size == 0 {
+			break
+		}
+	}
+	block, _ := aes.NewCipher(encryptionKey[:])
+	cipher.NewCBCDecrypter(block, fields[7]).CryptBlocks(encrypted, encrypted)
+	gz, err := gzip.NewReader(bytes.NewReader(encrypted[:len(encrypted)-int(encrypted[len(encrypted)-1])]))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	payload, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	// Inner header
+	var streamKey []byte
+	var attachments [][]byte
+	for {
+		id, size := payload[0], int(binary.LittleEndian.Uint32(payload[1:5]))
+		value := payload[5 : 5+size]
+		payload = payload[5+size:]
+		switch id {
+		case 1:
+			if binary.LittleEndian.Uint32(value) != 3 {
+				t.Fatalf("Result differs, want the ChaCha20 inner stream, got %d", binary.LittleEndian.Uint32(value))
+			}
+		case 2:
+			streamKey = value
+		case 3:
+			attachments = append(attachments, value[1:])
+		}
+		if id == 0 {
+			break
+		}
+	}
+	if len(attachments) != 1 || string(attachments[0]) != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("Result differs, got attachments %q", attachments)
+	}
+
+	// Protected values in document order
+	streamHash := sha512.Sum512(streamKey)
+	stream, _ := chacha20.NewUnauthenticatedCipher(streamHash[:32], streamHash[32:44])
+	var document struct {
+		Values []struct {
+			Protected string `xml:",attr"`
+			Value     string `xml:",chardata"`
+		} `xml:"Root>Group>Group>Entry>String>Value"`
+	}
+	if err := xml.Unmarshal(payload, &document); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	var protected []string
+	for _, value := range document.Values {
+		if value.Protected != "True" {
+			continue
+		}
+		decoded, _ := base64.StdEncoding.DecodeString(value.Value)
+		stream.XORKeyStream(decoded, decoded)
+		protected = append(protected, string(decoded))
+	}
+	if strings.Join(protected, " ") != "s3cr<et otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ" {
+		t.Errorf("Result differs, got protected values %q", protected)
+	}
+}
@@ -0,0 +1,113 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Writes independent.kdbx, a KDBX 4 database built with the Node.js crypto module only, from the format description
+// at https://keepass.info/help/kb/kdbx_4.html. It shares no code with the kdbx package, TestOpenIndependent reads it.
+//
+// Run with: node generate.js
+// Master password "master", AES-KDF, ChaCha20, gzip, one attachment and protected password and otp fields.
+
+const crypto = require('crypto');
+const fs = require('fs');
+const zlib = require('zlib');
+
+const u32 = (n) => { const b = Buffer.alloc(4); b.writeUInt32LE(n); return b; };
+const u64 = (n) => { const b = Buffer.alloc(8); b.writeBigUInt64LE(BigInt(n)); return b; };
+const sha256 = (...data) => crypto.createHash('sha256').update(Buffer.concat(data)).digest();
+const sha512 = (...data) => crypto.createHash('sha512').update(Buffer.concat(data)).digest();
+const uuid = (s) => Buffer.from(s.replace(/-/g, ''), 'hex');
+const field = (id, data) => Buffer.concat([Buffer.from([id]), u32(data.length), data]);
+const entry = (type, key, value) => Buffer.concat([Buffer.from([type]), u32(key.length), Buffer.from(key), u32(value.length), value]);
+
+// Fixed random values keep the file reproducible
+const bytes = (n, seed) => crypto.createHash('sha512').update(seed).digest().subarray(0, n);
+const masterSeed = bytes(32, 'master seed');
+const iv = bytes(12, 'iv');
+const kdfSeed = bytes(32, 'kdf seed');
+const streamKey = bytes(64, 'stream key');
+const rounds = 1000;
+
+const kdf = Buffer.concat([
+  Buffer.from([0x00, 0x01]),
+  entry(0x42, '$UUID', uuid('c9d9f39a-628a-4460-bf74-0d08c18a4fea')),
+  entry(0x42, 'S', kdfSeed),
+  entry(0x05, 'R', u64(rounds)),
+  Buffer.from([0x00]),
+]);
+const header = Buffer.concat([
+  u32(0x9AA2D903), u32(0xB54BFB67), u32(0x00040000),
+  field(2, uuid('d6038a2b-8b6f-4cb5-a524-339a31dbb59a')),
+  field(3, u32(1)),
+  field(4, masterSeed),
+  field(7, iv),
+  field(11, kdf),
+  field(0, Buffer.from('\r\n\r\n')),
+]);
+
+// Composite key of a password only database, transformed by AES-KDF
+let transformed = sha256(sha256(Buffer.from('master')));
+for (let i = 0; i < rounds; i++) {
+  const aes = crypto.createCipheriv('aes-256-ecb', kdfSeed, null).setAutoPadding(false);
+  transformed = Buffer.concat([aes.update(transformed), aes.final()]);
+}
+transformed = sha256(transformed);
+const encryptionKey = sha256(masterSeed, transformed);
+const hmacBase = sha512(masterSeed, transformed, Buffer.from([1]));
+const hmac = (index, data) => crypto.createHmac('sha256', sha512(u64(index), hmacBase)).update(data).digest();
+
+// Protected values are XORed with one ChaCha20 stream in document order
+const hash = sha512(streamKey);
+const inner = crypto.createCipheriv('chacha20', hash.subarray(0, 32), Buffer.concat([u32(0), hash.subarray(32, 44)]));
+const protect = (value) => inner.update(Buffer.from(value)).toString('base64');
+
+const b64uuid = (s) => uuid(s).toString('base64');
+const xml = `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Meta>
+		<Generator>generate.js</Generator>
+		<DatabaseName>Independent</DatabaseName>
+		<RecycleBinEnabled>False</RecycleBinEnabled>
+	</Meta>
+	<Root>
+		<Group>
+			<UUID>${b64uuid('7a3c1e6e-0f0b-4d3e-9c55-2f4a5b6c7d01')}</UUID>
+			<Name>Independent</Name>
+			<Entry>
+				<UUID>${b64uuid('7a3c1e6e-0f0b-4d3e-9c55-2f4a5b6c7d02')}</UUID>
+				<String><Key>Title</Key><Value>Database</Value></String>
+				<String><Key>UserName</Key><Value>app</Value></String>
+				<String><Key>Password</Key><Value Protected="True">${protect('s3cr<et')}</Value></String>
+				<String><Key>otp</Key><Value Protected="True">${protect('otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ')}</Value></String>
+				<Binary><Key>ca.pem</Key><Value Ref="0"/></Binary>
+			</Entry>
+		</Group>
+	</Root>
+</KeePassFile>`;
+
+const payload = Buffer.concat([
+  field(1, u32(3)),
+  field(2, streamKey),
+  field(3, Buffer.concat([Buffer.from([1]), Buffer.from('-----BEGIN CERTIFICATE-----')])),
+  field(0, Buffer.alloc(0)),
+  Buffer.from(xml),
+]);
+const outer = crypto.createCipheriv('chacha20', encryptionKey, Buffer.concat([u32(0), iv]));
+const encrypted = Buffer.concat([outer.update(zlib.gzipSync(payload)), outer.final()]);
+
+const blocks = [encrypted, Buffer.alloc(0)].map((data, index) => {
+  const sized = Buffer.concat([u32(data.length), data]);
+  return Buffer.concat([hmac(index, Buffer.concat([u64(index), sized])), sized]);
+});
+fs.writeFileSync(__dirname + '/independent.kdbx', Buffer.concat([header, sha256(header), hmac('18446744073709551615', header), ...blocks]));
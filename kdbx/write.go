@@ -0,0 +1,174 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+)
+
+// blockSize Size of the HMAC blocks Write produces, the size KeePass uses
+const blockSize = 1 << 20
+
+// Argon2Parameters Cost of the Argon2d key derivation of a written database, at most 1000 iterations and 2 GiB
+type Argon2Parameters struct {
+	Iterations uint32
+	// Memory In KiB
+	Memory      uint32
+	Parallelism uint8
+}
+
+// DefaultArgon2 The key derivation KeePassXC uses for new databases
+var DefaultArgon2 = Argon2Parameters{Iterations: 10, Memory: 64 * 1024, Parallelism: 2}
+
+// Write Encrypt database with password as a KDBX 4 file: AES-256 with an Argon2d derived key, a gzip compressed
+// payload and passwords and TOTP secrets protected by ChaCha20
+func Write(w io.Writer, database *Database, password string, kdf Argon2Parameters) error {
+	masterSeed, iv, salt, streamKey := make([]byte, 32), make([]byte, 16), make([]byte, 32), make([]byte, 64)
+	for _, random := range [][]byte{masterSeed, iv, salt, streamKey} {
+		if _, err := rand.Read(random); err != nil {
+			return err
+		}
+	}
+
+	kdfParameters := map[string][]byte{
+		"$UUID": kdfArgon2d[:],
+		"S":     salt,
+		"I":     binary.LittleEndian.AppendUint64(nil, uint64(kdf.Iterations)),
+		"M":     binary.LittleEndian.AppendUint64(nil, uint64(kdf.Memory)*1024),
+		"P":     binary.LittleEndian.AppendUint32(nil, uint32(kdf.Parallelism)),
+		"V":     binary.LittleEndian.AppendUint32(nil, argon2Version),
+	}
+
+	var header bytes.Buffer
+	_ = binary.Write(&header, binary.LittleEndian, []uint32{signature1, signature2, majorVersion<<16 | 1})
+	writeHeaderField(&header, headerCipherID, cipherAES256[:])
+	writeHeaderField(&header, headerCompressionFlags, binary.LittleEndian.AppendUint32(nil, 1))
+	writeHeaderField(&header, headerMasterSeed, masterSeed)
+	writeHeaderField(&header, headerEncryptionIV, iv)
+	writeHeaderField(&header, headerKdfParameters, writeVariantDictionary(kdfParameters))
+	writeHeaderField(&header, headerEnd, []byte("\r\n\r\n"))
+
+	transformedKey, err := transformKey(compositeKey(password), kdfParameters)
+	if err != nil {
+		return err
+	}
+	encryptionKey, hmacKey := deriveKeys(masterSeed, transformedKey)
+
+	// Inner header followed by the document, gzip compressed
+	var payload bytes.Buffer
+	gz := gzip.NewWriter(&payload)
+	var inner bytes.Buffer
+	writeHeaderField(&inner, innerHeaderStreamID, binary.LittleEndian.AppendUint32(nil, innerStreamChaCha20))
+	writeHeaderField(&inner, innerHeaderStreamKey, streamKey)
+
+	var binaries [][]byte
+	file := xmlFile{Meta: xmlMeta{Generator: "go-keyhub", DatabaseName: database.Name, RecycleBinEnabled: "False"}}
+	file.Root.Group = xmlGroupOf(&database.Root, &binaries)
+	for _, data := range binaries {
+		// The first byte holds flags, 1 would mark the attachment as protected in memory
+		writeHeaderField(&inner, innerHeaderBinary, append([]byte{0}, data...))
+	}
+	writeHeaderField(&inner, innerHeaderEnd, nil)
+
+	document, err := xml.Marshal(file)
+	if err != nil {
+		return err
+	}
+	xorStream, err := innerStream(innerStreamChaCha20, streamKey)
+	if err != nil {
+		return err
+	}
+	if document, err = protect(document, xorStream); err != nil {
+		return err
+	}
+
+	if _, err := inner.WriteTo(gz); err != nil {
+		return err
+	}
+	if _, err := gz.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	if _, err := gz.Write(document); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return err
+	}
+	padding := aes.BlockSize - payload.Len()%aes.BlockSize
+	encrypted := append(payload.Bytes(), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	headerHash := sha256.Sum256(header.Bytes())
+	out := bytes.NewBuffer(bytes.Clone(header.Bytes()))
+	out.Write(headerHash[:])
+	out.Write(headerHMAC(hmacKey, header.Bytes()))
+	for index := uint64(0); ; index++ {
+		size := min(len(encrypted), blockSize)
+		data := binary.LittleEndian.AppendUint32(nil, uint32(size))
+		data = append(data, encrypted[:size]...)
+		out.Write(blockHMAC(hmacKey, index, data))
+		out.Write(data)
+		encrypted = encrypted[size:]
+		if size == 0 {
+			break
+		}
+	}
+
+	_, err = out.WriteTo(w)
+	return err
+}
+
+func writeHeaderField(w *bytes.Buffer, id byte, data []byte) {
+	w.WriteByte(id)
+	_ = binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+}
+
+// writeVariantDictionary Encode KDF parameters, UUID and salt as byte arrays and the others as unsigned integers
+func writeVariantDictionary(values map[string][]byte) []byte {
+	var out bytes.Buffer
+	out.Write([]byte{0, 1})
+	for _, key := range []string{"$UUID", "S", "I", "M", "P", "V"} {
+		value := values[key]
+		switch len(value) {
+		case 4:
+			out.WriteByte(0x04)
+		case 8:
+			out.WriteByte(0x05)
+		default:
+			out.WriteByte(0x42)
+		}
+		_ = binary.Write(&out, binary.LittleEndian, uint32(len(key)))
+		out.WriteString(key)
+		_ = binary.Write(&out, binary.LittleEndian, uint32(len(value)))
+		out.Write(value)
+	}
+	out.WriteByte(0)
+	return out.Bytes()
+}
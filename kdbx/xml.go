@@ -0,0 +1,215 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package kdbx
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The XML document inside a database, only the elements this package uses are mapped
+
+type xmlFile struct {
+	XMLName xml.Name `xml:"KeePassFile"`
+	Meta    xmlMeta  `xml:"Meta"`
+	Root    struct {
+		Group xmlGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type xmlMeta struct {
+	Generator         string `xml:"Generator,omitempty"`
+	DatabaseName      string `xml:"DatabaseName"`
+	RecycleBinEnabled string `xml:"RecycleBinEnabled"`
+	RecycleBinUUID    string `xml:"RecycleBinUUID,omitempty"`
+}
+
+type xmlGroup struct {
+	UUID    string     `xml:"UUID"`
+	Name    string     `xml:"Name"`
+	Notes   string     `xml:"Notes,omitempty"`
+	Entries []xmlEntry `xml:"Entry"`
+	Groups  []xmlGroup `xml:"Group"`
+}
+
+type xmlEntry struct {
+	UUID     string      `xml:"UUID"`
+	Tags     string      `xml:"Tags,omitempty"`
+	Times    xmlTimes    `xml:"Times"`
+	Strings  []xmlString `xml:"String"`
+	Binaries []xmlBinary `xml:"Binary"`
+}
+
+type xmlTimes struct {
+	CreationTime         string `xml:"CreationTime,omitempty"`
+	LastModificationTime string `xml:"LastModificationTime,omitempty"`
+	Expires              string `xml:"Expires"`
+	ExpiryTime           string `xml:"ExpiryTime,omitempty"`
+}
+
+type xmlString struct {
+	Key   string   `xml:"Key"`
+	Value xmlValue `xml:"Value"`
+}
+
+type xmlValue struct {
+	Protected string `xml:"Protected,attr,omitempty"`
+	Text      string `xml:",chardata"`
+}
+
+type xmlBinary struct {
+	Key   string `xml:"Key"`
+	Value struct {
+		Ref int `xml:"Ref,attr"`
+	} `xml:"Value"`
+}
+
+// database Convert the document to a Database, resolving attachment references to binaries
+func (f *xmlFile) database(binaries [][]byte) (*Database, error) {
+	root, err := f.Root.Group.group(binaries)
+	if err != nil {
+		return nil, err
+	}
+
+	database := &Database{Name: f.Meta.DatabaseName, Root: root}
+	if f.Meta.RecycleBinEnabled == "True" && f.Meta.RecycleBinUUID != "" {
+		recycleBin, err := parseUUID(f.Meta.RecycleBinUUID)
+		if err != nil {
+			return nil, err
+		}
+		if recycleBin != uuid.Nil {
+			database.RecycleBin = &recycleBin
+		}
+	}
+	return database, nil
+}
+
+func (g *xmlGroup) group(binaries [][]byte) (Group, error) {
+	id, err := parseUUID(g.UUID)
+	if err != nil {
+		return Group{}, err
+	}
+	group := Group{UUID: id, Name: g.Name, Notes: g.Notes}
+
+	for _, e := range g.Entries {
+		id, err := parseUUID(e.UUID)
+		if err != nil {
+			return Group{}, err
+		}
+		entry := Entry{UUID: id, Tags: e.Tags}
+		if e.Times.Expires == "True" {
+			if entry.Expiry, err = parseTime(e.Times.ExpiryTime); err != nil {
+				return Group{}, err
+			}
+		}
+		for _, s := range e.Strings {
+			entry.Strings = append(entry.Strings, String{Key: s.Key, Value: s.Value.Text})
+		}
+		for _, b := range e.Binaries {
+			if b.Value.Ref < 0 || b.Value.Ref >= len(binaries) {
+				return Group{}, fmt.Errorf("%w: attachment %q refers to missing binary %d", ErrCorrupt, b.Key, b.Value.Ref)
+			}
+			entry.Attachments = append(entry.Attachments, Attachment{Name: b.Key, Data: binaries[b.Value.Ref]})
+		}
+		group.Entries = append(group.Entries, entry)
+	}
+
+	for _, child := range g.Groups {
+		sub, err := child.group(binaries)
+		if err != nil {
+			return Group{}, err
+		}
+		group.Groups = append(group.Groups, sub)
+	}
+	return group, nil
+}
+
+// xmlGroupOf Convert group to the document, appending the data of its attachments to binaries
+func xmlGroupOf(group *Group, binaries *[][]byte) xmlGroup {
+	result := xmlGroup{UUID: formatUUID(group.UUID), Name: group.Name, Notes: group.Notes}
+
+	for _, entry := range group.Entries {
+		e := xmlEntry{UUID: formatUUID(entry.UUID), Tags: entry.Tags, Times: xmlTimes{Expires: "False"}}
+		now := formatTime(time.Now())
+		e.Times.CreationTime, e.Times.LastModificationTime = now, now
+		if !entry.Expiry.IsZero() {
+			e.Times.Expires, e.Times.ExpiryTime = "True", formatTime(entry.Expiry)
+		}
+		for _, s := range entry.Strings {
+			value := xmlValue{Text: s.Value}
+			if protectedKeys[s.Key] {
+				value.Protected = "True"
+			}
+			e.Strings = append(e.Strings, xmlString{Key: s.Key, Value: value})
+		}
+		for _, attachment := range entry.Attachments {
+			b := xmlBinary{Key: attachment.Name}
+			b.Value.Ref = len(*binaries)
+			*binaries = append(*binaries, attachment.Data)
+			e.Binaries = append(e.Binaries, b)
+		}
+		result.Entries = append(result.Entries, e)
+	}
+
+	for i := range group.Groups {
+		result.Groups = append(result.Groups, xmlGroupOf(&group.Groups[i], binaries))
+	}
+	return result
+}
+
+// parseUUID Parse a base64 encoded UUID of the document, an empty value is the nil UUID
+func parseUUID(value string) (uuid.UUID, error) {
+	if value == "" {
+		return uuid.Nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: invalid UUID %q", ErrCorrupt, value)
+	}
+	id, err := uuid.FromBytes(data)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: invalid UUID %q", ErrCorrupt, value)
+	}
+	return id, nil
+}
+
+func formatUUID(id uuid.UUID) string {
+	return base64.StdEncoding.EncodeToString(id[:])
+}
+
+// epochOffset Seconds between 0001-01-01, from which KDBX 4 counts, and the unix epoch
+const epochOffset = 62135596800
+
+// parseTime Parse a time of the document, base64 encoded seconds since 0001-01-01 or an ISO 8601 time
+func parseTime(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(data) != 8 {
+		return time.Time{}, fmt.Errorf("%w: invalid time %q", ErrCorrupt, value)
+	}
+	return time.Unix(int64(binary.LittleEndian.Uint64(data))-epochOffset, 0).UTC(), nil
+}
+
+func formatTime(t time.Time) string {
+	return base64.StdEncoding.EncodeToString(binary.LittleEndian.AppendUint64(nil, uint64(t.Unix()+epochOffset)))
+}
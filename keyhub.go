@@ -17,18 +17,18 @@ package keyhub
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"time"
 
 	"github.com/coreos/go-oidc"
 
-	"golang.org/x/net/context"
-	"golang.org/x/oauth2/clientcredentials"
+	"golang.org/x/oauth2"
 
 	"github.com/dghubble/sling"
+	"github.com/topicuskeyhub/go-keyhub/model"
 )
 
 type Client struct {
@@ -42,6 +42,16 @@ type Client struct {
 	ServiceAccounts    *ServiceAccountService
 	LaunchPadTile      *LaunchPadTileService
 	VersionErrors      []error
+
+	rateLimiter *RateLimitTransport
+}
+
+// LimiterStats Return the statistics of the limits configured by WithRateLimit and WithMaxInFlight
+func (c *Client) LimiterStats() LimiterStats {
+	if c.rateLimiter == nil {
+		return LimiterStats{}
+	}
+	return c.rateLimiter.Stats()
 }
 
 // khJsonBodyProvider encodes a JSON tagged struct value as a Body for requests.
@@ -63,71 +73,141 @@ func (p khJsonBodyProvider) Body() (io.Reader, error) {
 	return buf, nil
 }
 
-func NewClientDefault(issuer string, clientID string, clientSecret string) (*Client, error) {
-	http.DefaultClient.Transport = http.DefaultTransport
+// receive Perform the request configured on s bound to ctx and decode the response like sling.Receive does
+func receive(ctx context.Context, s *sling.Sling, successV, failureV interface{}) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	req, err := s.Request()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Do(req.WithContext(ctx), successV, failureV)
 
-	if http.DefaultClient.Timeout == 0 {
-		http.DefaultClient.Timeout = time.Duration(time.Second * 10)
+	// Errors without a json error report, e.g. from a proxy, are reported with their http status
+	if errorReport, ok := failureV.(*model.ErrorReport); ok && resp != nil && resp.StatusCode >= 400 && errorReport.Code == 0 {
+		errorReport.Code = resp.StatusCode
+		errorReport.Reason = http.StatusText(resp.StatusCode)
+		errorReport.Message = resp.Status
+		err = nil
 	}
 
-	return NewClient(http.DefaultClient, issuer, clientID, clientSecret)
+	return resp, err
+}
+
+// NewClientDefault Create a new Client authenticating with the client credentials of a client application
+//
+// Deprecated: use New with WithClientCredentials
+func NewClientDefault(issuer string, clientID string, clientSecret string) (*Client, error) {
+	return New(issuer, WithClientCredentials(clientID, clientSecret))
 }
 
+// NewClient Create a new Client using httpClient, authenticating with the client credentials of a client application
+//
+// Deprecated: use New with WithHTTPClient and WithClientCredentials
 func NewClient(httpClient *http.Client, issuer string, clientID string, clientSecret string) (*Client, error) {
+	return NewClientContext(context.Background(), httpClient, issuer, clientID, clientSecret)
+}
+
+// NewClientContext Create a new Client, ctx is used for the version negotiation and the OIDC discovery only
+//
+// Deprecated: use NewContext with WithHTTPClient and WithClientCredentials
+func NewClientContext(ctx context.Context, httpClient *http.Client, issuer string, clientID string, clientSecret string) (*Client, error) {
+	return NewContext(ctx, issuer, WithHTTPClient(httpClient), WithClientCredentials(clientID, clientSecret))
+}
+
+// New Create a new Client for the KeyHub at issuer configured by opts
+func New(issuer string, opts ...ClientOption) (*Client, error) {
+	return NewContext(context.Background(), issuer, opts...)
+}
+
+// NewContext Create a new Client for the KeyHub at issuer configured by opts, ctx is used for the version negotiation and the OIDC discovery only
+func NewContext(ctx context.Context, issuer string, opts ...ClientOption) (*Client, error) {
 
 	var err error
 	var baseVersionedSupported bool
 	var latestVersionedSupported bool
 
+	cfg := newClientConfig(opts)
+	httpClient, rateLimiter := cfg.buildHTTPClient()
+
 	base := sling.New().Client(httpClient).Base(issuer)
+	if cfg.userAgent != "" {
+		base.Set("User-Agent", cfg.userAgent)
+	}
 
 	versionService := newVersionService(base.New().Set("Accept", "application/json").Set("Content-Type", "application/json"))
 
 	newClient := &Client{
-		ID:            clientID,
+		ID:            cfg.clientID,
 		Version:       versionService,
 		VersionErrors: make([]error, 0),
+		rateLimiter:   rateLimiter,
 	}
 
-	// Create sling for contract version 60
+	// Create sling for the configured contract version, 60 by default
 	baseVersionedSling := base.New()
-	baseVersionedSupported, err = versionService.CheckAndUpdateVersionedSling(60, baseVersionedSling)
+	baseVersionedSupported, err = versionService.CheckAndUpdateVersionedSlingContext(ctx, cfg.contractVersion, baseVersionedSling)
 	if err != nil {
 		newClient.VersionErrors = append(newClient.VersionErrors, err)
+		cfg.logger.Warn("contract version not supported", "version", cfg.contractVersion, "error", err)
 	}
 
-	// Create sling for contract version 71
+	// Create sling for the latest contract version
 	latestVersionedSling := base.New()
-	latestVersionedSupported, err = versionService.CheckAndUpdateVersionedSling(0, latestVersionedSling)
+	latestVersionedSupported, err = versionService.CheckAndUpdateVersionedSlingContext(ctx, 0, latestVersionedSling)
 	if err != nil {
 		newClient.VersionErrors = append(newClient.VersionErrors, err)
+		cfg.logger.Warn("latest contract version not supported", "error", err)
 	}
 
 	if !(baseVersionedSupported || latestVersionedSupported) {
-		return nil, fmt.Errorf("KeyHub %v does not support api contract versions 60 or Latest", versionService.info.KeyhubVersion)
+		return nil, fmt.Errorf("KeyHub %v does not support api contract versions %d or Latest: %w", versionService.info.KeyhubVersion, cfg.contractVersion, ErrVersionUnsupported)
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, httpClient), issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token source outlives ctx, so it must not be cancelled along with it
+	tokenCtx := oidc.ClientContext(context.WithoutCancel(ctx), httpClient)
+
+	endpoint := provider.Endpoint()
+	var deviceClaims struct {
+		DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	}
+	if provider.Claims(&deviceClaims) == nil {
+		endpoint.DeviceAuthURL = deviceClaims.DeviceAuthorizationEndpoint
 	}
 
-	ctx := oidc.ClientContext(context.Background(), httpClient)
-	provider, err := oidc.NewProvider(ctx, issuer)
+	if cfg.tokenSourceFunc == nil {
+		return nil, fmt.Errorf("no credentials configured, use WithClientCredentials, WithTokenSource, WithJWTBearer or WithDeviceFlow")
+	}
+	tokenSource, err := cfg.tokenSourceFunc(tokenCtx, vaultEndpoint(endpoint))
 	if err != nil {
 		return nil, err
 	}
+	// Shared by the api and the vault transport, so both use the same token
+	tokenSource = oauth2.ReuseTokenSource(nil, tokenSource)
 
-	var appClientConf = clientcredentials.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		Scopes:       []string{oidc.ScopeOpenID},
-		TokenURL:     provider.Endpoint().TokenURL + "?authVault=access",
+	oauth2Client := &http.Client{
+		Transport: &oauth2.Transport{
+			Source: tokenSource,
+			Base:   httpClient.Transport,
+		},
+		Timeout: httpClient.Timeout,
 	}
-	oauth2Client := appClientConf.Client(ctx)
-	oauth2Client.Timeout = httpClient.Timeout
 
 	oauth2Sling := baseVersionedSling.New().Client(oauth2Client)
 
 	vaultClient := &http.Client{
 		Transport: &Transport{
-			Base: oauth2Client.Transport,
+			Source: tokenSource,
+			Base:   oauth2Client.Transport,
 		},
+		Timeout: httpClient.Timeout,
 	}
 
 	if baseVersionedSupported {
@@ -143,6 +223,8 @@ func NewClient(httpClient *http.Client, issuer string, clientID string, clientSe
 		newClient.Vaults = newVaultService(latestVersionedSling.New().Client(vaultClient))
 	}
 
+	cfg.logger.Debug("created KeyHub client", "issuer", issuer, "keyhubVersion", versionService.info.KeyhubVersion)
+
 	return newClient, nil
 
 }
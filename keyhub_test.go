@@ -16,12 +16,23 @@
 package keyhub
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/google/go-querystring/query"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"filippo.io/age"
 	"github.com/google/uuid"
 	"github.com/jarcoal/httpmock"
 	"github.com/topicuskeyhub/go-keyhub/model"
@@ -44,6 +55,7 @@ func init() {
 	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/info", httpmock.NewJsonResponderOrPanic(200, model.NewVersionInfo("unknown", versions)))
 	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/.well-known/openid-configuration", httpmock.NewStringResponder(200, `{"authorization_endpoint":"https://topicus-keyhub.com/login/oauth2/authorize","token_endpoint":"https://topicus-keyhub.com/login/oauth2/token","revocation_endpoint":"https://topicus-keyhub.com/login/oauth2/revoke","device_authorization_endpoint":"https://topicus-keyhub.com/login/oauth2/authorizedevice","issuer":"https://topicus-keyhub.com","jwks_uri":"https://topicus-keyhub.com/login/oauth2/jwks.json","scopes_supported":["openid","profile","manage_account","provisioning","access_vault","group_admin","global_admin"],"response_types_supported":["code","id_token","code token","code id_token","id_token token","code id_token token"],"response_modes_supported":["fragment","query"],"grant_types_supported":["authorization_code","client_credentials","implicit","password","refresh_token","urn:ietf:params:oauth:grant-type:device_code"],"code_challenge_methods_supported":["plain","S256"],"token_endpoint_auth_methods_supported":["client_secret_basic","client_secret_post"],"revocation_endpoint_auth_methods_supported":["client_secret_basic","client_secret_post"],"request_object_signing_alg_values_supported":["RS256","none"],"ui_locales_supported":["nl-NL"],"service_documentation":"https://topicus-keyhub.com/docs","request_parameter_supported":true,"request_uri_parameter_supported":true,"authorization_response_iss_parameter_supported":true,"subject_types_supported":["public"],"userinfo_endpoint":"https://topicus-keyhub.com/login/oauth2/userinfo","end_session_endpoint":"https://topicus-keyhub.com/login/oauth2/logout","id_token_signing_alg_values_supported":["RS256"],"userinfo_signing_alg_values_supported":["RS256"],"display_values_supported":["page"],"claim_types_supported":["normal"],"claims_supported":["sub","name","given_name","family_name","middle_name","nickname","preferred_username","picture","email","email_verified","gender","birthdate","zoneinfo","locale","phone_number","phone_number_verified","address","updated_at"],"claims_parameter_supported":true}`))
 	httpmock.RegisterResponder("POST", "https://topicus-keyhub.com/login/oauth2/token", httpmock.NewStringResponder(200, `{"access_token": "a"}`))
+	httpmock.RegisterResponder("POST", "https://topicus-keyhub.com/login/oauth2/authorizedevice", httpmock.NewStringResponder(200, `{"device_code":"d","user_code":"ABCD-EFGH","verification_uri":"https://topicus-keyhub.com/device","expires_in":600,"interval":1}`))
 
 	accountlist := model.AccountList{}
 	sum := int64(1)
@@ -91,6 +103,32 @@ func TestVersioning(t *testing.T) {
 	}
 }
 
+func TestNewLeavesDefaultClientAlone(t *testing.T) {
+
+	transport, timeout := http.DefaultClient.Transport, http.DefaultClient.Timeout
+
+	client, err := New("https://topicus-keyhub.com",
+		WithClientCredentials("clientid", "clientsecret"),
+		WithTimeout(5*time.Second),
+		WithUserAgent("go-keyhub-test"),
+		WithContractVersion(62),
+	)
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if client.Accounts == nil || client.Vaults == nil {
+		t.Fatalf("ERROR expected all services to be available")
+	}
+	if http.DefaultClient.Transport != transport || http.DefaultClient.Timeout != timeout {
+		t.Fatalf("ERROR New modified http.DefaultClient")
+	}
+
+	_, err = New("https://topicus-keyhub.com")
+	if err == nil {
+		t.Fatalf("ERROR expected an error when no credentials are configured")
+	}
+}
+
 func TestAccounts(t *testing.T) {
 
 	client, err := NewClientDefault("https://topicus-keyhub.com", "clientid", "clientsecret")
@@ -145,6 +183,784 @@ func TestGroups(t *testing.T) {
 	}
 }
 
+func TestListContextCancelled(t *testing.T) {
+
+	client, err := NewClientDefault("https://topicus-keyhub.com", "clientid", "clientsecret")
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	accounts, err := client.Accounts.ListContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ERROR expected context.Canceled, got %v", err)
+	}
+	if accounts != nil {
+		t.Fatalf("ERROR expected no accounts for a cancelled context")
+	}
+}
+
+func TestDeviceFlow(t *testing.T) {
+
+	output := &bytes.Buffer{}
+	flow := &DeviceFlow{
+		ClientID:  "clientid",
+		Output:    output,
+		CacheFile: filepath.Join(t.TempDir(), "keyhub", "token.json"),
+	}
+
+	_, err := New("https://topicus-keyhub.com", WithDeviceFlow(flow))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if !strings.Contains(output.String(), "ABCD-EFGH") {
+		t.Fatalf("ERROR user code not printed, got %q", output.String())
+	}
+
+	info, err := os.Stat(flow.CacheFile)
+	if err != nil {
+		t.Fatalf("ERROR token not cached: %s", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("ERROR cached token has mode %v", info.Mode().Perm())
+	}
+
+	// The cached token is used without signing in again
+	output.Reset()
+	_, err = New("https://topicus-keyhub.com", WithDeviceFlow(flow))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if output.Len() > 0 {
+		t.Fatalf("ERROR expected the cached token to be used, got %q", output.String())
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportVaultSession(t *testing.T) {
+
+	tokenFile := filepath.Join(t.TempDir(), "token.json")
+	err := os.WriteFile(tokenFile, []byte(`{"access_token":"a","token_type":"bearer","expires_in":3600,"vaultSession":"session"}`), 0600)
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	var vaultSession string
+	transport := &Transport{
+		Source: NewFileTokenSource(tokenFile),
+		Base: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			vaultSession = req.Header.Get("topicus-Vault-session")
+			return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+		}),
+	}
+
+	req, _ := http.NewRequest("GET", "https://topicus-keyhub.com/keyhub/rest/v1/vaultrecord/", nil)
+	if _, err = transport.RoundTrip(req); err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if vaultSession != "session" {
+		t.Fatalf("ERROR expected vault session `session`, got `%s`", vaultSession)
+	}
+	if req.Header.Get("topicus-Vault-session") != "" {
+		t.Fatalf("ERROR original request was modified")
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+
+	attempts := 0
+	transport := &RetryTransport{
+		Policy: RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+		Base: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if req.Body != nil {
+				body, _ := io.ReadAll(req.Body)
+				if string(body) != "payload" {
+					t.Fatalf("ERROR attempt %d got body %q", attempts, body)
+				}
+			}
+			if attempts < 3 {
+				return &http.Response{StatusCode: 503, Header: http.Header{"Retry-After": {"0"}}, Body: http.NoBody}, nil
+			}
+			return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+		}),
+	}
+
+	req, _ := http.NewRequest("PUT", "https://topicus-keyhub.com/keyhub/rest/v1/group/1", strings.NewReader("payload"))
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if resp.StatusCode != 200 || attempts != 3 {
+		t.Fatalf("ERROR expected success after 3 attempts, got status %d after %d attempts", resp.StatusCode, attempts)
+	}
+
+	// POST is not idempotent and is not retried by default
+	attempts = 0
+	req, _ = http.NewRequest("POST", "https://topicus-keyhub.com/keyhub/rest/v1/group/", strings.NewReader("payload"))
+	resp, err = transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if resp.StatusCode != 503 || attempts != 1 {
+		t.Fatalf("ERROR expected a single attempt, got status %d after %d attempts", resp.StatusCode, attempts)
+	}
+}
+
+func TestRateLimitTransport(t *testing.T) {
+
+	release := make(chan struct{})
+	transport := NewRateLimitTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		<-release
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	}), RateLimit{RequestsPerSecond: 1000, Burst: 10, MaxInFlight: 2})
+
+	done := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		go func() {
+			req, _ := http.NewRequest("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/", nil)
+			if resp, err := transport.RoundTrip(req); err == nil {
+				resp.Body.Close()
+			}
+			done <- struct{}{}
+		}()
+	}
+
+	// The third request waits for a free slot, give it a cancelled context to verify waiting stops
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for transport.Stats().InFlight < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/", nil)
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ERROR expected context.Canceled while waiting, got %v", err)
+	}
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+
+	stats := transport.Stats()
+	if stats.Requests != 3 || stats.PeakInFlight != 2 || stats.InFlight != 0 || stats.Cancelled != 1 {
+		t.Fatalf("ERROR unexpected stats %+v", stats)
+	}
+}
+
+func TestRateLimitTransportBody(t *testing.T) {
+
+	transport := NewRateLimitTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	}), RateLimit{RequestsPerSecond: 1, Burst: 1, MaxInFlight: 1})
+
+	req, _ := http.NewRequest("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	// The slot is held until the body is closed
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, "GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/", nil)
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ERROR expected context.DeadlineExceeded while waiting for a slot, got %v", err)
+	}
+	if stats := transport.Stats(); stats.InFlight != 1 || stats.DeadlineExceeded != 1 || stats.Cancelled != 0 {
+		t.Fatalf("ERROR expected the open body to hold the slot, got %+v", transport.Stats())
+	}
+
+	resp.Body.Close()
+	resp.Body.Close()
+	if stats := transport.Stats(); stats.InFlight != 0 {
+		t.Fatalf("ERROR expected closing the body to release the slot once, got %+v", stats)
+	}
+
+	// The bucket is empty for a second, longer than the deadline allows
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, "GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/", nil)
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ERROR expected context.DeadlineExceeded from the token bucket, got %v", err)
+	}
+	if stats := transport.Stats(); stats.DeadlineExceeded != 2 || stats.Cancelled != 0 || stats.InFlight != 0 {
+		t.Fatalf("ERROR unexpected stats %+v", stats)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+
+	now := time.Date(2024, 6, 25, 12, 0, 0, 0, time.UTC)
+	for value, expected := range map[string]time.Duration{
+		"120":                           2 * time.Minute,
+		"Tue, 25 Jun 2024 12:00:30 GMT": 30 * time.Second,
+		"Tue, 25 Jun 2024 11:00:00 GMT": 0,
+	} {
+		wait, ok := parseRetryAfter(value, now)
+		if !ok || wait != expected {
+			t.Errorf("Retry-After %q: want %v, got %v (%v)", value, expected, wait, ok)
+		}
+	}
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Errorf("Retry-After `soon` should not parse")
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/launchpadtile/404", httpmock.NewStringResponder(404, "Not Found"))
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/launchpadtile/409", httpmock.NewJsonResponderOrPanic(409, model.ErrorReport{Code: 409, Message: "Duplicate"}))
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/serviceaccount/", httpmock.NewJsonResponderOrPanic(200, model.ServiceAccountList{}))
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	_, err = client.LaunchPadTile.GetById(404)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ERROR expected ErrNotFound for a 404 response, got %v", err)
+	}
+
+	_, err = client.LaunchPadTile.GetById(409)
+	if !errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+		t.Fatalf("ERROR expected only ErrConflict for a 409 response, got %v", err)
+	}
+
+	_, err = client.ServiceAccounts.GetByUUID(uuid.New())
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ERROR expected ErrNotFound for an empty result, got %v", err)
+	}
+
+	client, err = New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"), WithContractVersion(12))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(client.VersionErrors) != 1 || !errors.Is(client.VersionErrors[0], ErrVersionUnsupported) {
+		t.Fatalf("ERROR expected ErrVersionUnsupported for contract version 12, got %v", client.VersionErrors)
+	}
+}
+
+// pagedResponder Respond with the requested range of total items, counting the requests in calls
+func pagedResponder(total int, calls *int) httpmock.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		*calls++
+		var start, end int
+		if _, err := fmt.Sscanf(req.Header.Get("Range"), "items=%d-%d", &start, &end); err != nil {
+			return httpmock.NewStringResponse(400, "missing range"), nil
+		}
+		if start >= total {
+			return httpmock.NewStringResponse(416, ""), nil
+		}
+		end = min(end, total-1)
+
+		items := make([]map[string]string, 0, end-start+1)
+		for i := start; i <= end; i++ {
+			items = append(items, map[string]string{"uuid": strconv.Itoa(i)})
+		}
+		resp, err := httpmock.NewJsonResponse(206, map[string]interface{}{"items": items})
+		if err == nil {
+			if req.Header.Get("topicus-Range-Count") == "None" {
+				resp.Header.Set("Content-Range", fmt.Sprintf("items %d-%d/*", start, end))
+			} else {
+				resp.Header.Set("Content-Range", fmt.Sprintf("items %d-%d/%d", start, end, total))
+			}
+		}
+		return resp, err
+	}
+}
+
+func TestAllStopsFetching(t *testing.T) {
+
+	calls := 0
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/client/", pagedResponder(250, &calls))
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	for _, err := range client.ClientApplications.All(context.Background(), nil) {
+		if err != nil {
+			t.Fatalf("ERROR %s", err)
+		}
+		break
+	}
+	if calls != 1 {
+		t.Fatalf("ERROR expected a single page to be fetched, got %d", calls)
+	}
+
+	calls = 0
+	clients, err := client.ClientApplications.List()
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(clients) != 250 {
+		t.Fatalf("ERROR expected 250 clients, got %d", len(clients))
+	}
+	for i, c := range clients {
+		if c.UUID != strconv.Itoa(i) {
+			t.Fatalf("ERROR expected client %d at position %d, got %s", i, i, c.UUID)
+		}
+	}
+}
+
+func TestListOptions(t *testing.T) {
+
+	calls := 0
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/launchpadtile/", pagedResponder(120, &calls))
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	tiles, err := client.LaunchPadTile.ListContext(context.Background(), nil, WithPageSize(50), WithCountMode(model.RANGE_COUNT_NONE))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(tiles) != 120 || calls != 3 {
+		t.Fatalf("ERROR expected 120 tiles in 3 pages, got %d in %d", len(tiles), calls)
+	}
+
+	// A full last page is followed by a request that is not satisfiable
+	calls = 0
+	tiles, err = client.LaunchPadTile.ListContext(context.Background(), nil, WithPageSize(40), WithCountMode(model.RANGE_COUNT_NONE))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(tiles) != 120 || calls != 4 {
+		t.Fatalf("ERROR expected 120 tiles in 4 requests, got %d in %d", len(tiles), calls)
+	}
+
+	// List passes the options on to ListContext
+	calls = 0
+	tiles, err = client.LaunchPadTile.List(nil, WithPageSize(50), WithCountMode(model.RANGE_COUNT_NONE))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(tiles) != 120 || calls != 3 {
+		t.Fatalf("ERROR expected 120 tiles in 3 pages, got %d in %d", len(tiles), calls)
+	}
+}
+
+func TestParallelPages(t *testing.T) {
+
+	var mu sync.Mutex
+	calls, inFlight, peak := 0, 0, 0
+	failAt := ""
+	paged := pagedResponder(1050, &calls)
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/account/", func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		if req.Header.Get("Range") == failAt {
+			mu.Unlock()
+			return httpmock.NewJsonResponse(500, model.ErrorReport{Code: 500, Message: "Broken"})
+		}
+		resp, err := paged(req)
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return resp, err
+	})
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	accounts, err := client.Accounts.ListContext(context.Background(), WithParallelPages(4))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(accounts) != 1050 || calls != 11 {
+		t.Fatalf("ERROR expected 1050 accounts in 11 pages, got %d in %d", len(accounts), calls)
+	}
+	for i, a := range accounts {
+		if a.UUID != strconv.Itoa(i) {
+			t.Fatalf("ERROR expected account %d at position %d, got %s", i, i, a.UUID)
+		}
+	}
+	if peak < 2 || peak > 4 {
+		t.Fatalf("ERROR expected between 2 and 4 concurrent requests, got %d", peak)
+	}
+
+	failAt = "items=700-799"
+	_, err = client.Accounts.ListContext(context.Background(), WithParallelPages(4))
+	var apiErr model.KeyhubApiError
+	if !errors.As(err, &apiErr) || apiErr.Report.Code != 500 {
+		t.Fatalf("ERROR expected the error of the failing page, got %v", err)
+	}
+
+	// An estimate below the actual number of groups, the pages after it are fetched while they are full
+	calls = 0
+	estimated := pagedResponder(1050, &calls)
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/", func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		resp, err := estimated(req)
+		if err == nil && req.Header.Get("topicus-Range-Count") == "Estimate" {
+			resp.Header.Set("Content-Range", strings.Replace(resp.Header.Get("Content-Range"), "/1050", "/400", 1))
+		}
+		return resp, err
+	})
+	groups, err := client.Groups.ListContext(context.Background(), WithParallelPages(4), WithCountMode(model.RANGE_COUNT_ESTIMATE))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(groups) != 1050 || groups[1049].UUID != "1049" {
+		t.Fatalf("ERROR expected 1050 groups despite the estimate, got %d", len(groups))
+	}
+}
+
+// testGroup A group with id 1 whose vault records are served below https://topicus-keyhub.com/keyhub/rest/v1/group/1/vault/record
+func testGroup() *model.Group {
+	return &model.Group{GroupPrimer: model.GroupPrimer{
+		Linkable: model.Linkable{Links: []model.Link{{ID: 1, Rel: "self", Href: "https://topicus-keyhub.com/keyhub/rest/v1/group/1"}}},
+		UUID:     "00000000-0000-0000-0000-000000000001",
+	}}
+}
+
+func TestCurrentTOTP(t *testing.T) {
+
+	recordUUID := uuid.MustParse("00000000-0000-0000-0000-0000000000a1")
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/1/vault/record", func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("uuid") != recordUUID.String() || !strings.Contains(req.URL.RawQuery, "secret") {
+			return httpmock.NewJsonResponse(200, model.VaultRecordList{})
+		}
+		totp := "otpauth://totp/KeyHub:jdoe?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+		record := model.NewVaultRecord("totp", &model.VaultRecordSecretAdditionalObject{Totp: &totp})
+		record.UUID = recordUUID.String()
+		return httpmock.NewJsonResponse(200, model.VaultRecordList{Items: []model.VaultRecord{*record}})
+	})
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	code, err := client.Vaults.CurrentTOTP(testGroup(), recordUUID)
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(code) != 6 {
+		t.Fatalf("ERROR expected a 6 digit code, got %s", code)
+	}
+
+	_, err = client.Vaults.CurrentTOTP(testGroup(), uuid.New())
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ERROR expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+
+	recordUUID := uuid.MustParse("00000000-0000-0000-0000-0000000000b1")
+	lastModified := time.Now().AddDate(0, 0, -30)
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/1/vault/record", func(req *http.Request) (*http.Response, error) {
+		password, comment := "old", "note"
+		record := model.NewVaultRecord("database", &model.VaultRecordSecretAdditionalObject{Password: &password, Comment: &comment})
+		record.Links = []model.Link{{ID: 5, Rel: "self", Href: "https://topicus-keyhub.com/keyhub/rest/v1/group/1/vault/record/5"}}
+		record.UUID = recordUUID.String()
+		record.EndDate = lastModified.AddDate(0, 0, 90)
+		record.WarningPeriod = model.WARNINGPERIOD_TWO_WEEKS
+		record.AdditionalObjects.Audit = &model.AuditAdditionalObject{LastModifiedAt: lastModified}
+		return httpmock.NewJsonResponse(200, model.VaultRecordList{Items: []model.VaultRecord{*record}})
+	})
+	var stored *model.VaultRecord
+	httpmock.RegisterResponder("PUT", "https://topicus-keyhub.com/keyhub/rest/v1/group/1/vault/record/5", func(req *http.Request) (*http.Response, error) {
+		stored = new(model.VaultRecord)
+		if err := json.NewDecoder(req.Body).Decode(stored); err != nil {
+			return nil, err
+		}
+		return httpmock.NewJsonResponse(200, stored)
+	})
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	generator := SecretGeneratorFunc(func() (string, error) { return "new", nil })
+
+	_, err = client.Vaults.Rotate(context.Background(), testGroup(), recordUUID, generator, func(ctx context.Context, record *model.VaultRecord, newSecret string) error {
+		return errors.New("target unreachable")
+	})
+	if err == nil || stored != nil {
+		t.Fatalf("ERROR expected a failing apply to leave the record alone, got %v", err)
+	}
+
+	var applied string
+	_, err = client.Vaults.Rotate(context.Background(), testGroup(), recordUUID, generator, func(ctx context.Context, record *model.VaultRecord, newSecret string) error {
+		applied = *record.Password() + "->" + newSecret
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if applied != "old->new" || stored == nil || *stored.Password() != "new" {
+		t.Fatalf("ERROR expected the new secret to be applied and stored, got %s", applied)
+	}
+	if comment := *stored.Comment(); !strings.HasPrefix(comment, "note\nPrevious password (rotated ") || !strings.HasSuffix(comment, "): old") {
+		t.Fatalf("ERROR expected the previous secret in the comment, got %q", comment)
+	}
+	if days := int(time.Until(stored.EndDate).Hours() / 24); days < 88 || days > 90 {
+		t.Fatalf("ERROR expected the lifetime of 90 days to be kept, got %d", days)
+	}
+}
+
+func TestRotateSiblingWithoutSecret(t *testing.T) {
+
+	group := &model.Group{GroupPrimer: model.GroupPrimer{
+		Linkable: model.Linkable{Links: []model.Link{{ID: 6, Rel: "self", Href: "https://topicus-keyhub.com/keyhub/rest/v1/group/6"}}},
+		UUID:     "00000000-0000-0000-0000-000000000006",
+	}}
+	recordUUID := uuid.MustParse("00000000-0000-0000-0000-0000000000b1")
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/6/vault/record", func(req *http.Request) (*http.Response, error) {
+		password := "old"
+		record := model.NewVaultRecord("database", &model.VaultRecordSecretAdditionalObject{Password: &password})
+		record.UUID = recordUUID.String()
+		if req.URL.Query().Get("uuid") != recordUUID.String() {
+			// The sibling "database (previous)" is returned without its secrets
+			record = &model.VaultRecord{UUID: "00000000-0000-0000-0000-0000000000b2", Name: "database (previous)"}
+		}
+		return httpmock.NewJsonResponse(200, model.VaultRecordList{Items: []model.VaultRecord{*record}})
+	})
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	applied := false
+	_, err = client.Vaults.Rotate(context.Background(), group, recordUUID, SecretGeneratorFunc(func() (string, error) { return "new", nil }),
+		func(ctx context.Context, record *model.VaultRecord, newSecret string) error {
+			applied = true
+			return nil
+		}, WithPreviousInSibling())
+	if err == nil || applied {
+		t.Fatalf("ERROR expected rotation to stop before applying, got %v", err)
+	}
+}
+
+func TestRotatedEndDate(t *testing.T) {
+
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	record := model.NewVaultRecord("r", &model.VaultRecordSecretAdditionalObject{})
+
+	if endDate := rotatedEndDate(record, 0, now); !endDate.IsZero() {
+		t.Fatalf("ERROR expected no end date, got %s", endDate)
+	}
+
+	record.WarningPeriod = model.WARNINGPERIOD_ONE_MONTH
+	if endDate := rotatedEndDate(record, 10*24*time.Hour, now); !endDate.Equal(time.Date(2024, 7, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("ERROR expected the end date to lie beyond the warning period, got %s", endDate)
+	}
+	if endDate := rotatedEndDate(record, 60*24*time.Hour, now); !endDate.Equal(time.Date(2024, 8, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("ERROR expected the end date after the validity, got %s", endDate)
+	}
+}
+
+func TestSearchForClient(t *testing.T) {
+
+	me := model.ClientApplication{}
+	me.Links = []model.Link{{ID: 42, Rel: "self"}}
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/client/me", httpmock.NewJsonResponderOrPanic(200, me))
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/vaultrecord/", func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("accessibleByClient") != "42" || req.URL.Query().Get("url") != "git.example.com" {
+			return httpmock.NewJsonResponse(200, model.VaultRecordList{})
+		}
+		record := model.NewVaultRecord("git", &model.VaultRecordSecretAdditionalObject{})
+		record.AdditionalObjects = nil
+		record.Links = []model.Link{{ID: 7, Rel: "self", Href: "https://topicus-keyhub.com/keyhub/rest/v1/group/1/vault/record/7"}}
+		return httpmock.NewJsonResponse(200, model.VaultRecordList{Items: []model.VaultRecord{*record}})
+	})
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/1/vault/record/7", func(req *http.Request) (*http.Response, error) {
+		password := ""
+		if strings.Contains(req.URL.RawQuery, "secret") {
+			password = "s3cret"
+		}
+		record := model.NewVaultRecord("git", &model.VaultRecordSecretAdditionalObject{Password: &password})
+		return httpmock.NewJsonResponse(200, record)
+	})
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	var found []model.VaultRecord
+	for record, err := range client.Vaults.SearchForClient(context.Background(), model.VaultRecordSearchQueryParams{Url: "git.example.com"}) {
+		if err != nil {
+			t.Fatalf("ERROR %s", err)
+		}
+		found = append(found, record)
+	}
+	if len(found) != 1 {
+		t.Fatalf("ERROR expected 1 record, got %d", len(found))
+	}
+
+	record, err := client.Vaults.GetBySelf(&found[0], &model.VaultRecordAdditionalQueryParams{Secret: true})
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if record.Password() == nil || *record.Password() != "s3cret" {
+		t.Fatalf("ERROR expected the secret of the record, got %+v", record.AdditionalObjects)
+	}
+}
+
+func TestListWithSecrets(t *testing.T) {
+
+	group := &model.Group{GroupPrimer: model.GroupPrimer{
+		Linkable: model.Linkable{Links: []model.Link{{ID: 7, Rel: "self", Href: "https://topicus-keyhub.com/keyhub/rest/v1/group/7"}}},
+		UUID:     "00000000-0000-0000-0000-000000000007",
+	}}
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/7/vault/record", func(req *http.Request) (*http.Response, error) {
+		list := model.VaultRecordList{}
+		for id, name := range []string{"db", "api"} {
+			record := model.NewVaultRecord(name, &model.VaultRecordSecretAdditionalObject{})
+			record.AdditionalObjects = nil
+			record.Links = []model.Link{{ID: int64(id), Rel: "self", Href: fmt.Sprintf("https://topicus-keyhub.com/keyhub/rest/v1/group/7/vault/record/%d", id)}}
+			list.Items = append(list.Items, *record)
+		}
+		return httpmock.NewJsonResponse(200, list)
+	})
+	httpmock.RegisterResponder("GET", `=~^https://topicus-keyhub\.com/keyhub/rest/v1/group/7/vault/record/(\d+)\z`, func(req *http.Request) (*http.Response, error) {
+		if !strings.Contains(req.URL.RawQuery, "secret") {
+			return httpmock.NewJsonResponse(400, model.ErrorReport{Code: 400, Message: "secret not requested"})
+		}
+		id := httpmock.MustGetSubmatchAsUint(req, 1)
+		password := fmt.Sprintf("secret%d", id)
+		return httpmock.NewJsonResponse(200, model.NewVaultRecord([]string{"db", "api"}[id], &model.VaultRecordSecretAdditionalObject{Password: &password}))
+	})
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	records, err := client.Vaults.ListWithSecretsContext(context.Background(), group, nil)
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(records) != 2 || records[1].Name != "api" || *records[1].Password() != "secret1" {
+		t.Fatalf("ERROR expected 2 records with their secrets, got %+v", records)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/1/vault/record", func(req *http.Request) (*http.Response, error) {
+		list := model.VaultRecordList{}
+		for id, name := range []string{"db", "api"} {
+			record := model.NewVaultRecord(name, &model.VaultRecordSecretAdditionalObject{})
+			record.AdditionalObjects = nil
+			record.UUID = fmt.Sprintf("00000000-0000-0000-0000-0000000000c%d", id)
+			record.Links = []model.Link{{ID: int64(id), Rel: "self", Href: fmt.Sprintf("https://topicus-keyhub.com/keyhub/rest/v1/group/1/vault/record/%d", id)}}
+			list.Items = append(list.Items, *record)
+		}
+		return httpmock.NewJsonResponse(200, list)
+	})
+	httpmock.RegisterResponder("GET", `=~^https://topicus-keyhub\.com/keyhub/rest/v1/group/1/vault/record/(\d+)\z`, func(req *http.Request) (*http.Response, error) {
+		id := httpmock.MustGetSubmatchAsUint(req, 1)
+		password, file := fmt.Sprintf("secret%d", id), []byte{0, 1, 2}
+		record := model.NewVaultRecord([]string{"db", "api"}[id], &model.VaultRecordSecretAdditionalObject{Password: &password, File: &file})
+		record.UUID = fmt.Sprintf("00000000-0000-0000-0000-0000000000c%d", id)
+		record.EndDate = time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+		record.WarningPeriod = model.WARNINGPERIOD_ONE_MONTH
+		return httpmock.NewJsonResponse(200, record)
+	})
+
+	target := &model.Group{GroupPrimer: model.GroupPrimer{
+		Linkable: model.Linkable{Links: []model.Link{{ID: 2, Rel: "self", Href: "https://topicus-keyhub.com/keyhub/rest/v1/group/2"}}},
+		UUID:     "00000000-0000-0000-0000-000000000002",
+	}}
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/2/vault/record", func(req *http.Request) (*http.Response, error) {
+		record := model.NewVaultRecord("db", &model.VaultRecordSecretAdditionalObject{})
+		record.AdditionalObjects = nil
+		record.Links = []model.Link{{ID: 9, Rel: "self", Href: "https://topicus-keyhub.com/keyhub/rest/v1/group/2/vault/record/9"}}
+		return httpmock.NewJsonResponse(200, model.VaultRecordList{Items: []model.VaultRecord{*record}})
+	})
+	var created, updated []model.VaultRecord
+	httpmock.RegisterResponder("POST", "https://topicus-keyhub.com/keyhub/rest/v1/group/2/vault/record", func(req *http.Request) (*http.Response, error) {
+		list := model.VaultRecordList{}
+		if err := json.NewDecoder(req.Body).Decode(&list); err != nil {
+			return nil, err
+		}
+		created = append(created, list.Items...)
+		return httpmock.NewJsonResponse(200, list)
+	})
+	httpmock.RegisterResponder("PUT", "https://topicus-keyhub.com/keyhub/rest/v1/group/2/vault/record/9", func(req *http.Request) (*http.Response, error) {
+		record := model.VaultRecord{}
+		if err := json.NewDecoder(req.Body).Decode(&record); err != nil {
+			return nil, err
+		}
+		updated = append(updated, record)
+		return httpmock.NewJsonResponse(200, record)
+	})
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	var archive bytes.Buffer
+	count, err := client.Vaults.Backup(context.Background(), []model.Group{*testGroup()}, &archive, []age.Recipient{identity.Recipient()})
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if count != 2 || bytes.Contains(archive.Bytes(), []byte("secret0")) {
+		t.Fatalf("ERROR expected 2 encrypted records, got %d", count)
+	}
+
+	restore := func(opts ...RestoreOption) []RestoreResult {
+		results, err := client.Vaults.Restore(context.Background(), bytes.NewReader(archive.Bytes()), []age.Identity{identity}, target, opts...)
+		if err != nil {
+			t.Fatalf("ERROR %s", err)
+		}
+		return results
+	}
+
+	results := restore(WithDryRun(), WithConflictPolicy(CONFLICT_RENAME))
+	if len(created) != 0 || len(results) != 2 || results[0].Action != RESTORE_RENAMED || results[0].Name != "db (restored)" || results[1].Action != RESTORE_CREATED {
+		t.Fatalf("ERROR expected a dry run renaming db, got %+v", results)
+	}
+
+	results = restore()
+	if len(created) != 1 || results[0].Action != RESTORE_SKIPPED || created[0].Name != "api" {
+		t.Fatalf("ERROR expected db to be skipped and api to be created, got %+v", results)
+	}
+	if *created[0].Password() != "secret1" || len(*created[0].AdditionalObjects.Secret.File) != 3 || created[0].WarningPeriod != model.WARNINGPERIOD_ONE_MONTH || created[0].EndDate.Format("2006-01-02") != "2030-01-31" {
+		t.Fatalf("ERROR expected secrets, file, EndDate and WarningPeriod to be restored, got %+v", created[0])
+	}
+
+	restore(WithConflictPolicy(CONFLICT_OVERWRITE), WithSourceGroup("00000000-0000-0000-0000-000000000001"))
+	if len(updated) != 1 || *updated[0].Password() != "secret0" {
+		t.Fatalf("ERROR expected db to be overwritten, got %+v", updated)
+	}
+
+	other, _ := age.GenerateX25519Identity()
+	if _, err := client.Vaults.Restore(context.Background(), bytes.NewReader(archive.Bytes()), []age.Identity{other}, target); err == nil {
+		t.Fatalf("ERROR expected a backup for another recipient to fail")
+	}
+}
+
 func verifyQueryParams(t *testing.T, queryParams interface{}, expected string) {
 
 	r, err := query.Values(queryParams)
@@ -160,6 +976,217 @@ func verifyQueryParams(t *testing.T, queryParams interface{}, expected string) {
 
 }
 
+func TestVaultPlanApply(t *testing.T) {
+
+	group := &model.Group{GroupPrimer: model.GroupPrimer{
+		Linkable: model.Linkable{Links: []model.Link{{ID: 3, Rel: "self", Href: "https://topicus-keyhub.com/keyhub/rest/v1/group/3"}}},
+		UUID:     "00000000-0000-0000-0000-000000000003",
+		Name:     "Operations",
+	}}
+	current := func() []model.VaultRecord {
+		var records []model.VaultRecord
+		for id, name := range []string{"db", "api", "legacy"} {
+			record := model.NewVaultRecord(name, &model.VaultRecordSecretAdditionalObject{})
+			record.AdditionalObjects = nil
+			record.UUID = fmt.Sprintf("00000000-0000-0000-0000-0000000000d%d", id)
+			record.URL = "https://" + name + ".example.com"
+			record.Username = "app"
+			record.Links = []model.Link{{ID: int64(id), Rel: "self", Href: fmt.Sprintf("https://topicus-keyhub.com/keyhub/rest/v1/group/3/vault/record/%d", id)}}
+			records = append(records, *record)
+		}
+		return records
+	}
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/3/vault/record", func(req *http.Request) (*http.Response, error) {
+		list := model.VaultRecordList{}
+		for _, record := range current() {
+			if id := req.URL.Query().Get("uuid"); id == "" || id == record.UUID {
+				list.Items = append(list.Items, record)
+			}
+		}
+		return httpmock.NewJsonResponse(200, list)
+	})
+	httpmock.RegisterResponder("GET", `=~^https://topicus-keyhub\.com/keyhub/rest/v1/group/3/vault/record/(\d+)\z`, func(req *http.Request) (*http.Response, error) {
+		record := current()[httpmock.MustGetSubmatchAsUint(req, 1)]
+		password := "s3cret"
+		record.AdditionalObjects = &model.VaultRecordAdditionalObjects{Secret: &model.VaultRecordSecretAdditionalObject{Password: &password}}
+		return httpmock.NewJsonResponse(200, record)
+	})
+	var created, updated []model.VaultRecord
+	var deleted []string
+	httpmock.RegisterResponder("POST", "https://topicus-keyhub.com/keyhub/rest/v1/group/3/vault/record", func(req *http.Request) (*http.Response, error) {
+		list := model.VaultRecordList{}
+		if err := json.NewDecoder(req.Body).Decode(&list); err != nil {
+			return nil, err
+		}
+		created = append(created, list.Items...)
+		return httpmock.NewJsonResponse(200, list)
+	})
+	httpmock.RegisterResponder("PUT", `=~^https://topicus-keyhub\.com/keyhub/rest/v1/group/3/vault/record/\d+\z`, func(req *http.Request) (*http.Response, error) {
+		record := model.VaultRecord{}
+		if err := json.NewDecoder(req.Body).Decode(&record); err != nil {
+			return nil, err
+		}
+		updated = append(updated, record)
+		return httpmock.NewJsonResponse(200, record)
+	})
+	httpmock.RegisterResponder("DELETE", `=~^https://topicus-keyhub\.com/keyhub/rest/v1/group/3/vault/record/\d+\z`, func(req *http.Request) (*http.Response, error) {
+		deleted = append(deleted, req.URL.Path)
+		return httpmock.NewStringResponse(204, ""), nil
+	})
+
+	spec, err := ReadVaultSpec(strings.NewReader(`
+groups:
+  - uuid: 00000000-0000-0000-0000-000000000003
+    records:
+      - name: db
+        url: https://db.internal.example.com
+        warningPeriod: ONE_MONTH
+        endDate: 2030-01-31
+      - uuid: 00000000-0000-0000-0000-0000000000d1
+        name: api
+        username: app
+      - name: cache
+        username: redis
+`))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if _, err := ReadVaultSpec(strings.NewReader("groups:\n  - uuid: x\n    records:\n      - name: db\n        password: s3cret\n")); err == nil {
+		t.Fatalf("ERROR expected a spec with a password to be rejected")
+	}
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	plan, err := client.Vaults.Plan(context.Background(), spec, []model.Group{*group})
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(plan.Changes) != 2 || plan.Changes[0].Action != PLAN_UPDATE || len(plan.Changes[0].Fields) != 3 || plan.Changes[1].Action != PLAN_CREATE {
+		t.Fatalf("ERROR expected db to be updated and cache to be created, got %+v", plan.Changes)
+	}
+
+	plan, err = client.Vaults.Plan(context.Background(), spec, []model.Group{*group}, WithPrune())
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	text := plan.String()
+	for _, want := range []string{`~ update "db"`, `url: "https://db.example.com" => "https://db.internal.example.com"`, `+ create "cache"`, `- delete "legacy"`, "1 to create, 1 to update, 1 to delete"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("ERROR expected %q in plan, got\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "s3cret") {
+		t.Fatalf("ERROR plan contains a secret\n%s", text)
+	}
+
+	applied, err := client.Vaults.Apply(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if applied != 3 || len(created) != 1 || len(updated) != 1 || len(deleted) != 1 {
+		t.Fatalf("ERROR expected 3 changes, got %d: %+v %+v %v", applied, created, updated, deleted)
+	}
+	if created[0].Name != "cache" || created[0].Username != "redis" {
+		t.Fatalf("ERROR expected cache to be created, got %+v", created[0])
+	}
+	if updated[0].URL != "https://db.internal.example.com" || updated[0].WarningPeriod != model.WARNINGPERIOD_ONE_MONTH || updated[0].Username != "app" || *updated[0].Password() != "s3cret" {
+		t.Fatalf("ERROR expected db to be updated keeping its secrets, got %+v", updated[0])
+	}
+	if deleted[0] != "/keyhub/rest/v1/group/3/vault/record/2" {
+		t.Fatalf("ERROR expected legacy to be deleted, got %v", deleted)
+	}
+
+	if _, err := client.Vaults.Plan(context.Background(), spec, nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ERROR expected an unknown group to fail, got %v", err)
+	}
+}
+
+func TestCopyMove(t *testing.T) {
+
+	group := func(id int) *model.Group {
+		return &model.Group{GroupPrimer: model.GroupPrimer{
+			Linkable: model.Linkable{Links: []model.Link{{ID: int64(id), Rel: "self", Href: fmt.Sprintf("https://topicus-keyhub.com/keyhub/rest/v1/group/%d", id)}}},
+			UUID:     fmt.Sprintf("00000000-0000-0000-0000-00000000000%d", id),
+		}}
+	}
+	src, dst := group(4), group(5)
+	recordUUID := uuid.MustParse("00000000-0000-0000-0000-0000000000e0")
+
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/4/vault/record", func(req *http.Request) (*http.Response, error) {
+		password, totp, comment, file := "s3cret", "JBSWY3DPEHPK3PXP", "note", []byte{0, 1, 2}
+		record := model.NewVaultRecord("db", &model.VaultRecordSecretAdditionalObject{Password: &password, Totp: &totp, Comment: &comment, File: &file})
+		record.UUID = recordUUID.String()
+		record.URL, record.Username, record.Color, record.Filename = "https://db.example.com", "app", "RED", "db.key"
+		record.Types = []string{"PASSWORD", "FILE", "TOTP"}
+		record.EndDate = time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+		record.WarningPeriod = model.WARNINGPERIOD_ONE_MONTH
+		record.Links = []model.Link{{ID: 0, Rel: "self", Href: "https://topicus-keyhub.com/keyhub/rest/v1/group/4/vault/record/0"}}
+		return httpmock.NewJsonResponse(200, model.VaultRecordList{Items: []model.VaultRecord{*record}})
+	})
+	var created *model.VaultRecord
+	corrupt := false
+	httpmock.RegisterResponder("POST", "https://topicus-keyhub.com/keyhub/rest/v1/group/5/vault/record", func(req *http.Request) (*http.Response, error) {
+		list := model.VaultRecordList{}
+		if err := json.NewDecoder(req.Body).Decode(&list); err != nil {
+			return nil, err
+		}
+		created = &list.Items[0]
+		created.UUID = "00000000-0000-0000-0000-0000000000e9"
+		created.Links = []model.Link{{ID: 9, Rel: "self", Href: "https://topicus-keyhub.com/keyhub/rest/v1/group/5/vault/record/9"}}
+		return httpmock.NewJsonResponse(200, list)
+	})
+	httpmock.RegisterResponder("GET", "https://topicus-keyhub.com/keyhub/rest/v1/group/5/vault/record/9", func(req *http.Request) (*http.Response, error) {
+		record := *created
+		if corrupt {
+			file := []byte{0, 1}
+			secret := *record.AdditionalObjects.Secret
+			secret.File = &file
+			record.AdditionalObjects = &model.VaultRecordAdditionalObjects{Secret: &secret}
+		}
+		return httpmock.NewJsonResponse(200, record)
+	})
+	var deleted []string
+	httpmock.RegisterResponder("DELETE", "https://topicus-keyhub.com/keyhub/rest/v1/group/4/vault/record/0", func(req *http.Request) (*http.Response, error) {
+		deleted = append(deleted, req.URL.Path)
+		return httpmock.NewStringResponse(204, ""), nil
+	})
+
+	client, err := New("https://topicus-keyhub.com", WithClientCredentials("clientid", "clientsecret"))
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+
+	result, err := client.Vaults.Copy(context.Background(), src, dst, recordUUID)
+	if err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(deleted) != 0 || result.UUID != "00000000-0000-0000-0000-0000000000e9" {
+		t.Fatalf("ERROR expected a copy without deleting the original, got %+v %v", result, deleted)
+	}
+	if created.Name != "db" || created.Color != "RED" || created.Filename != "db.key" || len(created.Types) != 3 || created.WarningPeriod != model.WARNINGPERIOD_ONE_MONTH ||
+		created.EndDate.Format("2006-01-02") != "2030-01-31" || *created.Password() != "s3cret" || *created.Totp() != "JBSWY3DPEHPK3PXP" || len(*created.File()) != 3 {
+		t.Fatalf("ERROR expected every field to be copied, got %+v", created)
+	}
+
+	corrupt = true
+	_, err = client.Vaults.Move(context.Background(), src, dst, recordUUID)
+	var verificationError *CopyVerificationError
+	if !errors.As(err, &verificationError) || verificationError.Field != "file" || len(deleted) != 0 {
+		t.Fatalf("ERROR expected a failed verification keeping the original, got %v %v", err, deleted)
+	}
+
+	corrupt = false
+	if _, err = client.Vaults.Move(context.Background(), src, dst, recordUUID); err != nil {
+		t.Fatalf("ERROR %s", err)
+	}
+	if len(deleted) != 1 {
+		t.Fatalf("ERROR expected the original to be deleted, got %v", deleted)
+	}
+}
+
 func TestQueries(t *testing.T) {
 
 	var q model.ServiceAccountQueryParams
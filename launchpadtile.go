@@ -16,8 +16,9 @@
 package keyhub
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"iter"
 	"strconv"
 
 	"github.com/dghubble/sling"
@@ -37,13 +38,18 @@ func newLaunchPadTileService(sling *sling.Sling) *LaunchPadTileService {
 
 // Create a new launch pad tile in Keyhub
 func (s *LaunchPadTileService) Create(tile *model.LaunchPadTile) (result *model.LaunchPadTile, err error) {
+	return s.CreateContext(context.Background(), tile)
+}
+
+// CreateContext Create a new launch pad tile in Keyhub
+func (s *LaunchPadTileService) CreateContext(ctx context.Context, tile *model.LaunchPadTile) (result *model.LaunchPadTile, err error) {
 
 	tiles := new(model.LaunchPadTileList)
 	results := new(model.LaunchPadTileList)
 	errorReport := new(model.ErrorReport)
 	tiles.Items = append(tiles.Items, *tile)
 
-	_, err = s.sling.New().Post("").BodyJSON(tiles).Receive(results, errorReport)
+	_, err = receive(ctx, s.sling.New().Post("").BodyJSON(tiles), results, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not create LaunchPadTile.")
 	}
@@ -59,66 +65,62 @@ func (s *LaunchPadTileService) Create(tile *model.LaunchPadTile) (result *model.
 }
 
 // List all available launch pad tiles.
-func (s *LaunchPadTileService) List(queryParams *model.LaunchPadTileQueryParams) (tiles []model.LaunchPadTile, err error) {
-	searchRange := model.NewRange()
-
-	if queryParams == nil {
-		queryParams = new(model.LaunchPadTileQueryParams)
-	}
-
-	var response *http.Response
-
-	for ok := true; ok; ok = searchRange.NextPage() {
+func (s *LaunchPadTileService) List(queryParams *model.LaunchPadTileQueryParams, opts ...ListOption) (tiles []model.LaunchPadTile, err error) {
+	return s.ListContext(context.Background(), queryParams, opts...)
+}
 
-		errorReport := new(model.ErrorReport)
-		results := new(model.LaunchPadTileList)
-		response, err = s.sling.New().Get("").QueryStruct(*queryParams).Add(searchRange.GetRequestRangeHeader()).Add(searchRange.GetRequestModeHeader()).Receive(results, errorReport)
-		searchRange.ParseResponse(response)
+// ListContext List all available launch pad tiles, no further pages are fetched once ctx is done
+func (s *LaunchPadTileService) ListContext(ctx context.Context, queryParams *model.LaunchPadTileQueryParams, opts ...ListOption) (tiles []model.LaunchPadTile, err error) {
+	return collect(s.All(ctx, queryParams, opts...))
+}
 
-		if errorReport.Code > 0 {
-			err = errorReport.Wrap("Could not get LaunchPadTiles")
-		}
-		if err == nil {
-			if len(results.Items) > 0 {
-				tiles = append(tiles, results.Items...)
-			}
-		}
-	}
-	return
+// All Iterate all launch pad tiles matching query, pages are fetched while ranging over the tiles
+func (s *LaunchPadTileService) All(ctx context.Context, query *model.LaunchPadTileQueryParams, opts ...ListOption) iter.Seq2[model.LaunchPadTile, error] {
+	return paginate[model.LaunchPadTile](ctx, s.sling.New().Get("").QueryStruct(query), opts, "Could not get LaunchPadTiles")
 }
 
 // GetById Retrieve a launch pad tile by keyhub id
 func (s *LaunchPadTileService) GetById(id int64) (result *model.LaunchPadTile, err error) {
+	return s.GetByIdContext(context.Background(), id)
+}
+
+// GetByIdContext Retrieve a launch pad tile by keyhub id
+func (s *LaunchPadTileService) GetByIdContext(ctx context.Context, id int64) (result *model.LaunchPadTile, err error) {
 	al := new(model.LaunchPadTile)
 	errorReport := new(model.ErrorReport)
 	idString := strconv.FormatInt(id, 10)
 
-	_, err = s.sling.New().Get(idString).Receive(al, errorReport)
+	_, err = receive(ctx, s.sling.New().Get(idString), al, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get LaunchPadTile %q. Error: %s", idString, errorReport.Message)
 		return
 	}
 	if err == nil && al == nil {
-		err = fmt.Errorf("LaunchPadTile %q not found", idString)
+		err = fmt.Errorf("LaunchPadTile %q %w", idString, ErrNotFound)
 		return
 	}
 
 	return al, nil
 }
 
-// GetById Retrieve a launch pad tile by keyhub id
+// DeleteById Delete a launch pad tile by keyhub id
 func (s *LaunchPadTileService) DeleteById(id int64) (err error) {
+	return s.DeleteByIdContext(context.Background(), id)
+}
+
+// DeleteByIdContext Delete a launch pad tile by keyhub id
+func (s *LaunchPadTileService) DeleteByIdContext(ctx context.Context, id int64) (err error) {
 	al := new(model.LaunchPadTile)
 	errorReport := new(model.ErrorReport)
 	idString := strconv.FormatInt(id, 10)
 
-	_, err = s.sling.New().Delete(idString).Receive(al, errorReport)
+	_, err = receive(ctx, s.sling.New().Delete(idString), al, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not delete LaunchPadTile %q. Error: %s", idString, errorReport.Message)
 		return
 	}
 	if err == nil && al == nil {
-		err = fmt.Errorf("LaunchPadTile %q not found", idString)
+		err = fmt.Errorf("LaunchPadTile %q %w", idString, ErrNotFound)
 		return
 	}
 
@@ -1,6 +1,19 @@
 package model
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors, use errors.Is to test whether an error returned by the api has one of these outcomes
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrVersionUnsupported = errors.New("contract version not supported")
+)
 
 // ErrorReport error report returned by KeyHub Api
 type ErrorReport struct {
@@ -16,6 +29,21 @@ func (er ErrorReport) Error() string {
 	return er.Message
 }
 
+// Is Match the http status code of the report to the sentinel errors
+func (er ErrorReport) Is(target error) bool {
+	switch er.Code {
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	}
+	return false
+}
+
 // Wrap  rap the errorReport within an error of type KeyhubApiError
 func (er ErrorReport) Wrap(format string, any ...any) error {
 	return KeyhubApiError{
@@ -12,27 +12,58 @@ const (
 	HEADER_COUNT_MODE = "topicus-Range-Count"
 	HEADER_RESP_RANGE = "Content-Range"
 	RANGE_PER_PAGE    = 100
+
+	// RANGE_COUNT_EXACT KeyHub counts all matching items, expensive for large results
+	RANGE_COUNT_EXACT RangeCountMode = "Exact"
+	// RANGE_COUNT_ESTIMATE KeyHub estimates the number of matching items
+	RANGE_COUNT_ESTIMATE RangeCountMode = "Estimate"
+	// RANGE_COUNT_NONE KeyHub does not count, paging stops at the first page that is not full
+	RANGE_COUNT_NONE RangeCountMode = "None"
+
+	// rangeTotalUnknown Total of a Range when the response did not include one
+	rangeTotalUnknown = -1
 )
 
+// RangeCountMode How KeyHub determines the total number of items of a ranged request
+type RangeCountMode string
+
 var contentRangeRegex *regexp.Regexp
 
 func NewRange() *Range {
+	return NewRangeWith(RANGE_PER_PAGE, RANGE_COUNT_EXACT)
+}
+
+// NewRangeWith Create a Range fetching perpage items per request, using countMode to determine the total
+func NewRangeWith(perpage int, countMode RangeCountMode) *Range {
 
 	if contentRangeRegex == nil {
-		contentRangeRegex, _ = regexp.Compile("items (\\d+)-(\\d+)/(\\d+)")
+		contentRangeRegex, _ = regexp.Compile(`items (\d+)-(\d+)/(\d+|\*)`)
 	}
 
-	r := &Range{}
-	r.Setup(RANGE_PER_PAGE)
+	if perpage <= 0 {
+		perpage = RANGE_PER_PAGE
+	}
+	if countMode == "" {
+		countMode = RANGE_COUNT_EXACT
+	}
+
+	r := &Range{countMode: countMode}
+	r.Setup(perpage)
 
 	return r
 }
 
 type Range struct {
-	start   int
-	end     int
-	total   int
-	perpage int
+	start     int
+	end       int
+	total     int
+	perpage   int
+	countMode RangeCountMode
+}
+
+// ForgetTotal Treat the total as unknown, NextPage then continues as long as the current page is full
+func (r *Range) ForgetTotal() {
+	r.total = rangeTotalUnknown
 }
 
 func (r *Range) Setup(perpage int) {
@@ -51,24 +82,44 @@ func (r *Range) ParseResponse(response *http.Response) {
 	}
 
 	matches := contentRangeRegex.FindStringSubmatch(response.Header.Get(HEADER_RESP_RANGE))
-	// matches[0]  = all, matches[1]  = start (0 based), matches[2]  = end (0 based), matches[3]  = total
+	// matches[0]  = all, matches[1]  = start (0 based), matches[2]  = end (0 based), matches[3]  = total or * when unknown
 
 	if len(matches) > 0 {
 		r.start, _ = strconv.Atoi(matches[1])
 		r.end, _ = strconv.Atoi(matches[2])
-		r.total, _ = strconv.Atoi(matches[3])
+		if matches[3] == "*" {
+			r.total = rangeTotalUnknown
+		} else {
+			r.total, _ = strconv.Atoi(matches[3])
+		}
+	} else {
+		// Without a Content-Range the response holds all remaining items
+		r.total = r.end + 1
 	}
 
 }
 
+// Total Return the total number of items, ok is false when the response did not include a total
+func (r *Range) Total() (total int, ok bool) {
+	if r.total == rangeTotalUnknown {
+		return 0, false
+	}
+	return r.total, true
+}
+
 func (r *Range) NextPage() bool {
 
-	if r.end+1 >= r.total {
+	if r.total == rangeTotalUnknown {
+		// Only a page that is not full is known to be the last one
+		if r.end-r.start+1 < r.perpage {
+			return false
+		}
+	} else if r.end+1 >= r.total {
 		return false
 	}
 
 	r.start = r.end + 1
-	r.end = r.start + r.perpage
+	r.end = r.start + r.perpage - 1
 
 	return true
 }
@@ -80,7 +131,7 @@ func (r *Range) PreviousPage() bool {
 	}
 
 	r.end = r.start - 1
-	r.start = r.end - r.perpage
+	r.start = r.end - r.perpage + 1
 
 	// If start gets lower than 0, reset to page 0 values
 	if r.start < 0 {
@@ -96,5 +147,5 @@ func (r *Range) GetRequestRangeHeader() (string, string) {
 }
 func (r *Range) GetRequestModeHeader() (string, string) {
 
-	return HEADER_COUNT_MODE, "Exact"
+	return HEADER_COUNT_MODE, string(r.countMode)
 }
@@ -0,0 +1,104 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package model
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func rangeResponse(contentRange string) *http.Response {
+	response := &http.Response{Header: http.Header{}}
+	if contentRange != "" {
+		response.Header.Set(HEADER_RESP_RANGE, contentRange)
+	}
+	return response
+}
+
+func TestRangePaging(t *testing.T) {
+
+	r := NewRangeWith(10, RANGE_COUNT_EXACT)
+
+	var requested []string
+	for ok := true; ok; ok = r.NextPage() {
+		_, header := r.GetRequestRangeHeader()
+		requested = append(requested, header)
+
+		var start, end int
+		fmt.Sscanf(header, "items=%d-%d", &start, &end)
+		r.ParseResponse(rangeResponse(fmt.Sprintf("items %d-%d/25", start, min(end, 24))))
+	}
+
+	expected := []string{"items=0-9", "items=10-19", "items=20-29"}
+	if fmt.Sprint(requested) != fmt.Sprint(expected) {
+		t.Fatalf("Unexpected ranges, want %v, got %v", expected, requested)
+	}
+	if total, ok := r.Total(); !ok || total != 25 {
+		t.Errorf("Unexpected total, want 25, got %d (%v)", total, ok)
+	}
+
+	if !r.PreviousPage() {
+		t.Fatalf("Expected a previous page")
+	}
+	if _, header := r.GetRequestRangeHeader(); header != "items=10-19" {
+		t.Errorf("Unexpected previous range, want items=10-19, got %s", header)
+	}
+}
+
+func TestRangeUnknownTotal(t *testing.T) {
+
+	r := NewRangeWith(10, RANGE_COUNT_NONE)
+	if _, mode := r.GetRequestModeHeader(); mode != "None" {
+		t.Errorf("Unexpected count mode, want None, got %s", mode)
+	}
+
+	r.ParseResponse(rangeResponse("items 0-9/*"))
+	if _, ok := r.Total(); ok {
+		t.Errorf("Expected an unknown total")
+	}
+	if !r.NextPage() {
+		t.Fatalf("Expected a next page after a full page")
+	}
+
+	r.ParseResponse(rangeResponse("items 10-14/*"))
+	if r.NextPage() {
+		t.Errorf("Expected no next page after a short page")
+	}
+
+	r = NewRangeWith(10, RANGE_COUNT_ESTIMATE)
+	r.ParseResponse(rangeResponse(""))
+	if r.NextPage() {
+		t.Errorf("Expected no next page without a Content-Range")
+	}
+
+	r.ParseResponse(rangeResponse("items 10-19/20"))
+	r.ForgetTotal()
+	if !r.NextPage() {
+		t.Errorf("Expected a next page after a full page once the total is forgotten")
+	}
+}
+
+func TestRangeDefaults(t *testing.T) {
+
+	r := NewRangeWith(0, "")
+	if _, header := r.GetRequestRangeHeader(); header != fmt.Sprintf("items=0-%d", RANGE_PER_PAGE-1) {
+		t.Errorf("Unexpected default range, got %s", header)
+	}
+	if _, mode := r.GetRequestModeHeader(); mode != string(RANGE_COUNT_EXACT) {
+		t.Errorf("Unexpected default count mode, got %s", mode)
+	}
+}
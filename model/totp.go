@@ -0,0 +1,197 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TOTP_ALGORITHM_SHA1   = "SHA1"
+	TOTP_ALGORITHM_SHA256 = "SHA256"
+	TOTP_ALGORITHM_SHA512 = "SHA512"
+
+	TOTP_DEFAULT_DIGITS = 6
+	TOTP_DEFAULT_PERIOD = 30 * time.Second
+)
+
+// ErrNoTOTP The vault record has no totp secret, or it was not retrieved
+var ErrNoTOTP = errors.New("vault record has no totp secret")
+
+// TOTP Parameters of a time-based one-time password (RFC 6238)
+type TOTP struct {
+	Secret    []byte
+	Digits    int
+	Period    time.Duration
+	Algorithm string
+}
+
+// ParseTOTP Parse a totp secret, either a base32 encoded key or an otpauth:// uri with the optional digits, period and
+// algorithm parameters. Missing parameters get the defaults used by authenticator apps: 6 digits, 30 seconds and SHA1.
+func ParseTOTP(value string) (*TOTP, error) {
+	totp := &TOTP{Digits: TOTP_DEFAULT_DIGITS, Period: TOTP_DEFAULT_PERIOD, Algorithm: TOTP_ALGORITHM_SHA1}
+	secret := value
+
+	if strings.HasPrefix(strings.ToLower(value), "otpauth://") {
+		u, err := url.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid otpauth uri: %w", err)
+		}
+		if u.Host != "totp" {
+			return nil, fmt.Errorf("unsupported otpauth type %q", u.Host)
+		}
+
+		params := u.Query()
+		secret = params.Get("secret")
+		if digits := params.Get("digits"); digits != "" {
+			if totp.Digits, err = strconv.Atoi(digits); err != nil {
+				return nil, fmt.Errorf("invalid otpauth digits %q", digits)
+			}
+		}
+		if period := params.Get("period"); period != "" {
+			seconds, err := strconv.Atoi(period)
+			if err != nil {
+				return nil, fmt.Errorf("invalid otpauth period %q", period)
+			}
+			totp.Period = time.Duration(seconds) * time.Second
+		}
+		if algorithm := params.Get("algorithm"); algorithm != "" {
+			totp.Algorithm = strings.ToUpper(algorithm)
+		}
+	}
+
+	// Keys are often shown in groups of four and without padding
+	secret = strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(secret))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid totp secret: %w", err)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("invalid totp secret: empty")
+	}
+	totp.Secret = key
+
+	if err := totp.validate(); err != nil {
+		return nil, err
+	}
+
+	return totp, nil
+}
+
+// validate Return an error when the parameters cannot produce a valid code
+func (t *TOTP) validate() error {
+	if len(t.Secret) == 0 {
+		return fmt.Errorf("invalid totp secret: empty")
+	}
+	if t.Digits < 6 || t.Digits > 10 {
+		return fmt.Errorf("invalid totp digits %d, must be between 6 and 10", t.Digits)
+	}
+	if t.Period < time.Second {
+		return fmt.Errorf("invalid totp period %s, must be at least 1s", t.Period)
+	}
+	_, err := t.hash()
+	return err
+}
+
+// URL Return the parameters as an otpauth:// uri for account at issuer, the form authenticator apps import
+func (t *TOTP) URL(issuer string, account string) string {
+	params := url.Values{}
+	params.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(t.Secret))
+	params.Set("digits", strconv.Itoa(t.Digits))
+	params.Set("period", strconv.Itoa(int(t.Period/time.Second)))
+	params.Set("algorithm", t.Algorithm)
+	if issuer != "" {
+		params.Set("issuer", issuer)
+		account = issuer + ":" + account
+	}
+
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + account, RawQuery: params.Encode()}
+	return u.String()
+}
+
+func (t *TOTP) hash() (func() hash.Hash, error) {
+	switch t.Algorithm {
+	case TOTP_ALGORITHM_SHA1:
+		return sha1.New, nil
+	case TOTP_ALGORITHM_SHA256:
+		return sha256.New, nil
+	case TOTP_ALGORITHM_SHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported totp algorithm %q", t.Algorithm)
+}
+
+// Code Return the one-time password valid at the given time
+func (t *TOTP) Code(at time.Time) (string, error) {
+	if err := t.validate(); err != nil {
+		return "", err
+	}
+	h, _ := t.hash()
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(at.Unix()/int64(t.Period/time.Second)))
+
+	mac := hmac.New(h, t.Secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint64(1)
+	for range t.Digits {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", t.Digits, uint64(value)%modulo), nil
+}
+
+// ValidUntil Return the moment the code for the given time expires, a Period below one second counts as the default period
+func (t *TOTP) ValidUntil(at time.Time) time.Time {
+	period := int64(t.Period / time.Second)
+	if period <= 0 {
+		period = int64(TOTP_DEFAULT_PERIOD / time.Second)
+	}
+	return time.Unix((at.Unix()/period+1)*period, 0)
+}
+
+// TOTPCode Return the one-time password valid at the given time, the record must be retrieved with its secrets
+func (r *VaultRecord) TOTPCode(at time.Time) (string, error) {
+	secret := r.Totp()
+	if secret == nil || *secret == "" {
+		return "", ErrNoTOTP
+	}
+
+	totp, err := ParseTOTP(*secret)
+	if err != nil {
+		return "", err
+	}
+
+	return totp.Code(at)
+}
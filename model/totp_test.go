@@ -0,0 +1,98 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package model
+
+import (
+	"encoding/base32"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+
+	// Test vectors of RFC 6238 appendix B
+	sha1Key := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	sha256Key := base32.StdEncoding.EncodeToString([]byte("12345678901234567890123456789012"))
+	sha512Key := base32.StdEncoding.EncodeToString([]byte("1234567890123456789012345678901234567890123456789012345678901234"))
+
+	tests := []struct {
+		secret string
+		at     int64
+		want   string
+	}{
+		{sha1Key, 59, "287082"},
+		{"otpauth://totp/KeyHub:jdoe?secret=" + sha1Key + "&digits=8", 59, "94287082"},
+		{"otpauth://totp/KeyHub:jdoe?secret=" + sha1Key + "&digits=8", 1111111109, "07081804"},
+		{"otpauth://totp/KeyHub:jdoe?secret=" + sha256Key + "&digits=8&algorithm=SHA256", 1111111111, "67062674"},
+		{"otpauth://totp/KeyHub:jdoe?secret=" + sha512Key + "&digits=8&algorithm=sha512", 2000000000, "38618901"},
+		{"otpauth://totp/KeyHub:jdoe?secret=" + sha1Key + "&digits=8&period=60", 119, "94287082"},
+	}
+
+	for _, test := range tests {
+		secret := test.secret
+		record := NewVaultRecord("totp", &VaultRecordSecretAdditionalObject{Totp: &secret})
+		got, err := record.TOTPCode(time.Unix(test.at, 0))
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", test.secret, err.Error())
+		}
+		if got != test.want {
+			t.Errorf("Result differs for %s at %d, want `%v`, got `%v`", test.secret, test.at, test.want, got)
+		}
+	}
+}
+
+func TestTOTPInvalid(t *testing.T) {
+
+	record := NewVaultRecord("empty", &VaultRecordSecretAdditionalObject{})
+	if _, err := record.TOTPCode(time.Now()); !errors.Is(err, ErrNoTOTP) {
+		t.Errorf("Expected ErrNoTOTP, got %v", err)
+	}
+
+	for _, secret := range []string{
+		"not base32!",
+		"otpauth://hotp/KeyHub?secret=GEZDGNBV",
+		"otpauth://totp/KeyHub?secret=GEZDGNBV&algorithm=MD5",
+		"otpauth://totp/KeyHub?secret=GEZDGNBV&digits=4",
+		"otpauth://totp/KeyHub?secret=GEZDGNBV&period=0",
+	} {
+		if _, err := ParseTOTP(secret); err == nil {
+			t.Errorf("Expected an error for %s", secret)
+		}
+	}
+
+	totp, err := ParseTOTP("gezd gnbv")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if until := totp.ValidUntil(time.Unix(59, 0)); until.Unix() != 60 {
+		t.Errorf("Result differs, want 60, got %d", until.Unix())
+	}
+
+	for _, invalid := range []TOTP{
+		{Secret: totp.Secret},
+		{Secret: totp.Secret, Digits: 6, Period: 500 * time.Millisecond, Algorithm: TOTP_ALGORITHM_SHA1},
+		{Secret: totp.Secret, Digits: -1, Period: TOTP_DEFAULT_PERIOD, Algorithm: TOTP_ALGORITHM_SHA1},
+		{Secret: totp.Secret, Digits: 20, Period: TOTP_DEFAULT_PERIOD, Algorithm: TOTP_ALGORITHM_SHA1},
+	} {
+		if _, err := invalid.Code(time.Unix(59, 0)); err == nil {
+			t.Errorf("Expected an error for %+v", invalid)
+		}
+	}
+	if until := (&TOTP{Secret: totp.Secret}).ValidUntil(time.Unix(59, 0)); until.Unix() != 60 {
+		t.Errorf("Result differs, want the default period ending at 60, got %d", until.Unix())
+	}
+}
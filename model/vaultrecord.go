@@ -37,6 +37,40 @@ const (
 
 type RecordWarningPeriod string
 
+// WarningStart Return the date KeyHub starts warning about a record ending at endDate, ok is false when it never warns
+func (p RecordWarningPeriod) WarningStart(endDate time.Time) (start time.Time, ok bool) {
+	months, days, ok := p.offset()
+	if !ok {
+		return time.Time{}, false
+	}
+	return endDate.AddDate(0, -months, -days), true
+}
+
+// MinimalEndDate Return the first end date for which KeyHub does not warn yet at t
+func (p RecordWarningPeriod) MinimalEndDate(t time.Time) time.Time {
+	months, days, _ := p.offset()
+	t = t.AddDate(0, months, days+1)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (p RecordWarningPeriod) offset() (months int, days int, ok bool) {
+	switch p {
+	case WARNINGPERIOD_AT_EXPIRATION:
+		return 0, 0, true
+	case WARNINGPERIOD_TWO_WEEKS:
+		return 0, 14, true
+	case WARNINGPERIOD_ONE_MONTH:
+		return 1, 0, true
+	case WARNINGPERIOD_TWO_MONTHS:
+		return 2, 0, true
+	case WARNINGPERIOD_THREE_MONTHS:
+		return 3, 0, true
+	case WARNINGPERIOD_SIX_MONTHS:
+		return 6, 0, true
+	}
+	return 0, 0, false
+}
+
 type VaultRecord struct {
 	Linkable
 	AdditionalObjects *VaultRecordAdditionalObjects `json:"additionalObjects,omitempty"`
@@ -134,14 +168,30 @@ func (r *VaultRecord) LastModifiedBy() string {
 }
 
 func (r *VaultRecord) Comment() *string {
+	if r.AdditionalObjects == nil || r.AdditionalObjects.Secret == nil {
+		return nil
+	}
 	return r.AdditionalObjects.Secret.Comment
 }
 
 func (r *VaultRecord) Password() *string {
+	if r.AdditionalObjects == nil || r.AdditionalObjects.Secret == nil {
+		return nil
+	}
 	return r.AdditionalObjects.Secret.Password
 }
 
+func (r *VaultRecord) Totp() *string {
+	if r.AdditionalObjects == nil || r.AdditionalObjects.Secret == nil {
+		return nil
+	}
+	return r.AdditionalObjects.Secret.Totp
+}
+
 func (r *VaultRecord) File() *[]byte {
+	if r.AdditionalObjects == nil || r.AdditionalObjects.Secret == nil {
+		return nil
+	}
 	return r.AdditionalObjects.Secret.File
 }
 
@@ -0,0 +1,210 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package keyhub
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	// defaultTimeout Timeout used when neither WithTimeout nor a http.Client with a timeout is configured
+	defaultTimeout = 10 * time.Second
+	// defaultContractVersion Contract version used for the accounts, clients, systems, launchpad tiles and service accounts
+	defaultContractVersion = 60
+)
+
+// ClientOption Configures the Client created by New
+type ClientOption func(*clientConfig)
+
+type clientConfig struct {
+	httpClient      *http.Client
+	timeout         time.Duration
+	userAgent       string
+	contractVersion int
+	scopes          []string
+	clientID        string
+	tokenSourceFunc TokenSourceFunc
+	retryPolicy     RetryPolicy
+	rateLimit       RateLimit
+	logger          *slog.Logger
+}
+
+func newClientConfig(opts []ClientOption) *clientConfig {
+	cfg := &clientConfig{
+		contractVersion: defaultContractVersion,
+		scopes:          []string{oidc.ScopeOpenID},
+		retryPolicy:     DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.logger == nil {
+		cfg.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	return cfg
+}
+
+// buildHTTPClient Return a copy of the configured http.Client, the client passed to WithHTTPClient is never modified.
+// The rate limiter is returned separately for its statistics, it is nil when no limits are configured.
+func (cfg *clientConfig) buildHTTPClient() (*http.Client, *RateLimitTransport) {
+	var rateLimiter *RateLimitTransport
+	httpClient := &http.Client{}
+	if cfg.httpClient != nil {
+		*httpClient = *cfg.httpClient
+	}
+
+	// Below the retries, so every attempt is limited
+	if cfg.rateLimit.RequestsPerSecond > 0 || cfg.rateLimit.MaxInFlight > 0 {
+		rateLimiter = NewRateLimitTransport(httpClient.Transport, cfg.rateLimit)
+		httpClient.Transport = rateLimiter
+	}
+
+	if cfg.retryPolicy.MaxAttempts > 1 {
+		httpClient.Transport = &RetryTransport{
+			Base:   httpClient.Transport,
+			Policy: cfg.retryPolicy,
+			Logger: cfg.logger,
+		}
+	}
+
+	if cfg.timeout > 0 {
+		httpClient.Timeout = cfg.timeout
+	} else if httpClient.Timeout == 0 {
+		httpClient.Timeout = defaultTimeout
+	}
+
+	return httpClient, rateLimiter
+}
+
+// WithHTTPClient Use httpClient for all requests. The client is copied, so changes made by other options do not leak into it
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.httpClient = httpClient
+	}
+}
+
+// WithTimeout Set the timeout of every request, defaults to the timeout of the http.Client or 10 seconds
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithUserAgent Send userAgent as User-Agent header with every api request
+func WithUserAgent(userAgent string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.userAgent = userAgent
+	}
+}
+
+// WithContractVersion Use contract version for the services that do not use the latest contract, defaults to 60
+func WithContractVersion(version int) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.contractVersion = version
+	}
+}
+
+// WithScopes Request scopes when obtaining a token, defaults to openid
+func WithScopes(scopes ...string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.scopes = scopes
+	}
+}
+
+// WithClientCredentials Authenticate as a client application using the client credentials grant.
+// Only the last of the options configuring credentials takes effect.
+func WithClientCredentials(clientID string, clientSecret string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.clientID = clientID
+		cfg.tokenSourceFunc = func(ctx context.Context, endpoint oauth2.Endpoint) (oauth2.TokenSource, error) {
+			appClientConf := clientcredentials.Config{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				Scopes:       cfg.scopes,
+				TokenURL:     endpoint.TokenURL,
+			}
+			return appClientConf.TokenSource(ctx), nil
+		}
+	}
+}
+
+// WithTokenSource Authenticate with the tokens of tokenSource, e.g. tokens read by NewFileTokenSource.
+// Only the last of the options configuring credentials takes effect.
+func WithTokenSource(tokenSource oauth2.TokenSource) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.tokenSourceFunc = func(ctx context.Context, endpoint oauth2.Endpoint) (oauth2.TokenSource, error) {
+			return tokenSource, nil
+		}
+	}
+}
+
+// WithTokenSourceFunc Authenticate with the tokens of the source created by fn once the endpoints of the issuer are known.
+// Only the last of the options configuring credentials takes effect.
+func WithTokenSourceFunc(fn TokenSourceFunc) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.tokenSourceFunc = fn
+	}
+}
+
+// WithJWTBearer Authenticate by exchanging the JWT returned by assertion for an access token, e.g. a workload identity token.
+// Only the last of the options configuring credentials takes effect.
+func WithJWTBearer(assertion AssertionFunc) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.tokenSourceFunc = func(ctx context.Context, endpoint oauth2.Endpoint) (oauth2.TokenSource, error) {
+			return NewJWTBearerTokenSource(ctx, endpoint.TokenURL, cfg.scopes, assertion), nil
+		}
+	}
+}
+
+// WithRetryPolicy Retry failed requests according to policy instead of DefaultRetryPolicy, a MaxAttempts of 1 disables retries.
+// The timeout of the client covers all attempts of a request together.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.retryPolicy = policy
+	}
+}
+
+// WithRateLimit Send at most requestsPerSecond requests per second on average with bursts of burst requests
+func WithRateLimit(requestsPerSecond float64, burst int) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.rateLimit.RequestsPerSecond = requestsPerSecond
+		cfg.rateLimit.Burst = burst
+	}
+}
+
+// WithMaxInFlight Wait for a response before sending more than maxInFlight requests at the same time
+func WithMaxInFlight(maxInFlight int) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.rateLimit.MaxInFlight = maxInFlight
+	}
+}
+
+// WithLogger Log diagnostic messages to logger, nothing is logged by default
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.logger = logger
+	}
+}
@@ -0,0 +1,257 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package keyhub
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"sync"
+
+	"github.com/dghubble/sling"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+// ListOption Configures how the pages of a list are fetched
+type ListOption func(*listOptions)
+
+type listOptions struct {
+	pageSize      int
+	countMode     model.RangeCountMode
+	parallelPages int
+}
+
+// WithPageSize Fetch size items per request instead of model.RANGE_PER_PAGE
+func WithPageSize(size int) ListOption {
+	return func(o *listOptions) {
+		o.pageSize = size
+	}
+}
+
+// WithCountMode Let KeyHub determine the total number of items using mode. Counting exactly is expensive for large
+// results, with model.RANGE_COUNT_NONE paging stops at the first page that is not full.
+func WithCountMode(mode model.RangeCountMode) ListOption {
+	return func(o *listOptions) {
+		o.countMode = mode
+	}
+}
+
+// WithParallelPages Fetch the pages after the first one with workers concurrent requests, items are still returned in
+// order. The pages are planned from the total in the first response, so this has no effect with model.RANGE_COUNT_NONE.
+// When the total was an estimate (model.RANGE_COUNT_ESTIMATE) or items were added while listing, the pages after the
+// planned ones are fetched one at a time for as long as they are full.
+func WithParallelPages(workers int) ListOption {
+	return func(o *listOptions) {
+		o.parallelPages = workers
+	}
+}
+
+func newListOptions(opts []ListOption) *listOptions {
+	o := &listOptions{pageSize: model.RANGE_PER_PAGE, countMode: model.RANGE_COUNT_EXACT}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// listPage A single page of any KeyHub list response
+type listPage[T any] struct {
+	Items []T `json:"items"`
+}
+
+// paginate Iterate the items of all pages returned by request. The next page is only fetched once the caller has
+// ranged over all items of the previous page, so breaking out of the loop stops fetching.
+// An error is yielded once and ends the iteration, errorFormat and errorArgs describe errors reported by KeyHub.
+func paginate[T any](ctx context.Context, request *sling.Sling, opts []ListOption, errorFormat string, errorArgs ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		options := newListOptions(opts)
+		searchRange := model.NewRangeWith(options.pageSize, options.countMode)
+		beyondTotal := false
+
+		for ok := true; ok; ok = searchRange.NextPage() {
+
+			items, last, err := fetchPage[T](ctx, request, searchRange, errorFormat, errorArgs)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if last {
+				return
+			}
+
+			if beyondTotal {
+				searchRange.ForgetTotal()
+			} else if _, known := searchRange.Total(); known && options.parallelPages > 1 {
+				if !paginateParallel(ctx, request, searchRange, options.parallelPages, yield, errorFormat, errorArgs) {
+					return
+				}
+				// The total was an estimate or items were added while listing, continue while the pages are full
+				beyondTotal = true
+				searchRange.ForgetTotal()
+			}
+		}
+	}
+}
+
+// fetchPage Fetch the items in the current range of searchRange and update it with the response.
+// last is true when KeyHub reports the range lies beyond the last item.
+func fetchPage[T any](ctx context.Context, request *sling.Sling, searchRange *model.Range, errorFormat string, errorArgs []any) (items []T, last bool, err error) {
+	errorReport := new(model.ErrorReport)
+	results := new(listPage[T])
+	response, err := receive(ctx, request.New().Add(searchRange.GetRequestRangeHeader()).Add(searchRange.GetRequestModeHeader()), results, errorReport)
+	if response != nil && response.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The previous page was full and happened to hold the last items
+		return nil, true, nil
+	}
+	searchRange.ParseResponse(response)
+
+	if errorReport.Code > 0 {
+		err = errorReport.Wrap(errorFormat, errorArgs...)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return results.Items, false, nil
+}
+
+// pageResult The outcome of fetching one page by paginateParallel, done is closed once it is filled in
+type pageResult[T any] struct {
+	items []T
+	last  bool
+	err   error
+	done  chan struct{}
+}
+
+// paginateParallel Fetch the pages up to the total in searchRange with the given number of workers and yield their
+// items in order. At most two pages per worker are fetched ahead of the caller. The first error cancels all other
+// requests and is yielded right away, items of earlier pages that were not yielded yet are skipped.
+// more is true when all planned pages were yielded and the last one was not known to be the end of the list, searchRange
+// is then set to the range of that page.
+func paginateParallel[T any](ctx context.Context, request *sling.Sling, searchRange *model.Range, workers int, yield func(T, error) bool, errorFormat string, errorArgs []any) (more bool) {
+	var zero T
+
+	var pages []*model.Range
+	next := *searchRange
+	for next.NextPage() {
+		page := next
+		pages = append(pages, &page)
+	}
+	if len(pages) == 0 {
+		return false
+	}
+
+	// Deferred in this order so the workers are cancelled before waiting for them
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*pageResult[T], len(pages))
+	for i := range results {
+		results[i] = &pageResult[T]{done: make(chan struct{})}
+	}
+
+	var (
+		failOnce sync.Once
+		failed   = make(chan struct{})
+		firstErr error
+	)
+	fail := func(err error) {
+		failOnce.Do(func() {
+			firstErr = err
+			close(failed)
+			cancel()
+		})
+	}
+
+	// ahead limits the number of pages fetched but not yet yielded
+	ahead := make(chan struct{}, 2*workers)
+	queue := make(chan int)
+
+	go func() {
+		defer close(queue)
+		for i := range pages {
+			select {
+			case ahead <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case queue <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for range min(workers, len(pages)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				result := results[i]
+				result.items, result.last, result.err = fetchPage[T](ctx, request, pages[i], errorFormat, errorArgs)
+				if result.err != nil {
+					fail(result.err)
+				}
+				close(result.done)
+			}
+		}()
+	}
+
+	for _, result := range results {
+		select {
+		case <-result.done:
+		case <-failed:
+			yield(zero, firstErr)
+			return false
+		}
+		if result.err != nil {
+			yield(zero, firstErr)
+			return false
+		}
+		for _, item := range result.items {
+			if !yield(item, nil) {
+				return false
+			}
+		}
+		if result.last {
+			return false
+		}
+		<-ahead
+	}
+
+	*searchRange = *pages[len(pages)-1]
+	return true
+}
+
+// collect Gather all items of seq, stopping at the first error
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	items := []T{}
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,387 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package keyhub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub/model"
+	"gopkg.in/yaml.v3"
+)
+
+// PlanAction What Apply does with a record
+type PlanAction string
+
+const (
+	PLAN_CREATE PlanAction = "create"
+	PLAN_UPDATE PlanAction = "update"
+	PLAN_DELETE PlanAction = "delete"
+)
+
+// VaultSpec The desired records per group, as read from YAML by ReadVaultSpec:
+//
+//	groups:
+//	  - uuid: <group uuid>
+//	    records:
+//	      - name: database
+//	        url: https://db.example.com
+//	        username: app
+//	        warningPeriod: ONE_MONTH
+//	        endDate: 2030-01-31
+//	        types: [PASSWORD]
+type VaultSpec struct {
+	Groups []GroupVaultSpec `yaml:"groups"`
+}
+
+// GroupVaultSpec The desired records of the group with UUID
+type GroupVaultSpec struct {
+	UUID    string       `yaml:"uuid"`
+	Records []RecordSpec `yaml:"records"`
+}
+
+// RecordSpec The desired non-secret properties of a record. A record is matched by UUID when it is set, otherwise by
+// name. Properties left out (nil) are not managed and keep their current value, an empty value clears the property.
+type RecordSpec struct {
+	UUID          string                     `yaml:"uuid,omitempty"`
+	Name          string                     `yaml:"name"`
+	URL           *string                    `yaml:"url,omitempty"`
+	Username      *string                    `yaml:"username,omitempty"`
+	Color         *string                    `yaml:"color,omitempty"`
+	WarningPeriod *model.RecordWarningPeriod `yaml:"warningPeriod,omitempty"`
+	// EndDate End date as "2006-01-02"
+	EndDate *string  `yaml:"endDate,omitempty"`
+	Types   []string `yaml:"types,omitempty"`
+}
+
+// ReadVaultSpec Read a VaultSpec from YAML. Unknown keys are rejected, so a typo or a secret value in the file fails
+// instead of being ignored.
+func ReadVaultSpec(r io.Reader) (*VaultSpec, error) {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+
+	spec := &VaultSpec{}
+	if err := decoder.Decode(spec); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid vault spec: %w", err)
+	}
+	return spec, nil
+}
+
+// FieldChange A property of a record that changes, values are never secret
+type FieldChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+// PlanChange A change Apply makes to a record of Group. UUID is empty for records to create.
+type PlanChange struct {
+	Action PlanAction
+	Group  *model.Group
+	UUID   string
+	Name   string
+	Fields []FieldChange
+
+	spec    *RecordSpec
+	current *model.VaultRecord
+}
+
+// VaultPlan The changes that make the vaults match a VaultSpec
+type VaultPlan struct {
+	Changes []PlanChange
+}
+
+// Empty Return true when the vaults already match the spec
+func (p *VaultPlan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// String Return the plan in a human-readable form, one line per record followed by its changed properties
+func (p *VaultPlan) String() string {
+	var b strings.Builder
+	var group *model.Group
+	counts := make(map[PlanAction]int)
+	for _, change := range p.Changes {
+		if change.Group != group {
+			group = change.Group
+			fmt.Fprintf(&b, "group %q (%s)\n", group.Name, group.UUID)
+		}
+		counts[change.Action]++
+
+		switch change.Action {
+		case PLAN_CREATE:
+			fmt.Fprintf(&b, "  + create %q\n", change.Name)
+		case PLAN_UPDATE:
+			fmt.Fprintf(&b, "  ~ update %q (%s)\n", change.Name, change.UUID)
+		case PLAN_DELETE:
+			fmt.Fprintf(&b, "  - delete %q (%s)\n", change.Name, change.UUID)
+		}
+		for _, field := range change.Fields {
+			if change.Action == PLAN_CREATE {
+				fmt.Fprintf(&b, "      %s: %q\n", field.Field, field.To)
+			} else {
+				fmt.Fprintf(&b, "      %s: %q => %q\n", field.Field, field.From, field.To)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "Plan: %d to create, %d to update, %d to delete\n", counts[PLAN_CREATE], counts[PLAN_UPDATE], counts[PLAN_DELETE])
+	return b.String()
+}
+
+// PlanOption Option of VaultService.Plan
+type PlanOption func(options *planOptions)
+
+type planOptions struct {
+	prune bool
+}
+
+// WithPrune Delete the records of a group in the spec that the spec does not list
+func WithPrune() PlanOption {
+	return func(options *planOptions) {
+		options.prune = true
+	}
+}
+
+// Plan Compute the changes that make the records of the groups in spec match it. Groups holds the groups the spec
+// refers to, e.g. as retrieved by GroupService.List. Secrets are never retrieved, the plan only holds properties of
+// RecordSpec. Without WithPrune records the spec does not list are left alone.
+func (s *VaultService) Plan(ctx context.Context, spec *VaultSpec, groups []model.Group, opts ...PlanOption) (*VaultPlan, error) {
+	options := &planOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	plan := &VaultPlan{}
+	for i := range spec.Groups {
+		groupSpec := &spec.Groups[i]
+		index := slices.IndexFunc(groups, func(group model.Group) bool { return strings.EqualFold(group.UUID, groupSpec.UUID) })
+		if index < 0 {
+			return nil, fmt.Errorf("Group %q of the vault spec %w", groupSpec.UUID, ErrNotFound)
+		}
+		changes, err := s.planGroup(ctx, &groups[index], groupSpec, options)
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append(plan.Changes, changes...)
+	}
+	return plan, nil
+}
+
+// planGroup Return the changes to the records of group
+func (s *VaultService) planGroup(ctx context.Context, group *model.Group, spec *GroupVaultSpec, options *planOptions) ([]PlanChange, error) {
+	var current []model.VaultRecord
+	for record, err := range s.All(ctx, group, nil) {
+		if err != nil {
+			return nil, err
+		}
+		current = append(current, record)
+	}
+
+	var changes []PlanChange
+	matched := make(map[string]bool)
+	for i := range spec.Records {
+		recordSpec := &spec.Records[i]
+		if recordSpec.Name == "" {
+			return nil, fmt.Errorf("record %d of Group %q in the vault spec has no name", i+1, group.UUID)
+		}
+
+		record, err := matchRecord(current, recordSpec)
+		if err != nil {
+			return nil, fmt.Errorf("%w in Group %q", err, group.UUID)
+		}
+		if record == nil {
+			fields, err := recordSpec.diff(&model.VaultRecord{})
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, PlanChange{Action: PLAN_CREATE, Group: group, Name: recordSpec.Name, Fields: fields, spec: recordSpec})
+			continue
+		}
+
+		if matched[record.UUID] {
+			return nil, fmt.Errorf("VaultRecord %q of Group %q is listed more than once in the vault spec", record.Name, group.UUID)
+		}
+		matched[record.UUID] = true
+
+		fields, err := recordSpec.diff(record)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			changes = append(changes, PlanChange{Action: PLAN_UPDATE, Group: group, UUID: record.UUID, Name: record.Name, Fields: fields, spec: recordSpec, current: record})
+		}
+	}
+
+	if options.prune {
+		var deletes []PlanChange
+		for i := range current {
+			if !matched[current[i].UUID] {
+				deletes = append(deletes, PlanChange{Action: PLAN_DELETE, Group: group, UUID: current[i].UUID, Name: current[i].Name, current: &current[i]})
+			}
+		}
+		sort.SliceStable(deletes, func(i, j int) bool { return deletes[i].Name < deletes[j].Name })
+		changes = append(changes, deletes...)
+	}
+
+	return changes, nil
+}
+
+// matchRecord Return the record of current matching spec by uuid or name, nil when there is none
+func matchRecord(current []model.VaultRecord, spec *RecordSpec) (*model.VaultRecord, error) {
+	if spec.UUID != "" {
+		for i := range current {
+			if strings.EqualFold(current[i].UUID, spec.UUID) {
+				return &current[i], nil
+			}
+		}
+		return nil, fmt.Errorf("VaultRecord %q %w", spec.UUID, ErrNotFound)
+	}
+
+	var match *model.VaultRecord
+	for i := range current {
+		if current[i].Name == spec.Name {
+			if match != nil {
+				return nil, fmt.Errorf("more than one VaultRecord is named %q, match it by uuid", spec.Name)
+			}
+			match = &current[i]
+		}
+	}
+	return match, nil
+}
+
+// diff Return the properties of record that differ from spec
+func (spec *RecordSpec) diff(record *model.VaultRecord) ([]FieldChange, error) {
+	var fields []FieldChange
+	add := func(field string, from string, to string) {
+		if from != to {
+			fields = append(fields, FieldChange{Field: field, From: from, To: to})
+		}
+	}
+
+	add("name", record.Name, spec.Name)
+	if spec.URL != nil {
+		add("url", record.URL, *spec.URL)
+	}
+	if spec.Username != nil {
+		add("username", record.Username, *spec.Username)
+	}
+	if spec.Color != nil {
+		add("color", record.Color, *spec.Color)
+	}
+	if spec.WarningPeriod != nil {
+		add("warningPeriod", string(record.WarningPeriod), string(*spec.WarningPeriod))
+	}
+	if spec.EndDate != nil {
+		endDate, err := spec.endDate()
+		if err != nil {
+			return nil, err
+		}
+		add("endDate", formatEndDate(record.EndDate), formatEndDate(endDate))
+	}
+	if spec.Types != nil {
+		add("types", strings.Join(record.Types, ", "), strings.Join(spec.Types, ", "))
+	}
+	return fields, nil
+}
+
+// apply Set the managed properties of spec on record
+func (spec *RecordSpec) apply(record *model.VaultRecord) error {
+	record.Name = spec.Name
+	if spec.URL != nil {
+		record.URL = *spec.URL
+	}
+	if spec.Username != nil {
+		record.Username = *spec.Username
+	}
+	if spec.Color != nil {
+		record.Color = *spec.Color
+	}
+	if spec.WarningPeriod != nil {
+		record.WarningPeriod = *spec.WarningPeriod
+	}
+	if spec.EndDate != nil {
+		endDate, err := spec.endDate()
+		if err != nil {
+			return err
+		}
+		record.EndDate = endDate
+	}
+	if spec.Types != nil {
+		record.Types = spec.Types
+	}
+	return nil
+}
+
+// endDate Return the parsed EndDate, zero when it is empty
+func (spec *RecordSpec) endDate() (time.Time, error) {
+	if *spec.EndDate == "" {
+		return time.Time{}, nil
+	}
+	endDate, err := time.Parse("2006-01-02", *spec.EndDate)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid end date of %q in the vault spec: %w", spec.Name, err)
+	}
+	return endDate, nil
+}
+
+// formatEndDate Return endDate as "2006-01-02", or an empty string when it is not set
+func formatEndDate(endDate time.Time) string {
+	if endDate.IsZero() {
+		return ""
+	}
+	return endDate.Format("2006-01-02")
+}
+
+// Apply Execute the changes of plan in order, returning the number of changes made. Apply stops at the first error,
+// the changes before it are made. Updated records are retrieved with their secrets, so these are sent back unchanged.
+func (s *VaultService) Apply(ctx context.Context, plan *VaultPlan) (applied int, err error) {
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case PLAN_CREATE:
+			record := model.NewVaultRecord(change.Name, &model.VaultRecordSecretAdditionalObject{})
+			if err = change.spec.apply(record); err == nil {
+				_, err = s.CreateContext(ctx, change.Group, record)
+			}
+		case PLAN_UPDATE:
+			var record *model.VaultRecord
+			record, err = s.GetBySelfContext(ctx, change.current, &model.VaultRecordAdditionalQueryParams{Secret: true})
+			if err == nil {
+				if err = change.spec.apply(record); err == nil {
+					_, err = s.UpdateContext(ctx, change.Group, record)
+				}
+			}
+		case PLAN_DELETE:
+			var recordUUID uuid.UUID
+			recordUUID, err = uuid.Parse(change.UUID)
+			if err == nil {
+				err = s.DeleteByUUIDContext(ctx, change.Group, recordUUID)
+			}
+		default:
+			err = fmt.Errorf("unknown plan action %q", change.Action)
+		}
+		if err != nil {
+			return applied, fmt.Errorf("could not %s VaultRecord %q: %w", change.Action, change.Name, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
@@ -0,0 +1,186 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package keyhub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit Configures client side limits on the requests sent to KeyHub
+type RateLimit struct {
+	// RequestsPerSecond Sustained request rate of the token bucket, unlimited when <= 0
+	RequestsPerSecond float64
+	// Burst Size of the token bucket, defaults to 1
+	Burst int
+	// MaxInFlight Maximum number of requests in flight at the same time, unlimited when <= 0. A request is in flight until
+	// its response body is closed.
+	MaxInFlight int
+}
+
+// LimiterStats Statistics of a RateLimitTransport, use them to tune the RateLimit
+type LimiterStats struct {
+	// Requests Number of requests sent
+	Requests uint64
+	// Delayed Number of requests that waited for the token bucket or a free in-flight slot
+	Delayed uint64
+	// Cancelled Number of requests whose context was cancelled while waiting
+	Cancelled uint64
+	// DeadlineExceeded Number of requests whose context deadline passed, or would pass, while waiting
+	DeadlineExceeded uint64
+	// TotalWait Time spent waiting by all requests together
+	TotalWait time.Duration
+	// MaxWait Longest time a single request waited
+	MaxWait time.Duration
+	// InFlight Number of requests currently waiting for a response or whose body is not closed yet
+	InFlight int
+	// PeakInFlight Highest number of requests in flight at the same time
+	PeakInFlight int
+}
+
+// RateLimitTransport Delays requests so they stay within a RateLimit, waiting stops when the context of the request is done
+type RateLimitTransport struct {
+	Base http.RoundTripper
+
+	limiter *rate.Limiter
+	slots   chan struct{}
+
+	mu    sync.Mutex
+	stats LimiterStats
+}
+
+// NewRateLimitTransport Create a RateLimitTransport sending the requests within limit through base
+func NewRateLimitTransport(base http.RoundTripper, limit RateLimit) *RateLimitTransport {
+	t := &RateLimitTransport{Base: base}
+
+	if limit.RequestsPerSecond > 0 {
+		t.limiter = rate.NewLimiter(rate.Limit(limit.RequestsPerSecond), max(limit.Burst, 1))
+	}
+	if limit.MaxInFlight > 0 {
+		t.slots = make(chan struct{}, limit.MaxInFlight)
+	}
+
+	return t
+}
+
+// RoundTrip Wait for a free in-flight slot and the token bucket, then perform req. The slot is released when the body of
+// the response is closed.
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx := req.Context()
+	start := time.Now()
+
+	if t.slots != nil {
+		select {
+		case t.slots <- struct{}{}:
+		case <-ctx.Done():
+			t.record(time.Since(start), ctx.Err())
+			return nil, ctx.Err()
+		}
+	}
+
+	if t.limiter != nil {
+		if err := t.limiter.Wait(ctx); err != nil {
+			if ctx.Err() == nil {
+				// The limiter fails early when the wait would outlast the deadline of ctx
+				err = fmt.Errorf("%w: %s", context.DeadlineExceeded, err)
+			}
+			t.freeSlot()
+			t.record(time.Since(start), err)
+			return nil, err
+		}
+	}
+
+	t.record(time.Since(start), nil)
+
+	resp, err := base.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		t.done()
+		return resp, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: t.done}
+	return resp, nil
+}
+
+// freeSlot Free the in-flight slot taken by a request
+func (t *RateLimitTransport) freeSlot() {
+	if t.slots != nil {
+		<-t.slots
+	}
+}
+
+// done Free the in-flight slot of a sent request and stop counting it as in flight
+func (t *RateLimitTransport) done() {
+	t.freeSlot()
+	t.mu.Lock()
+	t.stats.InFlight--
+	t.mu.Unlock()
+}
+
+// releasingBody A response body that releases the in-flight slot of its request once it is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// record Add a wait to the statistics, requests sent (err is nil) are counted as in flight
+func (t *RateLimitTransport) record(wait time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Waits this short only cover acquiring the locks, not an actual delay
+	if wait > time.Millisecond {
+		t.stats.Delayed++
+		t.stats.TotalWait += wait
+		t.stats.MaxWait = max(t.stats.MaxWait, wait)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.stats.DeadlineExceeded++
+		return
+	}
+	if err != nil {
+		t.stats.Cancelled++
+		return
+	}
+	t.stats.Requests++
+	t.stats.InFlight++
+	t.stats.PeakInFlight = max(t.stats.PeakInFlight, t.stats.InFlight)
+}
+
+// Stats Return a snapshot of the statistics
+func (t *RateLimitTransport) Stats() LimiterStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
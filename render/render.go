@@ -0,0 +1,139 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Package render Renders text/template configuration files with secrets from KeyHub vault records.
+// Templates call the functions with a reference to a record, the field follows from the function:
+//
+//	password={{ keyhubPassword "keyhub://<group uuid>/<record uuid>" }}
+//	user={{ keyhubUsername "<group uuid>/<record uuid>" }}
+//
+// Rendering fails closed: a missing or expired record fails the whole render and nothing is written.
+package render
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/topicuskeyhub/go-keyhub/secretref"
+)
+
+// FileMode Permissions of rendered files, readable by the owner only
+const FileMode os.FileMode = 0600
+
+// ErrRecordExpired The EndDate of a referenced record lies in the past
+var ErrRecordExpired = errors.New("vault record has expired")
+
+// FuncMap Return the template functions keyhubPassword, keyhubUsername, keyhubURL, keyhubFile and keyhubTOTP,
+// resolving references with resolver
+func FuncMap(ctx context.Context, resolver *secretref.Resolver) template.FuncMap {
+	field := func(name string) func(string) (string, error) {
+		return func(ref string) (string, error) {
+			return resolve(ctx, resolver, ref, name)
+		}
+	}
+
+	return template.FuncMap{
+		"keyhubPassword": field(secretref.FieldPassword),
+		"keyhubUsername": field(secretref.FieldUsername),
+		"keyhubURL":      field(secretref.FieldURL),
+		"keyhubFile":     field(secretref.FieldFile),
+		"keyhubTOTP":     field(secretref.FieldTOTP),
+	}
+}
+
+// resolve Return field of the record ref points to, ref may leave out the keyhub:// scheme and must not have a field
+func resolve(ctx context.Context, resolver *secretref.Resolver, ref string, field string) (string, error) {
+	if strings.Contains(ref, "#") {
+		return "", fmt.Errorf("reference %q must not name a field", ref)
+	}
+	if !secretref.IsRef(ref) {
+		ref = secretref.Scheme + ref
+	}
+	parsed, err := secretref.Parse(ref + "#" + field)
+	if err != nil {
+		return "", err
+	}
+
+	record, err := resolver.Record(ctx, parsed)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %s: %w", parsed, err)
+	}
+	if !record.EndDate.IsZero() && !time.Now().Before(record.EndDate) {
+		return "", fmt.Errorf("could not resolve %s: %w on %s", parsed, ErrRecordExpired, record.EndDate.Format("2006-01-02"))
+	}
+
+	return resolver.ResolveRef(ctx, parsed)
+}
+
+// Render Execute the template text with data and write the result to w. Nothing is written when rendering fails.
+func Render(ctx context.Context, resolver *secretref.Resolver, w io.Writer, name string, text string, data any) error {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(FuncMap(ctx, resolver)).Parse(text)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return err
+	}
+	_, err = out.WriteTo(w)
+	return err
+}
+
+// RenderFile Render the template in templateFile with data to outputFile, which is replaced atomically and only
+// readable by the owner. outputFile is left untouched when rendering fails.
+func RenderFile(ctx context.Context, resolver *secretref.Resolver, templateFile string, outputFile string, data any) (err error) {
+	text, err := os.ReadFile(templateFile)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := Render(ctx, resolver, &out, filepath.Base(templateFile), string(text), data); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(outputFile), "."+filepath.Base(outputFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	// CreateTemp already uses 0600, Chmod makes sure the umask does not matter
+	if err = tmp.Chmod(FileMode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = out.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), outputFile)
+}
@@ -0,0 +1,132 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package render
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub/model"
+	"github.com/topicuskeyhub/go-keyhub/secretref"
+)
+
+var (
+	validUUID   = uuid.MustParse("00000000-0000-0000-0000-0000000000e1")
+	expiredUUID = uuid.MustParse("00000000-0000-0000-0000-0000000000e2")
+)
+
+// fakeVaults Serves a valid and an expired record to the client
+type fakeVaults struct{}
+
+func (fakeVaults) GetByUUIDContext(ctx context.Context, group *model.Group, id uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error) {
+	return nil, errors.New("not supported")
+}
+
+func (fakeVaults) FindByUUIDForClientContext(ctx context.Context, id uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error) {
+	password := "s3cret"
+	record := model.NewVaultRecord("db", &model.VaultRecordSecretAdditionalObject{Password: &password})
+	record.UUID = id.String()
+	record.Username = "app"
+	switch id {
+	case validUUID:
+		record.EndDate = time.Now().AddDate(0, 1, 0)
+	case expiredUUID:
+		record.EndDate = time.Now().AddDate(0, 0, -1)
+	default:
+		return nil, errors.New("not found")
+	}
+	return record, nil
+}
+
+func newTestResolver() *secretref.Resolver {
+	return secretref.NewResolver(nil, fakeVaults{})
+}
+
+func TestRenderFile(t *testing.T) {
+
+	dir := t.TempDir()
+	templateFile := filepath.Join(dir, "app.conf.tmpl")
+	outputFile := filepath.Join(dir, "app.conf")
+	template := `url=jdbc:postgresql://{{ .Host }}/app
+user={{ keyhubUsername "` + validUUID.String() + `" }}
+password={{ keyhubPassword "keyhub://` + validUUID.String() + `" }}
+`
+	if err := os.WriteFile(templateFile, []byte(template), 0644); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	err := RenderFile(context.Background(), newTestResolver(), templateFile, outputFile, map[string]string{"Host": "db"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	rendered, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if string(rendered) != "url=jdbc:postgresql://db/app\nuser=app\npassword=s3cret\n" {
+		t.Errorf("Result differs, got `%s`", rendered)
+	}
+	if info, _ := os.Stat(outputFile); info.Mode().Perm() != FileMode && os.PathSeparator == '/' {
+		t.Errorf("Result differs, want mode %v, got %v", FileMode, info.Mode().Perm())
+	}
+}
+
+func TestRenderFailsClosed(t *testing.T) {
+
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "app.conf")
+	if err := os.WriteFile(outputFile, []byte("previous"), FileMode); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	templates := map[string]string{
+		"expired": `{{ keyhubPassword "` + expiredUUID.String() + `" }}`,
+		"missing": `{{ keyhubPassword "` + uuid.NewString() + `" }}`,
+		"field":   `{{ keyhubPassword "keyhub://` + validUUID.String() + `#username" }}`,
+		"no file": `{{ keyhubFile "` + validUUID.String() + `" }}`,
+		"no data": `{{ keyhubPassword "` + validUUID.String() + `" }} {{ .Missing }}`,
+	}
+	for name, text := range templates {
+		templateFile := filepath.Join(dir, "app.conf.tmpl")
+		if err := os.WriteFile(templateFile, []byte(text), 0644); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		err := RenderFile(context.Background(), newTestResolver(), templateFile, outputFile, map[string]string{})
+		if err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+		if name == "expired" && !errors.Is(err, ErrRecordExpired) {
+			t.Errorf("Expected ErrRecordExpired, got %v", err)
+		}
+	}
+
+	if rendered, _ := os.ReadFile(outputFile); string(rendered) != "previous" {
+		t.Errorf("Expected the output file to be left untouched, got `%s`", rendered)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".app.conf") {
+			t.Errorf("Expected no temporary files, got %s", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,180 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package keyhub
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy Configures which failed requests are retried and how long to wait between attempts
+type RetryPolicy struct {
+	// MaxAttempts Maximum number of attempts including the first one, requests are not retried when <= 1
+	MaxAttempts int
+	// MinBackoff Upper bound of the random wait before the first retry, doubled for every next retry
+	MinBackoff time.Duration
+	// MaxBackoff Upper bound of every wait, including waits requested by a Retry-After header
+	MaxBackoff time.Duration
+	// StatusCodes Response status codes that are retried, defaults to 429, 502, 503 and 504
+	StatusCodes []int
+	// RetryNonIdempotent Also retry POST and PATCH requests, which may then be executed more than once
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy Policy used by New when WithRetryPolicy is not given
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		MinBackoff:  250 * time.Millisecond,
+		MaxBackoff:  10 * time.Second,
+		StatusCodes: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	}
+}
+
+// RetryTransport Retries requests that failed with a network error or a retryable status code according to Policy
+type RetryTransport struct {
+	Base   http.RoundTripper
+	Policy RetryPolicy
+	// Logger Receives a debug message for every retry, nothing is logged when nil
+	Logger *slog.Logger
+}
+
+// RoundTrip Perform req, retrying it while the policy allows
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if !t.retryable(req) {
+		return base.RoundTrip(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = cloneRequest(req)
+			attemptReq.Body = body
+		}
+
+		resp, err := base.RoundTrip(attemptReq)
+		if attempt >= t.Policy.MaxAttempts || !t.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt, resp)
+		if t.Logger != nil {
+			t.Logger.Debug("retrying request", "method", req.Method, "url", req.URL.Redacted(), "attempt", attempt, "wait", wait, "status", statusOf(resp), "error", err)
+		}
+		if resp != nil {
+			// Drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable Only idempotent requests with a body that can be replayed are retried, unless the policy says otherwise
+func (t *RetryTransport) retryable(req *http.Request) bool {
+	if t.Policy.MaxAttempts <= 1 {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return t.Policy.RetryNonIdempotent
+}
+
+func (t *RetryTransport) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		// Cancelled requests are never retried, other transport errors are
+		return req.Context().Err() == nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	statusCodes := t.Policy.StatusCodes
+	if statusCodes == nil {
+		statusCodes = DefaultRetryPolicy().StatusCodes
+	}
+	return slices.Contains(statusCodes, resp.StatusCode)
+}
+
+// backoff Wait requested by the Retry-After header of resp, or exponential backoff with full jitter
+func (t *RetryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	maxBackoff := t.Policy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultRetryPolicy().MaxBackoff
+	}
+
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return min(wait, maxBackoff)
+		}
+	}
+
+	ceiling := t.Policy.MinBackoff
+	for i := 1; i < attempt && ceiling < maxBackoff; i++ {
+		ceiling *= 2
+	}
+	ceiling = min(ceiling, maxBackoff)
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// parseRetryAfter Parse a Retry-After header holding either a number of seconds or a http date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+func statusOf(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
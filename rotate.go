@@ -0,0 +1,204 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package keyhub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+const (
+	// previousSecretPrefix Start of the comment line holding the previous secret of a rotated record
+	previousSecretPrefix = "Previous password (rotated "
+	// previousSecretSuffix Appended to the name of the sibling record holding the previous secret
+	previousSecretSuffix = " (previous)"
+)
+
+// SecretGenerator Generates the new secret of a rotation, e.g. a password policy of the generate package
+type SecretGenerator interface {
+	Generate() (string, error)
+}
+
+// SecretGeneratorFunc Use an ordinary function as SecretGenerator
+type SecretGeneratorFunc func() (string, error)
+
+func (f SecretGeneratorFunc) Generate() (string, error) {
+	return f()
+}
+
+// RotateApplyFunc Applies newSecret to the system the record gives access to, record still holds the current secret
+type RotateApplyFunc func(ctx context.Context, record *model.VaultRecord, newSecret string) error
+
+// RotateOption Configures a rotation by VaultService.Rotate
+type RotateOption func(*rotateOptions)
+
+type rotateOptions struct {
+	validity          time.Duration
+	previousInSibling bool
+}
+
+// WithRotateValidity Set the EndDate of the rotated record d from now, instead of keeping the lifetime of the previous secret
+func WithRotateValidity(d time.Duration) RotateOption {
+	return func(o *rotateOptions) {
+		o.validity = d
+	}
+}
+
+// WithPreviousInSibling Keep the previous secret in a sibling record named "<name> (previous)" instead of in the comment
+func WithPreviousInSibling() RotateOption {
+	return func(o *rotateOptions) {
+		o.previousInSibling = true
+	}
+}
+
+// RotationError The new secret was applied to the target system, but could not be stored in KeyHub.
+// NewSecret is the secret now in use, it is not part of the error message.
+type RotationError struct {
+	UUID      string
+	NewSecret string
+	Err       error
+}
+
+func (e *RotationError) Error() string {
+	return fmt.Sprintf("new secret of VaultRecord %q was applied but could not be stored: %s", e.UUID, e.Err)
+}
+
+func (e *RotationError) Unwrap() error {
+	return e.Err
+}
+
+// Rotate Replace the password of a vault record by a secret from generator. apply must put the new secret in use on the
+// target system, the record is only updated when it succeeds. The previous password is kept in the comment of the
+// record, or in a sibling record with WithPreviousInSibling, so a rotation can be rolled back.
+// The EndDate keeps the lifetime of the previous secret unless WithRotateValidity is given, and always lies beyond the
+// WarningPeriod of the record. Once apply succeeded the record is stored even when ctx is cancelled.
+func (s *VaultService) Rotate(ctx context.Context, group *model.Group, uuid uuid.UUID, generator SecretGenerator, apply RotateApplyFunc, opts ...RotateOption) (result *model.VaultRecord, err error) {
+	options := &rotateOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	record, err := s.GetByUUIDContext(ctx, group, uuid, &model.VaultRecordAdditionalQueryParams{Audit: true, Secret: true})
+	if err != nil {
+		return nil, err
+	}
+	if record.AdditionalObjects == nil || record.AdditionalObjects.Secret == nil {
+		return nil, fmt.Errorf("VaultRecord %q was returned without its secrets", record.UUID)
+	}
+
+	newSecret, err := generator.Generate()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate secret for VaultRecord %q: %w", record.UUID, err)
+	}
+
+	now := time.Now()
+	previous := record.Password()
+	if previous != nil && *previous != "" && options.previousInSibling {
+		// The sibling holds the secret that is still in use, so it is safe to store it before applying
+		if err = s.storePreviousSecret(ctx, group, record, *previous); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = apply(ctx, record, newSecret); err != nil {
+		return nil, fmt.Errorf("could not apply new secret of VaultRecord %q: %w", record.UUID, err)
+	}
+
+	if previous != nil && *previous != "" && !options.previousInSibling {
+		comment := withPreviousSecret(record.Comment(), *previous, now)
+		record.AdditionalObjects.Secret.Comment = &comment
+	}
+	record.EndDate = rotatedEndDate(record, options.validity, now)
+	record.AdditionalObjects.Secret.Password = &newSecret
+
+	result, err = s.UpdateContext(context.WithoutCancel(ctx), group, record)
+	if err != nil {
+		return nil, &RotationError{UUID: record.UUID, NewSecret: newSecret, Err: err}
+	}
+
+	return result, nil
+}
+
+// storePreviousSecret Create or update the sibling record holding the previous secret of record
+func (s *VaultService) storePreviousSecret(ctx context.Context, group *model.Group, record *model.VaultRecord, previous string) error {
+	name := record.Name + previousSecretSuffix
+
+	siblings, err := s.ListContext(ctx, group, &model.VaultRecordQueryParams{Name: name}, nil)
+	if err != nil {
+		return err
+	}
+
+	if len(siblings) == 0 {
+		sibling := model.NewVaultRecord(name, &model.VaultRecordSecretAdditionalObject{Password: &previous})
+		sibling.URL = record.URL
+		sibling.Username = record.Username
+		_, err = s.CreateContext(ctx, group, sibling)
+		return err
+	}
+
+	siblingUUID, err := uuid.Parse(siblings[0].UUID)
+	if err != nil {
+		return err
+	}
+	sibling, err := s.GetByUUIDContext(ctx, group, siblingUUID, &model.VaultRecordAdditionalQueryParams{Secret: true})
+	if err != nil {
+		return err
+	}
+	if sibling.AdditionalObjects == nil || sibling.AdditionalObjects.Secret == nil {
+		return fmt.Errorf("VaultRecord %q was returned without its secrets", sibling.UUID)
+	}
+	sibling.AdditionalObjects.Secret.Password = &previous
+	_, err = s.UpdateContext(ctx, group, sibling)
+	return err
+}
+
+// withPreviousSecret Return comment with its line holding a previous secret replaced by one holding previous
+func withPreviousSecret(comment *string, previous string, rotatedAt time.Time) string {
+	var lines []string
+	if comment != nil && *comment != "" {
+		for _, line := range strings.Split(*comment, "\n") {
+			if !strings.HasPrefix(line, previousSecretPrefix) {
+				lines = append(lines, line)
+			}
+		}
+	}
+	lines = append(lines, fmt.Sprintf("%s%s): %s", previousSecretPrefix, rotatedAt.UTC().Format(time.RFC3339), previous))
+	return strings.Join(lines, "\n")
+}
+
+// rotatedEndDate Return the EndDate of a record rotated at now, no EndDate stays none
+func rotatedEndDate(record *model.VaultRecord, validity time.Duration, now time.Time) time.Time {
+	var endDate time.Time
+	switch {
+	case validity > 0:
+		endDate = now.Add(validity)
+	case record.EndDate.IsZero():
+		return time.Time{}
+	case record.AdditionalObjects.Audit != nil && record.EndDate.After(record.LastModifiedAt()):
+		endDate = now.Add(record.EndDate.Sub(record.LastModifiedAt()))
+	}
+	endDate = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 0, 0, 0, 0, time.UTC)
+
+	if warningStart, ok := record.WarningPeriod.WarningStart(endDate); !ok && endDate.After(now) || ok && warningStart.After(now) {
+		return endDate
+	}
+	return record.WarningPeriod.MinimalEndDate(now)
+}
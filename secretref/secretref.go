@@ -0,0 +1,280 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+// Package secretref Resolves references to fields of KeyHub vault records, so configuration can hold references
+// instead of secrets. A reference has the form
+//
+//	keyhub://<group uuid>/<record uuid>#<field>
+//
+// where field is one of password (the default), username, url, totp or file. Without a group,
+// keyhub://<record uuid>#<field> refers to a record accessible by the client.
+package secretref
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+const (
+	Scheme = "keyhub://"
+
+	FieldPassword = "password"
+	FieldUsername = "username"
+	FieldURL      = "url"
+	FieldTOTP     = "totp"
+	FieldFile     = "file"
+)
+
+// refRegex Matches references within text, uuids are matched loosely and validated by Parse
+var refRegex = regexp.MustCompile(`keyhub://[0-9A-Fa-f-]{36}(?:/[0-9A-Fa-f-]{36})?(?:#[a-z]+)?`)
+
+// Ref A parsed reference to a field of a vault record
+type Ref struct {
+	// Group The group holding the record, uuid.Nil for a record accessible by the client
+	Group  uuid.UUID
+	Record uuid.UUID
+	Field  string
+}
+
+// Parse Parse a keyhub:// reference
+func Parse(value string) (Ref, error) {
+	rest, found := strings.CutPrefix(value, Scheme)
+	if !found {
+		return Ref{}, fmt.Errorf("reference %q does not start with %s", value, Scheme)
+	}
+
+	path, field, found := strings.Cut(rest, "#")
+	if !found {
+		field = FieldPassword
+	}
+	switch field {
+	case FieldPassword, FieldUsername, FieldURL, FieldTOTP, FieldFile:
+	default:
+		return Ref{}, fmt.Errorf("unknown field %q in reference %q", field, value)
+	}
+
+	ref := Ref{Field: field}
+	var err error
+	groupPart, recordPart, hasGroup := strings.Cut(path, "/")
+	if !hasGroup {
+		recordPart = groupPart
+	} else if ref.Group, err = uuid.Parse(groupPart); err != nil {
+		return Ref{}, fmt.Errorf("invalid group uuid in reference %q: %w", value, err)
+	}
+	if ref.Record, err = uuid.Parse(recordPart); err != nil {
+		return Ref{}, fmt.Errorf("invalid record uuid in reference %q: %w", value, err)
+	}
+
+	return ref, nil
+}
+
+// IsRef Return true when value looks like a reference
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, Scheme)
+}
+
+func (r Ref) String() string {
+	if r.Group == uuid.Nil {
+		return Scheme + r.Record.String() + "#" + r.Field
+	}
+	return Scheme + r.Group.String() + "/" + r.Record.String() + "#" + r.Field
+}
+
+// Value Return field of record, which must be retrieved with its secrets for password, totp and file
+func Value(record *model.VaultRecord, field string) (string, error) {
+	var value *string
+	switch field {
+	case FieldUsername:
+		value = &record.Username
+	case FieldURL:
+		value = &record.URL
+	case FieldTOTP:
+		code, err := record.TOTPCode(time.Now())
+		if err != nil {
+			return "", err
+		}
+		value = &code
+	case FieldFile:
+		if record.AdditionalObjects != nil && record.AdditionalObjects.Secret != nil && record.File() != nil {
+			file := string(*record.File())
+			value = &file
+		}
+	case FieldPassword:
+		if record.AdditionalObjects != nil && record.AdditionalObjects.Secret != nil {
+			value = record.Password()
+		}
+	default:
+		return "", fmt.Errorf("unknown field %q", field)
+	}
+
+	if value == nil {
+		return "", fmt.Errorf("VaultRecord %q has no %s", record.UUID, field)
+	}
+	return *value, nil
+}
+
+// GroupFinder Retrieves groups, implemented by keyhub.GroupService
+type GroupFinder interface {
+	GetByUUIDContext(ctx context.Context, uuid uuid.UUID) (*model.Group, error)
+}
+
+// RecordFinder Retrieves vault records, implemented by keyhub.VaultService
+type RecordFinder interface {
+	GetByUUIDContext(ctx context.Context, group *model.Group, uuid uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error)
+	FindByUUIDForClientContext(ctx context.Context, uuid uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error)
+}
+
+// Resolver Resolves references, every group and record is retrieved once. Create a new Resolver for every run,
+// or call Reset, to pick up changed records.
+type Resolver struct {
+	groups GroupFinder
+	vaults RecordFinder
+
+	mu          sync.Mutex
+	groupCache  map[uuid.UUID]*model.Group
+	recordCache map[[2]uuid.UUID]*model.VaultRecord
+}
+
+// NewResolver Create a Resolver, e.g. secretref.NewResolver(client.Groups, client.Vaults)
+func NewResolver(groups GroupFinder, vaults RecordFinder) *Resolver {
+	r := &Resolver{groups: groups, vaults: vaults}
+	r.Reset()
+	return r
+}
+
+// Reset Forget all retrieved groups and records
+func (r *Resolver) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.groupCache = make(map[uuid.UUID]*model.Group)
+	r.recordCache = make(map[[2]uuid.UUID]*model.VaultRecord)
+}
+
+// Record Retrieve the record ref points to, including its secrets
+func (r *Resolver) Record(ctx context.Context, ref Ref) (*model.VaultRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]uuid.UUID{ref.Group, ref.Record}
+	if record, ok := r.recordCache[key]; ok {
+		return record, nil
+	}
+
+	additional := &model.VaultRecordAdditionalQueryParams{Secret: true}
+	var record *model.VaultRecord
+	var err error
+	if ref.Group == uuid.Nil {
+		record, err = r.vaults.FindByUUIDForClientContext(ctx, ref.Record, additional)
+	} else {
+		group, ok := r.groupCache[ref.Group]
+		if !ok {
+			if group, err = r.groups.GetByUUIDContext(ctx, ref.Group); err != nil {
+				return nil, err
+			}
+			r.groupCache[ref.Group] = group
+		}
+		record, err = r.vaults.GetByUUIDContext(ctx, group, ref.Record, additional)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.recordCache[key] = record
+	return record, nil
+}
+
+// Resolve Return the value ref points to
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	parsed, err := Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return r.ResolveRef(ctx, parsed)
+}
+
+// ResolveRef Return the value ref points to
+func (r *Resolver) ResolveRef(ctx context.Context, ref Ref) (string, error) {
+	record, err := r.Record(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %s: %w", ref, err)
+	}
+	value, err := Value(record, ref.Field)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve %s: %w", ref, err)
+	}
+	return value, nil
+}
+
+// Expand Replace every reference in text by its value, e.g. in an env file. Values are inserted as is,
+// use ResolveTree for formats like YAML and JSON that need quoting.
+func (r *Resolver) Expand(ctx context.Context, text string) (string, error) {
+	var err error
+	expanded := refRegex.ReplaceAllStringFunc(text, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		var value string
+		value, err = r.Resolve(ctx, ref)
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
+
+// ResolveTree Replace every string in v that is a reference by its value. v is a document decoded by encoding/json or
+// gopkg.in/yaml.v3 into any, so built from maps, slices and scalars. Maps and slices are updated in place.
+func (r *Resolver) ResolveTree(ctx context.Context, v any) (any, error) {
+	switch node := v.(type) {
+	case string:
+		if !IsRef(node) {
+			return node, nil
+		}
+		return r.Resolve(ctx, node)
+	case map[string]any:
+		for key, child := range node {
+			resolved, err := r.ResolveTree(ctx, child)
+			if err != nil {
+				return nil, err
+			}
+			node[key] = resolved
+		}
+	case map[any]any:
+		for key, child := range node {
+			resolved, err := r.ResolveTree(ctx, child)
+			if err != nil {
+				return nil, err
+			}
+			node[key] = resolved
+		}
+	case []any:
+		for i, child := range node {
+			resolved, err := r.ResolveTree(ctx, child)
+			if err != nil {
+				return nil, err
+			}
+			node[i] = resolved
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,172 @@
+/* Licensed to the Apache Software Foundation (ASF) under one or more
+   contributor license agreements.  See the NOTICE file distributed with
+   this work for additional information regarding copyright ownership.
+   The ASF licenses this file to You under the Apache License, Version 2.0
+   (the "License"); you may not use this file except in compliance with
+   the License.  You may obtain a copy of the License at
+
+      http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License. */
+
+package secretref
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/topicuskeyhub/go-keyhub"
+	"github.com/topicuskeyhub/go-keyhub/model"
+)
+
+var (
+	_ GroupFinder  = (*keyhub.GroupService)(nil)
+	_ RecordFinder = (*keyhub.VaultService)(nil)
+)
+
+var (
+	groupUUID  = uuid.MustParse("00000000-0000-0000-0000-0000000000d1")
+	recordUUID = uuid.MustParse("00000000-0000-0000-0000-0000000000d2")
+)
+
+// fakeKeyHub Serves a single record in a single group and counts the lookups
+type fakeKeyHub struct {
+	groupLookups, recordLookups, clientLookups int
+}
+
+func (f *fakeKeyHub) GetByUUIDContext(ctx context.Context, id uuid.UUID) (*model.Group, error) {
+	f.groupLookups++
+	if id != groupUUID {
+		return nil, errors.New("not found")
+	}
+	group := model.NewEmptyGroup("group")
+	group.UUID = id.String()
+	return group, nil
+}
+
+type fakeVaults struct {
+	*fakeKeyHub
+}
+
+func (f fakeVaults) record(id uuid.UUID) (*model.VaultRecord, error) {
+	if id != recordUUID {
+		return nil, errors.New("not found")
+	}
+	password := "s3cr\"et"
+	record := model.NewVaultRecord("db", &model.VaultRecordSecretAdditionalObject{Password: &password})
+	record.UUID = id.String()
+	record.Username = "app"
+	record.URL = "https://db.example.com"
+	return record, nil
+}
+
+func (f fakeVaults) GetByUUIDContext(ctx context.Context, group *model.Group, id uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error) {
+	f.recordLookups++
+	return f.record(id)
+}
+
+func (f fakeVaults) FindByUUIDForClientContext(ctx context.Context, id uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (*model.VaultRecord, error) {
+	f.clientLookups++
+	return f.record(id)
+}
+
+func newTestResolver() (*Resolver, *fakeKeyHub) {
+	fake := &fakeKeyHub{}
+	return NewResolver(fake, fakeVaults{fake}), fake
+}
+
+func TestParse(t *testing.T) {
+
+	ref, err := Parse("keyhub://" + groupUUID.String() + "/" + recordUUID.String() + "#username")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if ref.Group != groupUUID || ref.Record != recordUUID || ref.Field != FieldUsername {
+		t.Errorf("Result differs, got `%+v`", ref)
+	}
+
+	ref, err = Parse("keyhub://" + recordUUID.String())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if ref.Group != uuid.Nil || ref.Field != FieldPassword || ref.String() != "keyhub://"+recordUUID.String()+"#password" {
+		t.Errorf("Result differs, got `%+v`", ref)
+	}
+
+	for _, value := range []string{
+		recordUUID.String(),
+		"keyhub://" + recordUUID.String() + "#secret",
+		"keyhub://not-a-uuid/" + recordUUID.String(),
+		"keyhub://" + groupUUID.String() + "/",
+	} {
+		if _, err := Parse(value); err == nil {
+			t.Errorf("Expected an error for %s", value)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+
+	resolver, fake := newTestResolver()
+	ctx := context.Background()
+	groupRef := "keyhub://" + groupUUID.String() + "/" + recordUUID.String()
+
+	for field, want := range map[string]string{"": "s3cr\"et", "#username": "app", "#url": "https://db.example.com"} {
+		got, err := resolver.Resolve(ctx, groupRef+field)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if got != want {
+			t.Errorf("Result differs, want `%v`, got `%v`", want, got)
+		}
+	}
+	if fake.groupLookups != 1 || fake.recordLookups != 1 {
+		t.Errorf("Expected a single lookup of the group and record, got %d and %d", fake.groupLookups, fake.recordLookups)
+	}
+
+	if _, err := resolver.Resolve(ctx, "keyhub://"+recordUUID.String()+"#file"); err == nil {
+		t.Errorf("Expected an error for a record without file")
+	}
+	if fake.clientLookups != 1 {
+		t.Errorf("Expected a lookup for the client, got %d", fake.clientLookups)
+	}
+}
+
+func TestExpandAndResolveTree(t *testing.T) {
+
+	resolver, _ := newTestResolver()
+	ctx := context.Background()
+	ref := "keyhub://" + recordUUID.String()
+
+	expanded, err := resolver.Expand(ctx, "DB_USER="+ref+"#username\nDB_PASSWORD="+ref+"\n")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if expanded != "DB_USER=app\nDB_PASSWORD=s3cr\"et\n" {
+		t.Errorf("Result differs, got `%v`", expanded)
+	}
+
+	var document any
+	if err := json.Unmarshal([]byte(`{"db":{"user":"`+ref+`#username","hosts":["`+ref+`#url"],"port":5432}}`), &document); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	document, err = resolver.ResolveTree(ctx, document)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	out, _ := json.Marshal(document)
+	if string(out) != `{"db":{"hosts":["https://db.example.com"],"port":5432,"user":"app"}}` {
+		t.Errorf("Result differs, got `%s`", out)
+	}
+
+	if _, err := resolver.Expand(ctx, "X=keyhub://"+groupUUID.String()); err == nil {
+		t.Errorf("Expected an error for an unknown record")
+	}
+}
@@ -16,11 +16,12 @@
 package keyhub
 
 import (
+	"context"
 	"fmt"
 	"github.com/dghubble/sling"
 	"github.com/google/uuid"
 	"github.com/topicuskeyhub/go-keyhub/model"
-	"net/http"
+	"iter"
 	"net/url"
 	"strconv"
 )
@@ -38,12 +39,17 @@ func NewServiceAccountService(sling *sling.Sling) *ServiceAccountService {
 
 // GetByUUID Get Service account by UUID
 func (s *ServiceAccountService) GetByUUID(uuid uuid.UUID) (result *model.ServiceAccount, err error) {
+	return s.GetByUUIDContext(context.Background(), uuid)
+}
+
+// GetByUUIDContext Get Service account by UUID
+func (s *ServiceAccountService) GetByUUIDContext(ctx context.Context, uuid uuid.UUID) (result *model.ServiceAccount, err error) {
 	list := new(model.ServiceAccountList)
 	errorReport := new(model.ErrorReport)
 
 	params := &model.ServiceAccountQueryParams{UUID: uuid.String()}
 
-	_, err = s.sling.New().Get("").QueryStruct(params).Receive(list, errorReport)
+	_, err = receive(ctx, s.sling.New().Get("").QueryStruct(params), list, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get ServiceAccount %q.", uuid)
 	}
@@ -51,7 +57,7 @@ func (s *ServiceAccountService) GetByUUID(uuid uuid.UUID) (result *model.Service
 		if len(list.Items) > 0 {
 			result = &list.Items[0]
 		} else {
-			err = fmt.Errorf("Account %q not found", uuid.String())
+			err = fmt.Errorf("Account %q %w", uuid.String(), ErrNotFound)
 		}
 	}
 
@@ -60,17 +66,22 @@ func (s *ServiceAccountService) GetByUUID(uuid uuid.UUID) (result *model.Service
 
 // GetById Get Service account by ID
 func (s *ServiceAccountService) GetById(id int64) (result *model.ServiceAccount, err error) {
+	return s.GetByIdContext(context.Background(), id)
+}
+
+// GetByIdContext Get Service account by ID
+func (s *ServiceAccountService) GetByIdContext(ctx context.Context, id int64) (result *model.ServiceAccount, err error) {
 	sa := new(model.ServiceAccount)
 	errorReport := new(model.ErrorReport)
 	idString := strconv.FormatInt(id, 10)
 
-	_, err = s.sling.New().Get(idString).Receive(sa, errorReport)
+	_, err = receive(ctx, s.sling.New().Get(idString), sa, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get ServiceAccount %q.", idString)
 		return
 	}
 	if err == nil && sa == nil {
-		err = fmt.Errorf("Account %q not found", idString)
+		err = fmt.Errorf("Account %q %w", idString, ErrNotFound)
 		return
 	}
 
@@ -83,9 +94,12 @@ func (s *ServiceAccountService) GetById(id int64) (result *model.ServiceAccount,
 //func (s *VaultService) List(group *model.Group, query *model.VaultRecordQueryParams, additional *model.VaultRecordAdditionalQueryParams) (records []model.VaultRecord, err error) {
 
 // List all Service Accounts
-func (s *ServiceAccountService) List(query *model.ServiceAccountQueryParams, additional *model.ServiceAccountAdditionalQueryParams) (list []model.ServiceAccount, err error) {
-	list = []model.ServiceAccount{}
+func (s *ServiceAccountService) List(query *model.ServiceAccountQueryParams, additional *model.ServiceAccountAdditionalQueryParams, opts ...ListOption) (list []model.ServiceAccount, err error) {
+	return s.ListContext(context.Background(), query, additional, opts...)
+}
 
+// ListContext List all Service Accounts, no further pages are fetched once ctx is done
+func (s *ServiceAccountService) ListContext(ctx context.Context, query *model.ServiceAccountQueryParams, additional *model.ServiceAccountAdditionalQueryParams, opts ...ListOption) (list []model.ServiceAccount, err error) {
 	if query == nil {
 		query = new(model.ServiceAccountQueryParams)
 	}
@@ -93,42 +107,29 @@ func (s *ServiceAccountService) List(query *model.ServiceAccountQueryParams, add
 		query.Additional = additional
 	}
 
-	searchRange := model.NewRange()
-
-	var response *http.Response
-
-	for ok := true; ok; ok = searchRange.NextPage() {
-
-		errorReport := new(model.ErrorReport)
-		results := new(model.ServiceAccountList)
-		response, err = s.sling.New().Get("").QueryStruct(query).Add(searchRange.GetRequestRangeHeader()).Add(searchRange.GetRequestModeHeader()).Receive(results, errorReport)
-		searchRange.ParseResponse(response)
-
-		if errorReport.Code > 0 {
-			err = errorReport.Wrap("Could not get Groups.")
-		}
-		if err == nil {
-			if len(results.Items) > 0 {
-				list = append(list, results.Items...)
-			}
-		}
-
-	}
+	return collect(s.All(ctx, query, opts...))
+}
 
-	return
+// All Iterate all Service Accounts matching query, pages are fetched while ranging over the accounts
+func (s *ServiceAccountService) All(ctx context.Context, query *model.ServiceAccountQueryParams, opts ...ListOption) iter.Seq2[model.ServiceAccount, error] {
+	return paginate[model.ServiceAccount](ctx, s.sling.New().Get("").QueryStruct(query), opts, "Could not get ServiceAccounts.")
 }
 
 // Create  Create a serviceaccount
 func (s *ServiceAccountService) Create(serviceaccount *model.ServiceAccount) (result *model.ServiceAccount, err error) {
+	return s.CreateContext(context.Background(), serviceaccount)
+}
+
+// CreateContext Create a serviceaccount
+func (s *ServiceAccountService) CreateContext(ctx context.Context, serviceaccount *model.ServiceAccount) (result *model.ServiceAccount, err error) {
 	serviceAccounts := new(model.ServiceAccountList)
 	results := new(model.ServiceAccountList)
 	errorReport := new(model.ErrorReport)
 	serviceAccounts.Items = append(serviceAccounts.Items, *serviceaccount)
 
-	_, err = s.sling.New().Post("").BodyJSON(serviceAccounts).Receive(results, errorReport)
+	_, err = receive(ctx, s.sling.New().Post("").BodyJSON(serviceAccounts), results, errorReport)
 
 	if errorReport.Code > 0 {
-		fmt.Println("Wrap", errorReport.Message)
 		//apiErr := model.NewKeyhubApiError(*errorReport, "Could not create ServiceAccount in System %q.", serviceaccount.System.Name)
 		err = errorReport.Wrap("Could not create ServiceAccount in System %q.", serviceaccount.System.Name)
 
@@ -147,12 +148,17 @@ func (s *ServiceAccountService) Create(serviceaccount *model.ServiceAccount) (re
 
 // Update  Update service account
 func (s *ServiceAccountService) Update(serviceAccount *model.ServiceAccount) (result *model.ServiceAccount, err error) {
+	return s.UpdateContext(context.Background(), serviceAccount)
+}
+
+// UpdateContext Update service account
+func (s *ServiceAccountService) UpdateContext(ctx context.Context, serviceAccount *model.ServiceAccount) (result *model.ServiceAccount, err error) {
 	updated := new(model.ServiceAccount)
 	errorReport := new(model.ErrorReport)
 
 	selfUrl, _ := url.Parse(serviceAccount.Self().Href)
 
-	_, err = s.sling.New().Path(selfUrl.Path).Put("").BodyJSON(serviceAccount).Receive(updated, errorReport)
+	_, err = receive(ctx, s.sling.New().Path(selfUrl.Path).Put("").BodyJSON(serviceAccount), updated, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not update ServiceAccount %s", serviceAccount.UUID)
 		return
@@ -163,11 +169,16 @@ func (s *ServiceAccountService) Update(serviceAccount *model.ServiceAccount) (re
 
 // Delete Delete a service account by object
 func (s *ServiceAccountService) Delete(serviceAccount *model.ServiceAccount) (err error) {
+	return s.DeleteContext(context.Background(), serviceAccount)
+}
+
+// DeleteContext Delete a service account by object
+func (s *ServiceAccountService) DeleteContext(ctx context.Context, serviceAccount *model.ServiceAccount) (err error) {
 	errorReport := new(model.ErrorReport)
 
 	selfUrl, _ := url.Parse(serviceAccount.Self().Href)
 
-	_, err = s.sling.New().Path(selfUrl.Path).Delete("").Receive(nil, errorReport)
+	_, err = receive(ctx, s.sling.New().Path(selfUrl.Path).Delete(""), nil, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not delete ServiceAccount %q", serviceAccount.UUID)
 	}
@@ -177,19 +188,29 @@ func (s *ServiceAccountService) Delete(serviceAccount *model.ServiceAccount) (er
 
 // DeleteByUUID  Delete a service account by uuid for a certain group
 func (s *ServiceAccountService) DeleteByUUID(uuid uuid.UUID) (err error) {
-	serviceAccount, err := s.GetByUUID(uuid)
+	return s.DeleteByUUIDContext(context.Background(), uuid)
+}
+
+// DeleteByUUIDContext Delete a service account by uuid
+func (s *ServiceAccountService) DeleteByUUIDContext(ctx context.Context, uuid uuid.UUID) (err error) {
+	serviceAccount, err := s.GetByUUIDContext(ctx, uuid)
 	if err != nil {
 		return err
 	}
 
-	return s.Delete(serviceAccount)
+	return s.DeleteContext(ctx, serviceAccount)
 }
 
 // DeleteByID  Delete a service account by ID
 func (s *ServiceAccountService) DeleteByID(id int64) (err error) {
-	serviceAccount, err := s.GetById(id)
+	return s.DeleteByIDContext(context.Background(), id)
+}
+
+// DeleteByIDContext Delete a service account by ID
+func (s *ServiceAccountService) DeleteByIDContext(ctx context.Context, id int64) (err error) {
+	serviceAccount, err := s.GetByIdContext(ctx, id)
 	if err != nil {
 		return err
 	}
-	return s.Delete(serviceAccount)
+	return s.DeleteContext(ctx, serviceAccount)
 }
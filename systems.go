@@ -16,6 +16,7 @@
 package keyhub
 
 import (
+	"context"
 	"fmt"
 	"github.com/dghubble/sling"
 	"github.com/google/uuid"
@@ -35,6 +36,10 @@ func newSystemService(sling *sling.Sling) *SystemService {
 }
 
 func (s *SystemService) FindGroupOnSystem(system *model.ProvisionedSystem, query *model.GroupOnSystemQueryParams) (results *model.GroupOnSystemList, err error) {
+	return s.FindGroupOnSystemContext(context.Background(), system, query)
+}
+
+func (s *SystemService) FindGroupOnSystemContext(ctx context.Context, system *model.ProvisionedSystem, query *model.GroupOnSystemQueryParams) (results *model.GroupOnSystemList, err error) {
 
 	results = &model.GroupOnSystemList{}
 
@@ -47,26 +52,10 @@ func (s *SystemService) FindGroupOnSystem(system *model.ProvisionedSystem, query
 		query.Additional = &model.GroupOnSystemAdditionalQueryParams{Audit: false}
 	}
 
-	searchRange := model.NewRange()
-
-	for ok := true; ok; ok = searchRange.NextPage() {
-
-		errorReport := new(model.ErrorReport)
-		pageList := new(model.GroupOnSystemList)
-		response, _ := s.sling.New().Path(selfUrl.Path+"/").Get("group").QueryStruct(query).
-			Add(searchRange.GetRequestRangeHeader()).
-			Add(searchRange.GetRequestModeHeader()).
-			Receive(pageList, errorReport)
-
-		searchRange.ParseResponse(response)
-
-		if errorReport.Code > 0 {
-			err = errorReport.Wrap("could not get GroupsOnSystem for System %s.", system.UUID)
-			return nil, err
-		}
-
-		results.Items = append(results.Items, pageList.Items...)
-
+	results.Items, err = collect(paginate[model.GroupOnSystem](ctx, s.sling.New().Path(selfUrl.Path+"/").Get("group").QueryStruct(query), nil,
+		"could not get GroupsOnSystem for System %s.", system.UUID))
+	if err != nil {
+		return nil, err
 	}
 
 	return
@@ -74,6 +63,10 @@ func (s *SystemService) FindGroupOnSystem(system *model.ProvisionedSystem, query
 }
 
 func (s *SystemService) CreateGroupOnSystem(groupOnSystem *model.GroupOnSystem) (result *model.GroupOnSystem, err error) {
+	return s.CreateGroupOnSystemContext(context.Background(), groupOnSystem)
+}
+
+func (s *SystemService) CreateGroupOnSystemContext(ctx context.Context, groupOnSystem *model.GroupOnSystem) (result *model.GroupOnSystem, err error) {
 
 	list := new(model.GroupOnSystemList)
 	results := new(model.GroupOnSystemList)
@@ -83,7 +76,7 @@ func (s *SystemService) CreateGroupOnSystem(groupOnSystem *model.GroupOnSystem)
 
 	list.Items = append(list.Items, *groupOnSystem)
 
-	_, err = s.sling.New().Post(groupId+"/group").BodyProvider(khJsonBodyProvider{payload: list}).Receive(results, errorReport)
+	_, err = receive(ctx, s.sling.New().Post(groupId+"/group").BodyProvider(khJsonBodyProvider{payload: list}), results, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not create GroupOnSystem.")
 	}
@@ -98,6 +91,10 @@ func (s *SystemService) CreateGroupOnSystem(groupOnSystem *model.GroupOnSystem)
 }
 
 func (s *SystemService) GetGroupOnSystem(system *model.ProvisionedSystem, groupId int64, additional *model.GroupOnSystemAdditionalQueryParams) (result *model.GroupOnSystem, err error) {
+	return s.GetGroupOnSystemContext(context.Background(), system, groupId, additional)
+}
+
+func (s *SystemService) GetGroupOnSystemContext(ctx context.Context, system *model.ProvisionedSystem, groupId int64, additional *model.GroupOnSystemAdditionalQueryParams) (result *model.GroupOnSystem, err error) {
 
 	al := new(model.GroupOnSystem)
 	errorReport := new(model.ErrorReport)
@@ -111,13 +108,13 @@ func (s *SystemService) GetGroupOnSystem(system *model.ProvisionedSystem, groupI
 	params := &model.GroupOnSystemQueryParams{
 		Additional: additional,
 	}
-	_, err = s.sling.New().Get(idString+"/group/"+groupIdString).QueryStruct(params).Receive(al, errorReport)
+	_, err = receive(ctx, s.sling.New().Get(idString+"/group/"+groupIdString).QueryStruct(params), al, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get GroupOnSystem \"%s/%s\".", idString, groupIdString)
 		return
 	}
 	if err == nil && al == nil {
-		err = fmt.Errorf("System %q not found", idString)
+		err = fmt.Errorf("System %q %w", idString, ErrNotFound)
 		return
 	}
 
@@ -128,6 +125,10 @@ func (s *SystemService) GetGroupOnSystem(system *model.ProvisionedSystem, groupI
 }
 
 func (s *SystemService) DeleteGroupOnSystem(groupOnSystem *model.GroupOnSystem) (err error) {
+	return s.DeleteGroupOnSystemContext(context.Background(), groupOnSystem)
+}
+
+func (s *SystemService) DeleteGroupOnSystemContext(ctx context.Context, groupOnSystem *model.GroupOnSystem) (err error) {
 
 	errorReport := new(model.ErrorReport)
 	gosId := strconv.FormatInt(groupOnSystem.Self().ID, 10)
@@ -141,7 +142,7 @@ func (s *SystemService) DeleteGroupOnSystem(groupOnSystem *model.GroupOnSystem)
 
 	var result interface{}
 
-	_, err = s.sling.New().Delete(groupId+"/group/"+gosId).QueryStruct(params).Receive(result, errorReport)
+	_, err = receive(ctx, s.sling.New().Delete(groupId+"/group/"+gosId).QueryStruct(params), result, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("could not delete GroupOnSystem.")
 	}
@@ -159,6 +160,10 @@ func (s *SystemService) Create(system *model.ProvisionedSystem) (result *model.P
 	}
 */
 func (s *SystemService) GetByUUID(uuid uuid.UUID) (system *model.ProvisionedSystem, err error) {
+	return s.GetByUUIDContext(context.Background(), uuid)
+}
+
+func (s *SystemService) GetByUUIDContext(ctx context.Context, uuid uuid.UUID) (system *model.ProvisionedSystem, err error) {
 	results := new(model.ProvisionedSystemList)
 	errorReport := new(model.ErrorReport)
 
@@ -167,7 +172,7 @@ func (s *SystemService) GetByUUID(uuid uuid.UUID) (system *model.ProvisionedSyst
 		Additional: &model.GroupAdditionalQueryParams{Admins: false},
 	}
 
-	_, err = s.sling.New().Get("").QueryStruct(params).Receive(results, errorReport)
+	_, err = receive(ctx, s.sling.New().Get("").QueryStruct(params), results, errorReport)
 
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get System %q.", uuid.String())
@@ -176,7 +181,7 @@ func (s *SystemService) GetByUUID(uuid uuid.UUID) (system *model.ProvisionedSyst
 		if len(results.Items) > 0 {
 			system = &results.Items[0]
 		} else {
-			err = fmt.Errorf("System %q not found", uuid.String())
+			err = fmt.Errorf("System %q %w", uuid.String(), ErrNotFound)
 		}
 	}
 
@@ -185,6 +190,10 @@ func (s *SystemService) GetByUUID(uuid uuid.UUID) (system *model.ProvisionedSyst
 }
 
 func (s *SystemService) GetById(id int64) (system *model.ProvisionedSystem, err error) {
+	return s.GetByIdContext(context.Background(), id)
+}
+
+func (s *SystemService) GetByIdContext(ctx context.Context, id int64) (system *model.ProvisionedSystem, err error) {
 	al := new(model.ProvisionedSystem)
 	errorReport := new(model.ErrorReport)
 	idString := strconv.FormatInt(id, 10)
@@ -192,13 +201,13 @@ func (s *SystemService) GetById(id int64) (system *model.ProvisionedSystem, err
 	params := &model.GroupQueryParams{
 		Additional: &model.GroupAdditionalQueryParams{Admins: false},
 	}
-	_, err = s.sling.New().Get(idString).QueryStruct(params).Receive(al, errorReport)
+	_, err = receive(ctx, s.sling.New().Get(idString).QueryStruct(params), al, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get Group %q.", idString)
 		return
 	}
 	if err == nil && al == nil {
-		err = fmt.Errorf("System %q not found", idString)
+		err = fmt.Errorf("System %q %w", idString, ErrNotFound)
 		return
 	}
 
@@ -16,29 +16,46 @@
 package keyhub
 
 import (
+	"errors"
 	"net/http"
 
 	"golang.org/x/oauth2"
 )
 
+// Transport Adds the vault session of the current token to every request
 type Transport struct {
-	Base http.RoundTripper
+	// Source supplies the token holding the vaultSession extra. When nil the source of Base is used, which then must be an *oauth2.Transport
+	Source oauth2.TokenSource
+	Base   http.RoundTripper
 }
 
-//RoundTrip Based on oauth2.Transport.RoundTrip()
+// RoundTrip Based on oauth2.Transport.RoundTrip()
 func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	token, err := t.Base.(*oauth2.Transport).Source.Token()
+	source := t.Source
+	if source == nil {
+		if base, ok := t.Base.(*oauth2.Transport); ok {
+			source = base.Source
+		}
+	}
+	if source == nil {
+		return nil, errors.New("keyhub: Transport has no token source")
+	}
+
+	token, err := source.Token()
 	if err != nil {
 		return nil, err
 	}
 
 	req2 := cloneRequest(req) // per RoundTripper contract
-	req2.Header.Add("topicus-Vault-session", token.Extra("vaultSession").(string))
+	// Tokens without a vault session are passed on as is, KeyHub rejects the vault request itself
+	if vaultSession, ok := token.Extra("vaultSession").(string); ok && vaultSession != "" {
+		req2.Header.Add("topicus-Vault-session", vaultSession)
+	}
 	res, err := t.Base.RoundTrip(req2)
 	return res, err
 }
 
-//cloneRequest See oauth2.Transport.cloneRequest()
+// cloneRequest See oauth2.Transport.cloneRequest()
 func cloneRequest(r *http.Request) *http.Request {
 	// shallow copy of the struct
 	r2 := new(http.Request)
@@ -16,15 +16,17 @@
 package keyhub
 
 import (
+	"context"
 	"fmt"
 	"github.com/dghubble/sling"
 	"github.com/google/uuid"
 	"github.com/topicuskeyhub/go-keyhub/model"
+	"iter"
 	"math/big"
-	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
+	"time"
 )
 
 type VaultService struct {
@@ -38,6 +40,10 @@ func newVaultService(sling *sling.Sling) *VaultService {
 }
 
 func (s *VaultService) Create(group *model.Group, vaultRecord *model.VaultRecord) (result *model.VaultRecord, err error) {
+	return s.CreateContext(context.Background(), group, vaultRecord)
+}
+
+func (s *VaultService) CreateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (result *model.VaultRecord, err error) {
 	vaultRecords := new(model.VaultRecordList)
 	results := new(model.VaultRecordList)
 	errorReport := new(model.ErrorReport)
@@ -48,7 +54,7 @@ func (s *VaultService) Create(group *model.Group, vaultRecord *model.VaultRecord
 		Additional: &model.VaultRecordAdditionalQueryParams{Secret: true},
 	}
 
-	_, err = s.sling.New().Path(selfUrl.Path+"/vault/").Post("record").QueryStruct(params).BodyProvider(khJsonBodyProvider{payload: vaultRecords}).Receive(results, errorReport)
+	_, err = receive(ctx, s.sling.New().Path(selfUrl.Path+"/vault/").Post("record").QueryStruct(params).BodyProvider(khJsonBodyProvider{payload: vaultRecords}), results, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not create VaultRecord in Group %q.", group.UUID)
 	}
@@ -65,54 +71,89 @@ func (s *VaultService) Create(group *model.Group, vaultRecord *model.VaultRecord
 
 // GetRecords Retrieve all vault records for a group including audit (secrets are not included)
 func (s *VaultService) GetRecords(g *model.Group) (result []model.VaultRecord, err error) {
-	result, err = s.List(g, nil, nil)
-	return
+	return s.GetRecordsContext(context.Background(), g)
 }
 
-// List Retrieve all vault records for a group (secrets are not included, default audit = true)
-func (s *VaultService) List(group *model.Group, query *model.VaultRecordQueryParams, additional *model.VaultRecordAdditionalQueryParams) (records []model.VaultRecord, err error) {
+// GetRecordsContext Retrieve all vault records for a group including audit (secrets are not included)
+func (s *VaultService) GetRecordsContext(ctx context.Context, g *model.Group) (result []model.VaultRecord, err error) {
+	result, err = s.ListContext(ctx, g, nil, nil)
+	return
+}
 
-	selfUrl, _ := url.Parse(group.Self().Href)
+// List Retrieve all vault records for a group (secrets are not included, see ListWithSecrets, default audit = true)
+func (s *VaultService) List(group *model.Group, query *model.VaultRecordQueryParams, additional *model.VaultRecordAdditionalQueryParams, opts ...ListOption) (records []model.VaultRecord, err error) {
+	return s.ListContext(context.Background(), group, query, additional, opts...)
+}
 
+// ListContext Retrieve all vault records for a group, no further pages are fetched once ctx is done
+func (s *VaultService) ListContext(ctx context.Context, group *model.Group, query *model.VaultRecordQueryParams, additional *model.VaultRecordAdditionalQueryParams, opts ...ListOption) (records []model.VaultRecord, err error) {
 	if query == nil {
 		query = &model.VaultRecordQueryParams{}
 	}
 	if additional != nil {
 		query.Additional = additional
-	} else if query.Additional == nil {
-		query.Additional = &model.VaultRecordAdditionalQueryParams{Audit: true}
 	}
 
-	searchRange := model.NewRange()
-	for ok := true; ok; ok = searchRange.NextPage() {
+	return collect(s.All(ctx, group, query, opts...))
+}
+
+// All Iterate the vault records of a group matching query (secrets are not included, default audit = true),
+// pages are fetched while ranging over the records
+func (s *VaultService) All(ctx context.Context, group *model.Group, query *model.VaultRecordQueryParams, opts ...ListOption) iter.Seq2[model.VaultRecord, error] {
+	selfUrl, _ := url.Parse(group.Self().Href)
+
+	if query == nil {
+		query = &model.VaultRecordQueryParams{}
+	}
+	if query.Additional == nil {
+		query.Additional = &model.VaultRecordAdditionalQueryParams{Audit: true}
+	}
 
-		errorReport := new(model.ErrorReport)
-		results := new(model.VaultRecordList)
-		var response *http.Response
-		response, err = s.sling.New().Path(selfUrl.Path+"/vault/").Get("record").QueryStruct(query).Add(searchRange.GetRequestRangeHeader()).Add(searchRange.GetRequestModeHeader()).Receive(results, errorReport)
-		searchRange.ParseResponse(response)
+	return paginate[model.VaultRecord](ctx, s.sling.New().Path(selfUrl.Path+"/vault/").Get("record").QueryStruct(query), opts, "Could not get VaultRecords of Group %q.", group.UUID)
+}
 
-		if errorReport.Code > 0 {
-			err = errorReport.Wrap("Could not get VaultRecords of Group %q.", group.UUID)
-		}
-		if err == nil {
-			if len(results.Items) > 0 {
-				records = append(records, results.Items...)
+// AllWithSecrets Iterate the vault records of a group matching query including audit and secrets. Lists leave out
+// secrets, so every record is retrieved again while ranging.
+func (s *VaultService) AllWithSecrets(ctx context.Context, group *model.Group, query *model.VaultRecordQueryParams, opts ...ListOption) iter.Seq2[model.VaultRecord, error] {
+	return func(yield func(model.VaultRecord, error) bool) {
+		additional := &model.VaultRecordAdditionalQueryParams{Audit: true, Secret: true}
+		for record, err := range s.All(ctx, group, query, opts...) {
+			var full *model.VaultRecord
+			if err == nil {
+				full, err = s.GetBySelfContext(ctx, &record, additional)
+			}
+			if err == nil && (full.AdditionalObjects == nil || full.AdditionalObjects.Secret == nil) {
+				err = fmt.Errorf("VaultRecord %q was returned without its secrets", record.UUID)
+			}
+			if err != nil {
+				yield(model.VaultRecord{}, err)
+				return
+			}
+			if !yield(*full, nil) {
+				return
 			}
 		}
-
 	}
+}
 
-	return
+// ListWithSecrets Retrieve all vault records for a group including audit and secrets, this takes a request per record
+func (s *VaultService) ListWithSecrets(group *model.Group, query *model.VaultRecordQueryParams, opts ...ListOption) (records []model.VaultRecord, err error) {
+	return s.ListWithSecretsContext(context.Background(), group, query, opts...)
 }
 
-func (s *VaultService) getMyClientId() (id int64, err error) {
+// ListWithSecretsContext Retrieve all vault records for a group including audit and secrets, no further records are
+// fetched once ctx is done
+func (s *VaultService) ListWithSecretsContext(ctx context.Context, group *model.Group, query *model.VaultRecordQueryParams, opts ...ListOption) (records []model.VaultRecord, err error) {
+	return collect(s.AllWithSecrets(ctx, group, query, opts...))
+}
+
+func (s *VaultService) getMyClientId(ctx context.Context) (id int64, err error) {
 
 	me := new(model.ClientApplication)
 
 	errorReport := new(model.ErrorReport)
 
-	_, err = s.sling.New().Get("/keyhub/rest/v1/client/me").Receive(&me, errorReport)
+	_, err = receive(ctx, s.sling.New().Get("/keyhub/rest/v1/client/me"), &me, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("could not determine client details")
 		return
@@ -128,28 +169,36 @@ func (s *VaultService) getMyClientId() (id int64, err error) {
 }
 
 func (s *VaultService) FindByIDForClient(id int64, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
+	return s.FindByIDForClientContext(context.Background(), id, additional)
+}
+
+func (s *VaultService) FindByIDForClientContext(ctx context.Context, id int64, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
 	query := model.VaultRecordSearchQueryParams{
 		ID: strconv.FormatInt(id, 10),
 	}
 
-	return s.findForClient(query, additional)
+	return s.findForClient(ctx, query, additional)
 
 }
 
 func (s *VaultService) FindByUUIDForClient(uuid uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
+	return s.FindByUUIDForClientContext(context.Background(), uuid, additional)
+}
+
+func (s *VaultService) FindByUUIDForClientContext(ctx context.Context, uuid uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
 
 	query := model.VaultRecordSearchQueryParams{
 		UUID: uuid.String(),
 	}
 
-	return s.findForClient(query, additional)
+	return s.findForClient(ctx, query, additional)
 }
 
-func (s *VaultService) findForClient(query model.VaultRecordSearchQueryParams, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
+func (s *VaultService) findForClient(ctx context.Context, query model.VaultRecordSearchQueryParams, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
 	results := new(model.VaultRecordList)
 	errorReport := new(model.ErrorReport)
 
-	clientID, err := s.getMyClientId()
+	clientID, err := s.getMyClientId(ctx)
 	if err == nil {
 		query.AccessibleByClient = strconv.FormatInt(clientID, 10)
 	}
@@ -163,7 +212,7 @@ func (s *VaultService) findForClient(query model.VaultRecordSearchQueryParams, a
 	}
 	query.Additional = additionalParams
 
-	_, err = s.sling.New().Get("/keyhub/rest/v1/vaultrecord/").QueryStruct(query).Receive(results, errorReport)
+	_, err = receive(ctx, s.sling.New().Get("/keyhub/rest/v1/vaultrecord/").QueryStruct(query), results, errorReport)
 
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not find VaultRecord.")
@@ -204,21 +253,77 @@ func (s *VaultService) findForClient(query model.VaultRecordSearchQueryParams, a
 					},
 				)
 
-				return s.GetByID(fakegroup, rid.Int64(), additional)
+				return s.GetByIDContext(ctx, fakegroup, rid.Int64(), additional)
 			} else {
 				return result, err
 			}
 
 		} else {
-			err = fmt.Errorf("no VaultRecords found")
+			err = fmt.Errorf("VaultRecord %q %w", query.UUID+query.ID, ErrNotFound)
+		}
+	}
+
+	return
+}
+
+// SearchForClient Iterate the vault records accessible by this client that match query, secrets are not included.
+// AccessibleByClient is filled in when it is empty.
+func (s *VaultService) SearchForClient(ctx context.Context, query model.VaultRecordSearchQueryParams, opts ...ListOption) iter.Seq2[model.VaultRecord, error] {
+	return func(yield func(model.VaultRecord, error) bool) {
+		if query.AccessibleByClient == "" {
+			clientID, err := s.getMyClientId(ctx)
+			if err != nil {
+				yield(model.VaultRecord{}, err)
+				return
+			}
+			query.AccessibleByClient = strconv.FormatInt(clientID, 10)
+		}
+
+		for record, err := range paginate[model.VaultRecord](ctx, s.sling.New().Get("/keyhub/rest/v1/vaultrecord/").QueryStruct(query), opts, "Could not search VaultRecords.") {
+			if !yield(record, err) {
+				return
+			}
 		}
 	}
+}
+
+// GetBySelf Retrieve a vault record again through its self link, e.g. to get the secrets of a search result
+func (s *VaultService) GetBySelf(vaultRecord *model.VaultRecord, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
+	return s.GetBySelfContext(context.Background(), vaultRecord, additional)
+}
+
+// GetBySelfContext Retrieve a vault record again through its self link, e.g. to get the secrets of a search result
+func (s *VaultService) GetBySelfContext(ctx context.Context, vaultRecord *model.VaultRecord, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
+	al := new(model.VaultRecord)
+	errorReport := new(model.ErrorReport)
+
+	if vaultRecord.Self() == nil {
+		return nil, fmt.Errorf("VaultRecord %q has no self link", vaultRecord.UUID)
+	}
+	selfUrl, _ := url.Parse(vaultRecord.Self().Href)
+
+	query := &model.VaultRecordQueryParams{Additional: additional}
+
+	_, err = receive(ctx, s.sling.New().Path(selfUrl.Path).Get("").QueryStruct(query), al, errorReport)
+	if errorReport.Code > 0 {
+		err = errorReport.Wrap("Could not get VaultRecord %q.", vaultRecord.UUID)
+		return
+	}
+	if err != nil {
+		return
+	}
 
+	result = al
 	return
 }
 
 // GetByUUID Retrieve a vault record by uuid for a certain group, including audit and secrets
 func (s *VaultService) GetByUUID(group *model.Group, uuid uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
+	return s.GetByUUIDContext(context.Background(), group, uuid, additional)
+}
+
+// GetByUUIDContext Retrieve a vault record by uuid for a certain group, including audit and secrets
+func (s *VaultService) GetByUUIDContext(ctx context.Context, group *model.Group, uuid uuid.UUID, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
 	results := new(model.VaultRecordList)
 	errorReport := new(model.ErrorReport)
 
@@ -230,7 +335,7 @@ func (s *VaultService) GetByUUID(group *model.Group, uuid uuid.UUID, additional
 	}
 	query.Additional = additional
 
-	_, err = s.sling.New().Path(selfUrl.Path+"/vault/").Get("record").QueryStruct(query).Receive(results, errorReport)
+	_, err = receive(ctx, s.sling.New().Path(selfUrl.Path+"/vault/").Get("record").QueryStruct(query), results, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get VaultRecord %q of Group %q.", uuid.String(), group.UUID)
 	}
@@ -238,7 +343,7 @@ func (s *VaultService) GetByUUID(group *model.Group, uuid uuid.UUID, additional
 		if len(results.Items) > 0 {
 			result = &results.Items[0]
 		} else {
-			err = fmt.Errorf("VaultRecord %q of Group %q not found", uuid.String(), group.UUID)
+			err = fmt.Errorf("VaultRecord %q of Group %q %w", uuid.String(), group.UUID, ErrNotFound)
 		}
 	}
 
@@ -247,6 +352,11 @@ func (s *VaultService) GetByUUID(group *model.Group, uuid uuid.UUID, additional
 
 // GetByID  Retrieve a vault record by ID for a certain group, including audit and secrets
 func (s *VaultService) GetByID(group *model.Group, id int64, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
+	return s.GetByIDContext(context.Background(), group, id, additional)
+}
+
+// GetByIDContext Retrieve a vault record by ID for a certain group, including audit and secrets
+func (s *VaultService) GetByIDContext(ctx context.Context, group *model.Group, id int64, additional *model.VaultRecordAdditionalQueryParams) (result *model.VaultRecord, err error) {
 	al := new(model.VaultRecord)
 	errorReport := new(model.ErrorReport)
 	idString := strconv.FormatInt(id, 10)
@@ -259,13 +369,13 @@ func (s *VaultService) GetByID(group *model.Group, id int64, additional *model.V
 	}
 	query.Additional = additional
 
-	_, err = s.sling.New().Path(selfUrl.Path+"/vault/record/").Get(idString).QueryStruct(query).Receive(al, errorReport)
+	_, err = receive(ctx, s.sling.New().Path(selfUrl.Path+"/vault/record/").Get(idString).QueryStruct(query), al, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not get VaultRecord %q of Group %q.", idString, group.UUID)
 		return
 	}
 	if err == nil && al == nil {
-		err = fmt.Errorf("VaultRecord %q of Group %q not found", idString, group.UUID)
+		err = fmt.Errorf("VaultRecord %q of Group %q %w", idString, group.UUID, ErrNotFound)
 		return
 	}
 
@@ -276,6 +386,11 @@ func (s *VaultService) GetByID(group *model.Group, id int64, additional *model.V
 
 // Update Retrieve a vault record by uuid for a certain group, including audit and secrets
 func (s *VaultService) Update(group *model.Group, vaultRecord *model.VaultRecord) (result *model.VaultRecord, err error) {
+	return s.UpdateContext(context.Background(), group, vaultRecord)
+}
+
+// UpdateContext Update a vault record for a certain group, including audit and secrets
+func (s *VaultService) UpdateContext(ctx context.Context, group *model.Group, vaultRecord *model.VaultRecord) (result *model.VaultRecord, err error) {
 	al := new(model.VaultRecord)
 	errorReport := new(model.ErrorReport)
 
@@ -292,7 +407,7 @@ func (s *VaultService) Update(group *model.Group, vaultRecord *model.VaultRecord
 		vaultRecord.AdditionalObjects.Audit = nil
 	}
 
-	_, err = s.sling.New().Path(selfUrl.Path).Put("").BodyProvider(khJsonBodyProvider{payload: vaultRecord}).QueryStruct(query).Receive(al, errorReport)
+	_, err = receive(ctx, s.sling.New().Path(selfUrl.Path).Put("").BodyProvider(khJsonBodyProvider{payload: vaultRecord}).QueryStruct(query), al, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not update VaultRecord %q of Group %q.", vaultRecord.UUID, group.UUID)
 		return
@@ -305,16 +420,21 @@ func (s *VaultService) Update(group *model.Group, vaultRecord *model.VaultRecord
 
 // DeleteByUUID  Delete a vault record by uuid for a certain group, including audit and secrets
 func (s *VaultService) DeleteByUUID(group *model.Group, uuid uuid.UUID) (err error) {
+	return s.DeleteByUUIDContext(context.Background(), group, uuid)
+}
+
+// DeleteByUUIDContext Delete a vault record by uuid for a certain group
+func (s *VaultService) DeleteByUUIDContext(ctx context.Context, group *model.Group, uuid uuid.UUID) (err error) {
 	errorReport := new(model.ErrorReport)
 
-	vaultRecord, err := s.GetByUUID(group, uuid, nil)
+	vaultRecord, err := s.GetByUUIDContext(ctx, group, uuid, nil)
 	if err != nil {
 		return err
 	}
 
 	selfUrl, _ := url.Parse(vaultRecord.Self().Href)
 
-	_, err = s.sling.New().Path(selfUrl.Path).Delete("").Receive(nil, errorReport)
+	_, err = receive(ctx, s.sling.New().Path(selfUrl.Path).Delete(""), nil, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not delete VaultRecord %q of Group %q.", uuid.String(), group.UUID)
 	}
@@ -324,14 +444,39 @@ func (s *VaultService) DeleteByUUID(group *model.Group, uuid uuid.UUID) (err err
 
 // DeleteByID  Delete a vault record by ID for a certain group, including audit and secrets
 func (s *VaultService) DeleteByID(group *model.Group, id int64) (err error) {
+	return s.DeleteByIDContext(context.Background(), group, id)
+}
+
+// DeleteByIDContext Delete a vault record by ID for a certain group
+func (s *VaultService) DeleteByIDContext(ctx context.Context, group *model.Group, id int64) (err error) {
 	errorReport := new(model.ErrorReport)
 	selfUrl, _ := url.Parse(group.Self().Href)
 	idString := strconv.FormatInt(id, 10)
 
-	_, err = s.sling.New().Path(selfUrl.Path+"/vault/record/").Delete(idString).Receive(nil, errorReport)
+	_, err = receive(ctx, s.sling.New().Path(selfUrl.Path+"/vault/record/").Delete(idString), nil, errorReport)
 	if errorReport.Code > 0 {
 		err = errorReport.Wrap("Could not delete VaultRecord %q of Group %q.", idString, group.UUID)
 	}
 
 	return
 }
+
+// CurrentTOTP Retrieve a vault record by uuid for a certain group and return its current one-time password
+func (s *VaultService) CurrentTOTP(group *model.Group, uuid uuid.UUID) (code string, err error) {
+	return s.CurrentTOTPContext(context.Background(), group, uuid)
+}
+
+// CurrentTOTPContext Retrieve a vault record by uuid for a certain group and return its current one-time password
+func (s *VaultService) CurrentTOTPContext(ctx context.Context, group *model.Group, uuid uuid.UUID) (code string, err error) {
+	record, err := s.GetByUUIDContext(ctx, group, uuid, &model.VaultRecordAdditionalQueryParams{Secret: true})
+	if err != nil {
+		return "", err
+	}
+
+	code, err = record.TOTPCode(time.Now())
+	if err != nil {
+		return "", fmt.Errorf("could not generate totp code for VaultRecord %q of Group %q: %w", uuid.String(), group.UUID, err)
+	}
+
+	return
+}
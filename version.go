@@ -16,6 +16,7 @@
 package keyhub
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -40,10 +41,14 @@ func newVersionService(sling *sling.Sling) *VersionService {
 }
 
 func (s *VersionService) Get() (v *model.VersionInfo, err error) {
+	return s.GetContext(context.Background())
+}
+
+func (s *VersionService) GetContext(ctx context.Context) (v *model.VersionInfo, err error) {
 	results := new(model.VersionInfo)
 	errorReport := new(model.ErrorReport)
 
-	resp, err := s.sling.New().Get("").Receive(results, errorReport)
+	resp, err := receive(ctx, s.sling.New().Get(""), results, errorReport)
 	if err != nil {
 		return
 	}
@@ -60,11 +65,15 @@ func (s *VersionService) Get() (v *model.VersionInfo, err error) {
 }
 
 func (s *VersionService) CheckAndUpdateVersionedSling(version int, base *sling.Sling) (isSupported bool, err error) {
+	return s.CheckAndUpdateVersionedSlingContext(context.Background(), version, base)
+}
+
+func (s *VersionService) CheckAndUpdateVersionedSlingContext(ctx context.Context, version int, base *sling.Sling) (isSupported bool, err error) {
 
 	isSupported = false
 	var headerVersion string
 	if s.info == nil {
-		s.info, err = s.Get()
+		s.info, err = s.GetContext(ctx)
 		if err != nil {
 			return
 		}
@@ -79,7 +88,7 @@ func (s *VersionService) CheckAndUpdateVersionedSling(version int, base *sling.S
 			}
 		}
 		if !isSupported {
-			return isSupported, fmt.Errorf("KeyHub %v does not support api contract version %v", s.info.KeyhubVersion, version)
+			return isSupported, fmt.Errorf("KeyHub %v does not support api contract version %v: %w", s.info.KeyhubVersion, version, ErrVersionUnsupported)
 		}
 
 		headerVersion = fmt.Sprintf("%d", version)